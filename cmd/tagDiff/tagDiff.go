@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagDiff is an alias for "tagparser diff"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagDiff"), "diff", os.Args[1:]))
+}
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagFmt is an alias for "tagparser fmt"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagFmt"), "fmt", os.Args[1:]))
+}
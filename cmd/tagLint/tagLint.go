@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagLint is an alias for "tagparser lint"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagLint"), "lint", os.Args[1:]))
+}
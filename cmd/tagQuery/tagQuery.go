@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagQuery is an alias for "tagparser query"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagQuery"), "query", os.Args[1:]))
+}
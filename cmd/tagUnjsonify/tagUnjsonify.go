@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagUnjsonify is an alias for "tagparser unjson"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagUnjsonify"), "unjson", os.Args[1:]))
+}
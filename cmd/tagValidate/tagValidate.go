@@ -0,0 +1,11 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+// tagValidate is an alias for "tagparser validate"
+func main() {
+	os.Exit(cli.RunCommand(cli.NewEnv("tagValidate"), "validate", os.Args[1:]))
+}
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"go-tagparser/internal/cli"
+	"os"
+)
+
+func main() {
+	os.Exit(cli.Run(cli.NewEnv("tagparser"), os.Args[1:]))
+}
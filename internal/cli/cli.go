@@ -0,0 +1,197 @@
+// Package cli implements the tagparser command line tools.
+//
+// Each tool is a Command, which can be run as a subcommand of the tagparser binary (i.e. "tagparser stat") or on it's
+// own by one of the single purpose binaries (i.e. "tagStat"). Every command shares the same input flags, help output
+// and exit codes.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes shared by every command
+const (
+	ExitSuccess = 0
+	// The command ran, but failed (i.e. a document could not be parsed)
+	ExitFailure = 1
+	// The command line was invalid
+	ExitUsage = 2
+	// The input could not be read (i.e. a missing or empty file). Commands which report a negative result with
+	// ExitFailure (i.e. query when nothing matches, diff when the documents differ, lint when an issue is found or
+	// validate when a document is invalid) also use it for any other failure, as grep and diff do
+	ExitError = 2
+)
+
+// Env is the environment a command runs in
+type Env struct {
+	// The name shown in help and error messages, i.e. "tagparser stat" or "tagStat"
+	Program string
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+// NewEnv: Create an environment using the process' standard streams
+func NewEnv(program string) *Env {
+	return &Env{Program: program, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+// Errorf: Report an error on standard error, prefixed with the program name
+func (env *Env) Errorf(format string, args ...any) {
+	fmt.Fprintf(env.Stderr, "%v: %v\n", env.Program, fmt.Sprintf(format, args...))
+}
+
+// Command is a single tool, such as stat or fmt
+type Command struct {
+	Name string
+	// A one line description, shown in the list of commands
+	Summary string
+	// Lines describing the command in more detail, shown in it's help
+	Description []string
+	// Example command lines, where %prog is replaced by the program name
+	Examples []string
+	// Run the command with the arguments following the command name, returning the exit code
+	Run func(env *Env, command *Command, args []string) int
+}
+
+// Commands: The available commands, in the order they are listed in the help
+func Commands() []*Command {
+	return []*Command{statCommand, jsonCommand, unjsonCommand, fmtCommand, queryCommand, lintCommand, validateCommand, diffCommand}
+}
+
+// FindCommand: Find a command by name. Returns nil if there is no such command
+func FindCommand(name string) *Command {
+	for _, command := range Commands() {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// Run: Run the tagparser binary, where the first argument names the command to run
+func Run(env *Env, args []string) int {
+	if len(args) == 0 {
+		printCommands(env, env.Stderr)
+		return ExitUsage
+	}
+
+	name := args[0]
+	switch name {
+	case "-h", "-help", "--help":
+		printCommands(env, env.Stdout)
+		return ExitSuccess
+	case "help":
+		if len(args) == 1 {
+			printCommands(env, env.Stdout)
+			return ExitSuccess
+		}
+		// "help stat" is the same as "stat --help"
+		name, args = args[1], []string{name, "--help"}
+	}
+
+	command := FindCommand(name)
+	if command == nil {
+		env.Errorf("unknown command %q. Run '%v help' for a list of commands", name, env.Program)
+		return ExitUsage
+	}
+
+	commandEnv := *env
+	commandEnv.Program = env.Program + " " + command.Name
+	return command.Run(&commandEnv, command, args[1:])
+}
+
+// RunCommand: Run a single command directly, as the single purpose binaries do
+func RunCommand(env *Env, name string, args []string) int {
+	command := FindCommand(name)
+	if command == nil {
+		env.Errorf("unknown command %q", name)
+		return ExitUsage
+	}
+	return command.Run(env, command, args)
+}
+
+func printCommands(env *Env, w io.Writer) {
+	fmt.Fprintf(w, "\n%v - Tag Document Tools\n", env.Program)
+	fmt.Fprintln(w, "-------------------------------------")
+	fmt.Fprintf(w, "Usage: %v <command> [arguments]\n", env.Program)
+	fmt.Fprintln(w, "\nCommands:")
+	for _, command := range Commands() {
+		fmt.Fprintf(w, "\t%-10v%v\n", command.Name, command.Summary)
+	}
+	fmt.Fprintf(w, "\nRun '%v <command> --help' for the arguments of a command.\n", env.Program)
+}
+
+// newFlagSet: Create the flag set for a command. Errors and help are reported by parseFlags
+func newFlagSet(env *Env) *flag.FlagSet {
+	fs := flag.NewFlagSet(env.Program, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	return fs
+}
+
+// parseFlags: Parse a command's arguments. Flags may follow the positional arguments (i.e. "tagQuery 'a' -i page.html"),
+// unless they come after "--". When parsing doesn't succeed, the help or error is reported and the exit code is
+// returned, with ok == false
+func parseFlags(env *Env, command *Command, fs *flag.FlagSet, args []string) (code int, ok bool) {
+	positional := make([]string, 0)
+	for {
+		err := fs.Parse(args)
+		switch {
+		case err == nil:
+		case errors.Is(err, flag.ErrHelp):
+			printUsage(env, command, fs, env.Stdout)
+			return ExitSuccess, false
+		default:
+			env.Errorf("%v", err)
+			printUsage(env, command, fs, env.Stderr)
+			return ExitUsage, false
+		}
+
+		remaining := fs.Args()
+		consumed := len(args) - len(remaining)
+		if len(remaining) == 0 || (consumed > 0 && args[consumed-1] == "--") {
+			positional = append(positional, remaining...)
+			break
+		}
+
+		positional = append(positional, remaining[0])
+		args = remaining[1:]
+	}
+
+	// Leave the positional arguments in fs.Args()
+	fs.Parse(append([]string{"--"}, positional...))
+	return ExitSuccess, true
+}
+
+// usageError: Report an invalid command line, along with the command's help
+func usageError(env *Env, command *Command, fs *flag.FlagSet, format string, args ...any) int {
+	env.Errorf(format, args...)
+	printUsage(env, command, fs, env.Stderr)
+	return ExitUsage
+}
+
+func printUsage(env *Env, command *Command, fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "\n%v - %v\n", env.Program, command.Summary)
+	fmt.Fprintln(w, "-------------------------------------")
+	for _, line := range command.Description {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "\nArguments:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+
+	if len(command.Examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range command.Examples {
+			fmt.Fprintf(w, "\t%v\n", strings.ReplaceAll(example, "%prog", env.Program))
+		}
+	}
+}
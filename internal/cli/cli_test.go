@@ -0,0 +1,369 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// testEnv: Create an environment reading the given standard in, with the output captured
+func testEnv(stdin string) (env *Env, stdout *bytes.Buffer, stderr *bytes.Buffer) {
+	stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+	env = &Env{Program: "tagparser", Stdin: strings.NewReader(stdin), Stdout: stdout, Stderr: stderr}
+	return env, stdout, stderr
+}
+
+func TestRun_ListsCommands(t *testing.T) {
+	env, stdout, _ := testEnv("")
+	if code := Run(env, []string{"help"}); code != ExitSuccess {
+		t.Errorf("Run help returned %v, want %v", code, ExitSuccess)
+	}
+
+	for _, command := range Commands() {
+		if !strings.Contains(stdout.String(), command.Name) {
+			t.Errorf("help doesn't list the %v command. Got %q", command.Name, stdout.String())
+		}
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	cases := map[string][]string{
+		"no command":      {},
+		"unknown command": {"nope"},
+		"unknown flag":    {"stat", "-nope"},
+		"no input":        {"json"},
+		"many inputs":     {"json", "-stdin", "a.html"},
+		"bad format":      {"stat", "-stdin", "-format=xml"},
+		"json positions":  {"json", "-stdin", "-positions", "-format=jsonml"},
+	}
+
+	for name, args := range cases {
+		env, stdout, stderr := testEnv("<div></div>")
+		if code := Run(env, args); code != ExitUsage {
+			t.Errorf("%v: Run returned %v, want %v", name, code, ExitUsage)
+		}
+		if stdout.Len() != 0 || stderr.Len() == 0 {
+			t.Errorf("%v: usage errors should only be written to standard error. Got stdout %q", name, stdout.String())
+		}
+	}
+}
+
+func TestRun_CommandHelp(t *testing.T) {
+	for _, args := range [][]string{{"help", "fmt"}, {"fmt", "--help"}, {"fmt", "-h"}} {
+		env, stdout, _ := testEnv("")
+		if code := Run(env, args); code != ExitSuccess {
+			t.Errorf("Run %v returned %v, want %v", args, code, ExitSuccess)
+		}
+		if !strings.Contains(stdout.String(), "tagparser fmt - Tag Document Formatter") {
+			t.Errorf("Run %v didn't write the fmt help. Got %q", args, stdout.String())
+		}
+	}
+}
+
+func TestRun_Json(t *testing.T) {
+	env, stdout, _ := testEnv(`<div id="a">Hello</div>`)
+	if code := Run(env, []string{"json", "-stdin", "-compact"}); code != ExitSuccess {
+		t.Fatalf("Run json returned %v, want %v", code, ExitSuccess)
+	}
+
+	want := `{"_name":"div","id":"a","_children":["Hello"]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Run json wrote %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_JsonPositions(t *testing.T) {
+	env, stdout, _ := testEnv("\n\n<a>\n  <b/>\n</a>")
+	if code := Run(env, []string{"json", "-stdin", "-compact", "-positions"}); code != ExitSuccess {
+		t.Fatalf("Run json returned %v, want %v", code, ExitSuccess)
+	}
+
+	// Offsets and lines are counted from the start of the input, including the whitespace Parse strips
+	want := `{"_name":"a","_start":2,"_end":17,"_line":3,"_column":1,"_depth":0,"_children":[` +
+		`{"_name":"b","_start":8,"_end":12,"_line":4,"_column":3,"_depth":1}]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Run json wrote %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_JsonNdjsonSkipsText(t *testing.T) {
+	env, stdout, _ := testEnv(`<ul>Items:<li>One</li>and<li>Two</li></ul>`)
+	if code := Run(env, []string{"json", "-stdin", "-ndjson"}); code != ExitSuccess {
+		t.Fatalf("Run json returned %v, want %v", code, ExitSuccess)
+	}
+
+	want := `{"_name":"li","_children":["One"]}` + "\n" + `{"_name":"li","_children":["Two"]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Run json wrote %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_ParseFailure(t *testing.T) {
+	env, stdout, stderr := testEnv(`<div a=1></div>`)
+	if code := Run(env, []string{"stat", "-stdin", "-format=json"}); code != ExitFailure {
+		t.Errorf("Run stat returned %v, want %v", code, ExitFailure)
+	}
+	if stdout.Len() != 0 || !strings.HasPrefix(stderr.String(), "tagparser stat: ") {
+		t.Errorf("Run stat should report the failure on standard error. Got stdout %q stderr %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRunCommand_FmtReportsEachDocument(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.html")
+	bad := filepath.Join(dir, "bad.html")
+	os.WriteFile(good, []byte("<div><p>Hi</p></div>"), 0o644)
+	os.WriteFile(bad, []byte("<div a=1></div>"), 0o644)
+
+	env, stdout, stderr := testEnv("")
+	env.Program = "tagFmt"
+	if code := RunCommand(env, "fmt", []string{"-l", bad, good}); code != ExitFailure {
+		t.Errorf("RunCommand fmt returned %v, want %v", code, ExitFailure)
+	}
+
+	if stdout.String() != good+"\n" {
+		t.Errorf("RunCommand fmt -l wrote %q, want %q", stdout.String(), good+"\n")
+	}
+	if !strings.HasPrefix(stderr.String(), "tagFmt: "+bad+": ") {
+		t.Errorf("RunCommand fmt should report the failing document. Got %q", stderr.String())
+	}
+}
+
+func TestRun_EmptyInput(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.html")
+	os.WriteFile(empty, []byte(" \n\t"), 0o644)
+
+	cases := [][]string{
+		{"stat", "-stdin"},
+		{"json", "-stdin"},
+		{"unjson", "-stdin"},
+		{"fmt", "-stdin"},
+		{"query", "p", "-stdin"},
+		{"lint", "-stdin"},
+		{"diff", "-", empty},
+		{"stat", empty},
+		{"fmt", empty},
+	}
+
+	for _, args := range cases {
+		env, _, stderr := testEnv("\n")
+		if code := Run(env, args); code != ExitError {
+			t.Errorf("Run %v with empty input returned %v, want %v", args, code, ExitError)
+		}
+		if !strings.Contains(stderr.String(), "empty") {
+			t.Errorf("Run %v with empty input should report it. Got %q", args, stderr.String())
+		}
+	}
+}
+
+func TestRunCommand_Query(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.html")
+	second := filepath.Join(dir, "second.html")
+	os.WriteFile(first, []byte(`<nav><a href="/a">A</a><a>None</a></nav>`), 0o644)
+	os.WriteFile(second, []byte("<nav><a href=\"/b\"><b>B</b>\n</a></nav>"), 0o644)
+
+	cases := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"markup", []string{"a[href]", "-i", first}, `<a href="/a">A</a>` + "\n"},
+		{"text", []string{"a", "-text", first}, "A\nNone\n"},
+		{"attribute", []string{"a", "-attr", "href", first, second}, first + ":/a\n" + second + ":/b\n"},
+		{"no prefix", []string{"-h", "a", "-attr", "href", first, second}, "/a\n/b\n"},
+		{"json", []string{"a[href]", "-json", "-H", first}, `{"file":"` + first + `","match":{"_name":"a","href":"/a","_children":["A"]}}` + "\n"},
+		{"multi-line markup", []string{"a", "-H", second}, second + ":<a href=\"/b\"><b>B</b>\n" + second + ":</a>\n"},
+	}
+
+	for _, c := range cases {
+		env, stdout, stderr := testEnv("")
+		if code := RunCommand(env, "query", c.args); code != ExitSuccess {
+			t.Errorf("%v: RunCommand query returned %v, want %v. Got stderr %q", c.name, code, ExitSuccess, stderr.String())
+		}
+		if stdout.String() != c.want {
+			t.Errorf("%v: RunCommand query wrote %q, want %q", c.name, stdout.String(), c.want)
+		}
+	}
+}
+
+func TestRunCommand_QueryWithoutMatches(t *testing.T) {
+	env, stdout, _ := testEnv(`<div><p>Hi</p></div>`)
+	if code := RunCommand(env, "query", []string{"span", "-stdin"}); code != ExitFailure {
+		t.Errorf("RunCommand query returned %v, want %v", code, ExitFailure)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("RunCommand query without matches wrote %q", stdout.String())
+	}
+}
+
+func TestRunCommand_QueryErrors(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	os.WriteFile(page, []byte(`<p>Hi</p>`), 0o644)
+
+	cases := map[string][]string{
+		"unparseable":         {"p", "-stdin"},
+		"missing":             {"p", filepath.Join(dir, "missing.html")},
+		"matched and missing": {"p", page, filepath.Join(dir, "missing.html")},
+	}
+
+	for name, args := range cases {
+		env, _, stderr := testEnv(`<p>Hi</div>`)
+		if code := RunCommand(env, "query", args); code != ExitError {
+			t.Errorf("%v: RunCommand query returned %v, want %v", name, code, ExitError)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("%v: RunCommand query should report the error on standard error", name)
+		}
+	}
+}
+
+func TestRunCommand_Lint(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "lint.json")
+	page := filepath.Join(dir, "page.html")
+	os.WriteFile(config, []byte(`{"disallowedTags": ["font"]}`), 0o644)
+	os.WriteFile(page, []byte("\n\n<div>\n  <font>Hi</font>\n</div>"), 0o644)
+
+	env, stdout, stderr := testEnv("<div a=1></div>")
+	if code := RunCommand(env, "lint", []string{"-config", config, "-stdin", dir}); code != ExitFailure {
+		t.Errorf("RunCommand lint returned %v, want %v. Got stderr %q", code, ExitFailure, stderr.String())
+	}
+
+	// Lines are counted from the start of the file, including the whitespace Parse strips
+	want := "<standard input>:1:8: Invalid attribute value quotation. Should be \" or '. Was 1 (parse-error)\n" +
+		page + ":4:3: <font> is not allowed (disallowed-tag)\n"
+	if stdout.String() != want {
+		t.Errorf("RunCommand lint wrote %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRunCommand_LintErrors(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "lint.json")
+	page := filepath.Join(dir, "page.html")
+	os.WriteFile(config, []byte(`{"disallowedTags": [`), 0o644)
+	os.WriteFile(page, []byte(`<img src="a.png" />`), 0o644)
+
+	cases := map[string][]string{
+		"missing config": {"-config", filepath.Join(dir, "missing.json"), page},
+		"invalid config": {"-config", config, page},
+		"missing input":  {page, filepath.Join(dir, "missing.html")},
+		"invalid glob":   {filepath.Join(dir, "[")},
+		"unreadable":     {"-stdin", page},
+	}
+
+	for name, args := range cases {
+		env, _, stderr := testEnv("")
+		env.Stdin = iotest.ErrReader(errors.New("broken pipe"))
+		if code := RunCommand(env, "lint", args); code != ExitError {
+			t.Errorf("%v: RunCommand lint returned %v, want %v", name, code, ExitError)
+		}
+		if stderr.Len() == 0 {
+			t.Errorf("%v: RunCommand lint should report the error on standard error", name)
+		}
+	}
+}
+
+func TestRunCommand_Validate(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "config.schema")
+	config := filepath.Join(dir, "config.xml")
+	os.WriteFile(schema, []byte(`<schema root="config"><element name="config"><attribute name="port" type="int" /></element></schema>`), 0o644)
+	os.WriteFile(config, []byte("\n<config port=\"80\" />"), 0o644)
+
+	env, stdout, stderr := testEnv("\n\n  <config port=\"http\"><x /></config>")
+	if code := RunCommand(env, "validate", []string{"-schema", schema, config, "-stdin"}); code != ExitFailure {
+		t.Errorf("RunCommand validate returned %v, want %v. Got stderr %q", code, ExitFailure, stderr.String())
+	}
+
+	want := "<standard input>:3:3: /config/@port: invalid port attribute - \"http\" is not an int\n" +
+		"<standard input>:3:23: /config/x: <x> is not allowed in <config>\n"
+	if stdout.String() != want {
+		t.Errorf("RunCommand validate wrote %q, want %q", stdout.String(), want)
+	}
+
+	env, _, stderr = testEnv("")
+	if code := RunCommand(env, "validate", []string{config}); code != ExitUsage {
+		t.Errorf("RunCommand validate without a schema returned %v, want %v", code, ExitUsage)
+	}
+
+	os.WriteFile(schema, []byte(`<schema><element /></schema>`), 0o644)
+	env, _, stderr = testEnv("")
+	if code := RunCommand(env, "validate", []string{"-schema", schema, config}); code != ExitError || !strings.Contains(stderr.String(), "invalid schema") {
+		t.Errorf("RunCommand validate with an invalid schema returned %v and wrote %q", code, stderr.String())
+	}
+}
+
+func TestRunCommand_ValidateErrors(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "config.schema")
+	config := filepath.Join(dir, "config.xml")
+	broken := filepath.Join(dir, "broken.xml")
+	os.WriteFile(schema, []byte(`<schema root="config"><element name="config" /></schema>`), 0o644)
+	os.WriteFile(config, []byte(`<config />`), 0o644)
+	os.WriteFile(broken, []byte(`<config><x /></config>`+"\n<config>"), 0o644)
+
+	cases := map[string][]string{
+		"missing schema": {"-schema", filepath.Join(dir, "missing.schema"), config},
+		"missing input":  {"-schema", schema, config, filepath.Join(dir, "missing.xml")},
+		"unreadable":     {"-schema", schema, "-stdin"},
+		"unparseable":    {"-schema", schema, config, broken},
+	}
+
+	for name, args := range cases {
+		env, _, _ := testEnv("")
+		env.Stdin = iotest.ErrReader(errors.New("broken pipe"))
+		if code := RunCommand(env, "validate", args); code != ExitError {
+			t.Errorf("%v: RunCommand validate returned %v, want %v", name, code, ExitError)
+		}
+	}
+}
+
+func TestRunCommand_Diff(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.html")
+	os.WriteFile(old, []byte(`<ul><li class="a">One</li></ul>`), 0o644)
+
+	env, stdout, _ := testEnv("<ul>\n    <li class='a'>One</li>\n</ul>")
+	if code := RunCommand(env, "diff", []string{old, "-"}); code != ExitSuccess || stdout.Len() != 0 {
+		t.Errorf("RunCommand diff of equivalent documents returned %v and wrote %q", code, stdout.String())
+	}
+
+	env, stdout, _ = testEnv(`<ul><li class="b">One</li><li>Two</li></ul>`)
+	if code := RunCommand(env, "diff", []string{old, "-"}); code != ExitFailure {
+		t.Errorf("RunCommand diff returned %v, want %v", code, ExitFailure)
+	}
+
+	want := "~ /ul/li[1]/@class: \"a\" -> \"b\"\n+ /ul/li[2]: <li>Two</li>\n"
+	if stdout.String() != want {
+		t.Errorf("RunCommand diff wrote %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRunCommand_DiffErrors(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.html")
+	os.WriteFile(old, []byte(`<ul><li>One</li></ul>`), 0o644)
+
+	cases := map[string][]string{
+		"unparseable": {old, "-"},
+		"missing":     {old, filepath.Join(dir, "missing.html")},
+	}
+
+	for name, args := range cases {
+		env, stdout, stderr := testEnv(`<ul><li>One</ul>`)
+		if code := RunCommand(env, "diff", args); code != ExitError {
+			t.Errorf("%v: RunCommand diff returned %v, want %v", name, code, ExitError)
+		}
+		if stdout.Len() != 0 || stderr.Len() == 0 {
+			t.Errorf("%v: RunCommand diff should only report the error on standard error. Got stdout %q", name, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+)
+
+var diffCommand = &Command{
+	Name:    "diff",
+	Summary: "Structural Tag Document Diff",
+	Description: []string{
+		"Compare the structure of two tag documents, writing the edits which turn the first into the second to standard out.",
+		"Formatting-only differences (whitespace, attribute order and quotation) are ignored.",
+		"Provide the two files as arguments. Use - to read one of them from standard in.",
+		"The exit code is 1 when the documents differ, and 2 when a document could not be read or parsed.",
+	},
+	Examples: []string{
+		"%prog ./old.html ./new.html",
+		"%prog -json ./old.html ./new.html | jq '.[].op'",
+		"git show HEAD:page.html | %prog - ./page.html",
+	},
+	Run: runDiff,
+}
+
+func runDiff(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	writeJson := fs.Bool("json", false, "Write the edits as a JSON Patch style array of operations.")
+	quiet := fs.Bool("q", false, "Don't write the edits. Only report whether the documents differ with the exit code.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() != 2 {
+		return usageError(env, command, fs, "expected two documents to compare, got %v", fs.NArg())
+	}
+	if fs.Arg(0) == "-" && fs.Arg(1) == "-" {
+		return usageError(env, command, fs, "only one document can be read from standard in")
+	}
+
+	trees := make([]parser.Tag, 0, 2)
+	for _, path := range fs.Args() {
+		if path == "-" {
+			path = ""
+		}
+
+		doc, err := readDocument(env, path)
+		if err != nil {
+			env.Errorf("%v", err)
+			return ExitError
+		}
+
+		result, err := parser.Parse([]rune(string(doc.input)))
+		if err != nil {
+			env.Errorf("error occurred parsing %v - %v", doc.name(), err)
+			return ExitError
+		}
+		trees = append(trees, result.Root)
+	}
+
+	edits := parser.Diff(&trees[0], &trees[1])
+	switch {
+	case *quiet:
+	case *writeJson:
+		encoded, err := json.MarshalIndent(edits, "", "    ")
+		if err != nil {
+			env.Errorf("error occurred writing json - %v", err)
+			return ExitError
+		}
+		fmt.Fprintf(env.Stdout, "%s\n", encoded)
+	default:
+		for _, edit := range edits {
+			fmt.Fprintln(env.Stdout, edit.String())
+		}
+	}
+
+	if len(edits) > 0 {
+		return ExitFailure
+	}
+	return ExitSuccess
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+	"os"
+)
+
+var fmtCommand = &Command{
+	Name:    "fmt",
+	Summary: "Tag Document Formatter",
+	Description: []string{
+		"Reformat tag documents with consistent indentation, attribute layout and quotation.",
+		"Provide the files to format as arguments (or with -i), or use -stdin to read your input from standard in.",
+		"By default the formatted documents are written to standard out.",
+	},
+	Examples: []string{
+		"%prog ./test.html",
+		"%prog -l -w ./templates/*.html",
+		"echo \"<div><p>Hello, World!</p></div>\" | %prog -stdin -indent=\"  \"",
+	},
+	Run: runFmt,
+}
+
+type fmtOptions struct {
+	writeInPlace  bool
+	listDiffering bool
+	format        parser.FormatOptions
+}
+
+func runFmt(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	opts := fmtOptions{}
+	fs.BoolVar(&opts.writeInPlace, "w", false, "Write the result back to the source file instead of standard out.")
+	fs.BoolVar(&opts.listDiffering, "l", false, "List the files whose formatting differs from the formatter's.")
+	fs.StringVar(&opts.format.Indent, "indent", "    ", "The string used for each level of indentation.")
+	fs.IntVar(&opts.format.MaxWidth, "width", 0, "Wrap the attributes of opening tags wider than this many characters. 0 disables wrapping.")
+	fs.BoolVar(&opts.format.SortAttributes, "sort", false, "Sort attributes by name instead of keeping their source order.")
+	quote := fs.String("quote", "\"", "The preferred attribute quotation, either \" or '.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	if len(*quote) > 0 {
+		opts.format.Quote = []rune(*quote)[0]
+	}
+
+	if in.count(fs) == 0 {
+		return usageError(env, command, fs, "you must provide the files to format, or -stdin to read from standard in")
+	}
+
+	return in.forEachDocument(env, fs, func(doc document) error {
+		return formatDocument(env, doc, opts)
+	})
+}
+
+// formatDocument: Format a single document, reporting it as required by the -l and -w flags.
+// Documents read from standard in are never written in place
+func formatDocument(env *Env, doc document, opts fmtOptions) error {
+	formatted, err := parser.Format([]rune(string(doc.input)), opts.format)
+	if err != nil {
+		return err
+	}
+
+	differs := formatted != string(doc.input)
+	if opts.listDiffering && differs {
+		fmt.Fprintln(env.Stdout, doc.name())
+	}
+
+	if opts.writeInPlace && doc.path != "" {
+		if !differs {
+			return nil
+		}
+
+		info, err := os.Stat(doc.path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(doc.path, []byte(formatted), info.Mode().Perm())
+	}
+
+	if !opts.listDiffering {
+		_, err = fmt.Fprint(env.Stdout, formatted)
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The name used for standard in when reporting
+const stdinName = "<standard input>"
+
+// inputFlags are the input arguments shared by every command: -i, -stdin, and any files given as arguments
+type inputFlags struct {
+	path  string
+	stdin bool
+	// The number of leading arguments which are not inputs, i.e. the selector of the query command
+	leading int
+	// The documents found by expand, replacing the file inputs
+	expanded []string
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	in := &inputFlags{}
+	fs.StringVar(&in.path, "i", "", "Read Tag Document from a file. You must provide the path to the tag document.")
+	fs.BoolVar(&in.stdin, "stdin", false, "Read Tag Document from stdin.")
+	return in
+}
+
+// paths: The files to read - The -i file, followed by any arguments
+func (in *inputFlags) paths(fs *flag.FlagSet) []string {
+	if in.expanded != nil {
+		return in.expanded
+	}
+
+	paths := fs.Args()[min(in.leading, fs.NArg()):]
+	if len(in.path) > 0 {
+		paths = append([]string{in.path}, paths...)
+	}
+	return paths
+}
+
+// expand: Replace the file inputs with the documents found within them. See expandInputs
+func (in *inputFlags) expand(fs *flag.FlagSet, extensions string) error {
+	inputs := in.paths(fs)
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	paths, err := expandInputs(inputs, extensions)
+	if err == nil && len(paths) == 0 {
+		err = fmt.Errorf("no documents found in %v", strings.Join(inputs, ", "))
+	}
+
+	in.expanded = paths
+	return err
+}
+
+// count: The total number of inputs, including standard in
+func (in *inputFlags) count(fs *flag.FlagSet) int {
+	count := len(in.paths(fs))
+	if in.stdin {
+		count += 1
+	}
+	return count
+}
+
+// document is a single input, read into memory
+type document struct {
+	// The path of the file, or the empty string for standard in
+	path  string
+	input []byte
+}
+
+// name: The name used to report the document
+func (d *document) name() string {
+	if d.path == "" {
+		return stdinName
+	}
+	return d.path
+}
+
+// readDocument: Read a file, or standard in when the path is empty. Documents which are empty (or only whitespace)
+// cannot be parsed, so are reported as an error here
+func readDocument(env *Env, path string) (document, error) {
+	var input []byte
+	var err error
+	if path == "" {
+		input, err = io.ReadAll(env.Stdin)
+	} else {
+		input, err = os.ReadFile(path)
+	}
+
+	doc := document{path: path, input: input}
+	if err == nil && len(bytes.TrimSpace(input)) == 0 {
+		err = fmt.Errorf("the document is empty")
+	}
+	return doc, err
+}
+
+// readSingle: Read the one document a command operates on. Reports a usage error unless there is exactly one input
+func (in *inputFlags) readSingle(env *Env, command *Command, fs *flag.FlagSet) (doc document, code int, ok bool) {
+	paths := in.paths(fs)
+	switch count := in.count(fs); {
+	case count == 0:
+		return doc, usageError(env, command, fs, "you must specify either -i to provide an input file, or -stdin to read from standard in"), false
+	case count > 1:
+		return doc, usageError(env, command, fs, "expected a single input document, got %v", count), false
+	}
+
+	path := ""
+	if len(paths) > 0 {
+		path = paths[0]
+	}
+
+	doc, err := readDocument(env, path)
+	if err != nil {
+		env.Errorf("%v", err)
+		return doc, ExitError, false
+	}
+
+	return doc, ExitSuccess, true
+}
+
+// forEachDocument: Read every input in turn (standard in first), calling fn for each. Failures are reported against the
+// document and the remaining documents are still processed. Returns ExitError if any document could not be read, and
+// otherwise ExitFailure if fn failed for any document
+func (in *inputFlags) forEachDocument(env *Env, fs *flag.FlagSet, fn func(doc document) error) int {
+	paths := in.paths(fs)
+	if in.stdin {
+		paths = append([]string{""}, paths...)
+	}
+
+	code := ExitSuccess
+	for _, path := range paths {
+		doc, err := readDocument(env, path)
+		if err != nil {
+			env.Errorf("%v: %v", doc.name(), err)
+			code = ExitError
+			continue
+		}
+
+		if err := fn(doc); err != nil {
+			env.Errorf("%v: %v", doc.name(), err)
+			if code == ExitSuccess {
+				code = ExitFailure
+			}
+		}
+	}
+	return code
+}
+
+// expandInputs: Find the documents for each input. Directories are searched recursively for files with one of the
+// extensions, and glob patterns are expanded. The paths are sorted, so the output is deterministic
+func expandInputs(inputs []string, extensions string) ([]string, error) {
+	allowed := map[string]bool{}
+	for _, extension := range strings.Split(extensions, ",") {
+		extension = strings.TrimSpace(extension)
+		if extension != "" && !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		allowed[strings.ToLower(extension)] = true
+	}
+
+	seen := map[string]bool{}
+	paths := make([]string, 0)
+	addPath := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			matches, err = filepath.Glob(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %v - %v", input, err)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				addPath(match)
+				continue
+			}
+
+			err = filepath.WalkDir(match, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !entry.IsDir() && allowed[strings.ToLower(filepath.Ext(path))] {
+					addPath(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
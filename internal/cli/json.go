@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"bufio"
+	parser "go-tagparser/pkg/tagparser"
+	"strings"
+)
+
+var jsonCommand = &Command{
+	Name:    "json",
+	Summary: "Tag Document Jsonification",
+	Description: []string{
+		"Convert a tag document into JSON (or YAML), written to standard out.",
+		"Provide a file with -i (or as an argument), or use -stdin to read your input from standard in.",
+	},
+	Examples: []string{
+		"%prog -i ./test.html > test.json",
+		"echo \"<div>Hello, World!</div>\" | %prog -stdin",
+		"%prog -i ./test.xml -format=badgerfish",
+		"%prog -i ./test.html -positions",
+		"%prog -i ./config.xml -format=yaml",
+		"%prog -i ./test.html -ndjson -select=\"ul > li\" | jq .id",
+	},
+	Run: runJson,
+}
+
+func runJson(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	includePositions := fs.Bool("positions", false, "Include the offsets, line, column and depth of each tag. Only supported by the default and structured formats.")
+	compact := fs.Bool("compact", false, "Write compact JSON, without any indentation or line breaks.")
+	indent := fs.String("indent", "    ", "The string used for each level of indentation.")
+	ndjson := fs.Bool("ndjson", false, "Write newline delimited JSON, with one compact JSON value per line for each selected tag.")
+	selector := fs.String("select", "", "With -ndjson, a CSS selector choosing the tags to write. Defaults to each child element of the root tag.")
+	format := fs.String("format", "default", "The shape of the JSON output. One of default, structured, jsonml, badgerfish or parker. Use yaml to write YAML (in the default shape) instead.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	writeYaml := strings.EqualFold(*format, "yaml")
+	if writeYaml && (*includePositions || *compact || *ndjson) {
+		return usageError(env, command, fs, "-format=yaml cannot be used with -positions, -compact or -ndjson")
+	}
+
+	jsonFormat := parser.JsonFormatDefault
+	var err error
+	if !writeYaml {
+		jsonFormat, err = parser.ParseJsonFormat(*format)
+		if err != nil {
+			return usageError(env, command, fs, "%v", err)
+		}
+	}
+
+	if *includePositions && jsonFormat != parser.JsonFormatDefault && jsonFormat != parser.JsonFormatStructured {
+		return usageError(env, command, fs, "-positions is only supported by the default and structured formats, not %v", jsonFormat)
+	}
+
+	var compiledSelector *parser.Selector
+	if len(*selector) > 0 {
+		if !*ndjson {
+			return usageError(env, command, fs, "-select can only be used with -ndjson")
+		}
+
+		compiledSelector, err = parser.CompileSelector(*selector)
+		if err != nil {
+			return usageError(env, command, fs, "invalid selector - %v", err)
+		}
+	}
+
+	doc, code, ok := in.readSingle(env, command, fs)
+	if !ok {
+		return code
+	}
+
+	result, err := parser.Parse([]rune(string(doc.input)))
+	if err != nil {
+		env.Errorf("error occurred parsing %v - %v", doc.name(), err)
+		return ExitFailure
+	}
+
+	writer := bufio.NewWriter(env.Stdout)
+	encoder := parser.NewJsonEncoder(writer)
+	encoder.SetFormat(jsonFormat)
+	if *includePositions {
+		encoder.SetLineIndex(result.LineIndex())
+	}
+	if *compact || *ndjson {
+		encoder.SetIndent("")
+	} else {
+		encoder.SetIndent(*indent)
+	}
+
+	tags := []*parser.Tag{&result.Root}
+	if *ndjson {
+		tags = selectTags(&result.Root, compiledSelector)
+	}
+
+	for _, tag := range tags {
+		if writeYaml {
+			err = parser.WriteYaml(writer, tag)
+		} else {
+			err = encoder.Encode(tag)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	if err != nil {
+		env.Errorf("error occurred writing json - %v", err)
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+// selectTags: Find the tags written in -ndjson mode - Either those matching the selector, or the root's child
+// elements (skipping it's text)
+func selectTags(root *parser.Tag, selector *parser.Selector) []*parser.Tag {
+	if selector != nil {
+		return selector.Select(root)
+	}
+
+	tags := make([]*parser.Tag, 0, len(root.Children))
+	for idx := range root.Children {
+		if root.Children[idx].Name == parser.TextTagName {
+			continue
+		}
+		tags = append(tags, &root.Children[idx])
+	}
+	return tags
+}
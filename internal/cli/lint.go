@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+	"os"
+)
+
+var lintCommand = &Command{
+	Name:    "lint",
+	Summary: "Tag Document Linter",
+	Description: []string{
+		"Check tag documents for style and correctness issues, writing each issue to standard out as file:line:col.",
+		"Provide the files to check as arguments (or with -i), or use -stdin to read your input from standard in.",
+		"The rules are read from the JSON -config file. Without one, duplicate ids and images without alt text are reported.",
+		"The exit code is 1 when any issue is found, and 2 when the config or a document could not be read.",
+	},
+	Examples: []string{
+		"%prog ./site/*.html",
+		"%prog -config=lint.json ./templates",
+		"echo \"<div><img src='a.png'/></div>\" | %prog -stdin",
+	},
+	Run: runLint,
+}
+
+func runLint(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	configPath := fs.String("config", "", "Read the lint rules from this JSON file.")
+	extensions := fs.String("ext", ".html,.htm,.xml", "A comma separated list of the file extensions read from directories.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	config := parser.DefaultLintConfig()
+	if *configPath != "" {
+		file, err := os.Open(*configPath)
+		if err != nil {
+			env.Errorf("%v", err)
+			return ExitError
+		}
+
+		config, err = parser.ParseLintConfig(file)
+		file.Close()
+		if err != nil {
+			env.Errorf("%v: %v", *configPath, err)
+			return ExitError
+		}
+	}
+
+	linter, err := parser.NewLinter(config)
+	if err != nil {
+		env.Errorf("%v: %v", *configPath, err)
+		return ExitError
+	}
+
+	if in.count(fs) == 0 {
+		return usageError(env, command, fs, "you must provide the files to check, or -stdin to read from standard in")
+	}
+
+	if err := in.expand(fs, *extensions); err != nil {
+		env.Errorf("%v", err)
+		return ExitError
+	}
+
+	found := false
+	code := in.forEachDocument(env, fs, func(doc document) error {
+		issues, err := lintDocument(env, doc, linter)
+		found = found || issues > 0
+		return err
+	})
+
+	switch {
+	case code != ExitSuccess:
+		return ExitError
+	case found:
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+// lintDocument: Write the issues found in a single document, returning the number of issues. Parse errors are
+// reported as an issue, as they are the most important problem with a document
+func lintDocument(env *Env, doc document, linter *parser.Linter) (int, error) {
+	result, err := parser.Parse([]rune(string(doc.input)))
+	if err != nil {
+		var parseError *parser.ParseError
+		if !errors.As(err, &parseError) {
+			return 0, err
+		}
+
+		position := result.LineIndex().Position(parseError.StartIdx)
+		fmt.Fprintf(env.Stdout, "%v:%v:%v: %v (parse-error)\n", doc.name(), position.Line, position.Column, parseError.Reason)
+		return 1, nil
+	}
+
+	issues := linter.Lint(result)
+	for _, issue := range issues {
+		fmt.Fprintf(env.Stdout, "%v:%v:%v: %v (%v)\n", doc.name(), issue.Position.Line, issue.Position.Column, issue.Message, issue.Rule)
+	}
+	return len(issues), nil
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+	"io"
+	"strings"
+)
+
+var queryCommand = &Command{
+	Name:    "query",
+	Summary: "Select elements from Tag Documents",
+	Description: []string{
+		"Write the elements matching a CSS selector to standard out, as markup, text, an attribute's value or JSON.",
+		"Provide the selector, followed by the files to search (or -i), or use -stdin to read your input from standard in.",
+		"When searching many documents, each line is prefixed with the file it was found in, as grep does.",
+		"The exit code is 1 when nothing matches, and 2 when a document could not be read or parsed.",
+	},
+	Examples: []string{
+		"%prog 'nav a[href]' -i ./page.html",
+		"%prog 'a' -attr href ./site/*.html",
+		"curl -s https://example.com | %prog 'h1, h2' -stdin -text",
+		"%prog 'ul > li' -json ./page.html | jq .id",
+	},
+	Run: runQuery,
+}
+
+type queryOutput int
+
+const (
+	queryOutputMarkup queryOutput = iota
+	queryOutputText
+	queryOutputAttribute
+	queryOutputJson
+)
+
+type queryOptions struct {
+	selector  *parser.Selector
+	output    queryOutput
+	attribute string
+	// Prefix each line with the name of the document
+	prefix bool
+}
+
+func runQuery(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	in.leading = 1
+	opts := queryOptions{}
+	text := fs.Bool("text", false, "Write the text content of each match, on a single line.")
+	fs.StringVar(&opts.attribute, "attr", "", "Write the value of this attribute for each match. Matches without the attribute are skipped.")
+	writeJson := fs.Bool("json", false, "Write each match as a line of compact JSON.")
+	withFilename := fs.Bool("H", false, "Always prefix the output with the name of the document.")
+	noFilename := fs.Bool("h", false, "Never prefix the output with the name of the document.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	if fs.NArg() == 0 {
+		return usageError(env, command, fs, "you must provide a selector")
+	}
+
+	outputs := 0
+	for _, enabled := range []bool{*text, opts.attribute != "", *writeJson} {
+		if enabled {
+			outputs += 1
+		}
+	}
+
+	switch {
+	case outputs > 1:
+		return usageError(env, command, fs, "only one of -text, -attr or -json may be used")
+	case *withFilename && *noFilename:
+		return usageError(env, command, fs, "-H and -h cannot be used together")
+	case *text:
+		opts.output = queryOutputText
+	case opts.attribute != "":
+		opts.output = queryOutputAttribute
+	case *writeJson:
+		opts.output = queryOutputJson
+	}
+
+	var err error
+	opts.selector, err = parser.CompileSelector(fs.Arg(0))
+	if err != nil {
+		return usageError(env, command, fs, "invalid selector - %v", err)
+	}
+
+	if in.count(fs) == 0 {
+		return usageError(env, command, fs, "you must provide the files to search, or -stdin to read from standard in")
+	}
+	opts.prefix = *withFilename || (in.count(fs) > 1 && !*noFilename)
+
+	writer := bufio.NewWriter(env.Stdout)
+	matched := 0
+	code := in.forEachDocument(env, fs, func(doc document) error {
+		count, err := queryDocument(writer, doc, opts)
+		matched += count
+		if err == nil {
+			// Flush each document, so failures are reported after the matches which preceded them
+			err = writer.Flush()
+		}
+		return err
+	})
+
+	switch {
+	case code != ExitSuccess:
+		return ExitError
+	case matched == 0:
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+// queryDocument: Write the matches within a single document, returning the number of matches written
+func queryDocument(w io.Writer, doc document, opts queryOptions) (int, error) {
+	result, err := parser.Parse([]rune(string(doc.input)))
+	if err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	for _, tag := range opts.selector.Select(&result.Root) {
+		var output string
+		switch opts.output {
+		case queryOutputText:
+			output = tag.InnerText(" ")
+		case queryOutputAttribute:
+			value, ok := tag.Attributes[opts.attribute]
+			if !ok {
+				continue
+			}
+			output = value
+		case queryOutputJson:
+			output, err = queryJson(tag, doc, opts.prefix)
+			if err != nil {
+				return matched, err
+			}
+		default:
+			output = tag.Render(result.Document)
+		}
+
+		if opts.prefix && opts.output != queryOutputJson {
+			// Every line of multi-line markup is prefixed, as grep would
+			output = doc.name() + ":" + strings.ReplaceAll(output, "\n", "\n"+doc.name()+":")
+		}
+
+		if _, err := fmt.Fprintln(w, output); err != nil {
+			return matched, err
+		}
+		matched += 1
+	}
+
+	return matched, nil
+}
+
+// queryJson: Encode a match as compact JSON. When prefixed, the match is wrapped in an object naming it's document so
+// each line remains valid JSON
+func queryJson(tag *parser.Tag, doc document, prefix bool) (string, error) {
+	var encoded []byte
+	var err error
+	if prefix {
+		encoded, err = json.Marshal(struct {
+			File  string      `json:"file"`
+			Match *parser.Tag `json:"match"`
+		}{doc.name(), tag})
+	} else {
+		encoded, err = json.Marshal(tag)
+	}
+	return string(encoded), err
+}
@@ -0,0 +1,309 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var statCommand = &Command{
+	Name:    "stat",
+	Summary: "Tag Document Statistics",
+	Description: []string{
+		"Parse tag documents, writing helpful statistics to standard out.",
+		"Provide a file with -i (or as an argument), or use -stdin to read your input from standard in.",
+		"Directories and glob patterns may also be provided to report on many documents at once.",
+	},
+	Examples: []string{
+		"%prog -i ./test.html",
+		"echo \"<div>Hello, World!</div>\" | %prog -stdin",
+		"%prog -i ./test.html -format=csv > stats.csv",
+		"%prog -merged ./site \"./templates/*.html\"",
+	},
+	Run: runStat,
+}
+
+type statOptions struct {
+	format     parser.StatsFormat
+	extensions string
+	workers    int
+	mergedOnly bool
+}
+
+func runStat(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	format := fs.String("format", "text", "The output format. One of text, json, csv or markdown. Only text output for a single document includes the document.")
+	opts := statOptions{}
+	fs.StringVar(&opts.extensions, "ext", ".html,.htm,.xml", "A comma separated list of the file extensions read from directories.")
+	fs.IntVar(&opts.workers, "j", runtime.NumCPU(), "The number of documents to parse concurrently.")
+	fs.BoolVar(&opts.mergedOnly, "merged", false, "When reading many documents, only report the merged stats (and any failures).")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	var err error
+	opts.format, err = parser.ParseStatsFormat(*format)
+	if err != nil {
+		return usageError(env, command, fs, "%v", err)
+	}
+
+	inputs := in.paths(fs)
+	if !in.stdin && len(inputs) > 0 {
+		paths, err := expandInputs(inputs, opts.extensions)
+		if err != nil {
+			env.Errorf("%v", err)
+			return ExitFailure
+		}
+
+		// A single file keeps the single document output
+		if len(inputs) > 1 || len(paths) != 1 || paths[0] != inputs[0] {
+			if len(paths) == 0 {
+				env.Errorf("no documents found in %v", strings.Join(inputs, ", "))
+				return ExitFailure
+			}
+
+			return reportFileStats(env, calculateFileStats(paths, opts.workers), opts)
+		}
+	}
+
+	doc, code, ok := in.readSingle(env, command, fs)
+	if !ok {
+		return code
+	}
+
+	result, err := parser.Parse([]rune(string(doc.input)))
+	if err != nil {
+		env.Errorf("error occurred parsing %v - %v", doc.name(), err)
+		return ExitFailure
+	}
+
+	stats := parser.CalculateDocumentStats(result)
+	rendered, err := stats.RenderFormat(opts.format)
+	if err != nil {
+		env.Errorf("error occurred rendering stats - %v", err)
+		return ExitFailure
+	}
+
+	if opts.format == parser.StatsFormatText {
+		// Machine readable formats are written alone, so they can be piped directly to other tools. Text also echoes the input
+		fmt.Fprintf(env.Stdout, "Input:\n%v\n\n", string(doc.input))
+	}
+
+	io.WriteString(env.Stdout, rendered)
+	return ExitSuccess
+}
+
+// fileStats is the outcome of reading and parsing a single document
+type fileStats struct {
+	path  string
+	stats parser.Stats
+	err   error
+}
+
+// calculateFileStats: Read, parse and calculate the stats for each document, using the given number of workers.
+// The results are in the same order as the paths
+func calculateFileStats(paths []string, workers int) []fileStats {
+	results := make([]fileStats, len(paths))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				result := fileStats{path: paths[idx]}
+
+				input, err := os.ReadFile(result.path)
+				if err == nil {
+					var parsed parser.ParseResult
+					parsed, err = parser.Parse([]rune(string(input)))
+					if err == nil {
+						result.stats = parser.CalculateDocumentStats(parsed)
+					}
+				}
+
+				result.err = err
+				results[idx] = result
+			}
+		}()
+	}
+
+	for idx := range paths {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// reportFileStats: Write the stats for each file, the merged stats, and any failures in the requested format.
+// Returns ExitFailure if any file failed
+func reportFileStats(env *Env, results []fileStats, opts statOptions) int {
+	var merged parser.Stats
+	succeeded := make([]fileStats, 0, len(results))
+	failed := make([]fileStats, 0)
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+			continue
+		}
+
+		merged.Merge(result.stats)
+		succeeded = append(succeeded, result)
+	}
+
+	mergedTitle := fmt.Sprintf("Merged (%v of %v files)", len(succeeded), len(results))
+	if opts.mergedOnly {
+		succeeded = nil
+	}
+
+	var err error
+	switch opts.format {
+	case parser.StatsFormatJson:
+		err = writeJsonFileStats(env, succeeded, merged, failed)
+	case parser.StatsFormatCsv:
+		err = writeCsvFileStats(env, succeeded, merged)
+	default:
+		err = writeTextFileStats(env, succeeded, merged, opts.format, mergedTitle)
+	}
+
+	if err != nil {
+		env.Errorf("error occurred rendering stats - %v", err)
+		return ExitFailure
+	}
+
+	// JSON output includes the failures, so they are only logged for the other formats
+	if opts.format != parser.StatsFormatJson {
+		for _, result := range failed {
+			env.Errorf("error occurred parsing %v - %v", result.path, result.err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+func writeJsonFileStats(env *Env, succeeded []fileStats, merged parser.Stats, failed []fileStats) error {
+	type fileJson struct {
+		Path  string          `json:"path"`
+		Stats json.RawMessage `json:"stats"`
+	}
+
+	type failureJson struct {
+		Path  string `json:"path"`
+		Error string `json:"error"`
+	}
+
+	output := struct {
+		Files    []fileJson      `json:"files"`
+		Merged   json.RawMessage `json:"merged"`
+		Failures []failureJson   `json:"failures"`
+	}{Files: make([]fileJson, 0), Failures: make([]failureJson, 0)}
+
+	for _, result := range succeeded {
+		rendered, err := result.stats.RenderFormat(parser.StatsFormatJson)
+		if err != nil {
+			return err
+		}
+		output.Files = append(output.Files, fileJson{Path: result.path, Stats: json.RawMessage(rendered)})
+	}
+
+	rendered, err := merged.RenderFormat(parser.StatsFormatJson)
+	if err != nil {
+		return err
+	}
+	output.Merged = json.RawMessage(rendered)
+
+	for _, result := range failed {
+		output.Failures = append(output.Failures, failureJson{Path: result.path, Error: result.err.Error()})
+	}
+
+	bytes, err := json.MarshalIndent(output, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(env.Stdout, "%s\n", bytes)
+	return err
+}
+
+// writeCsvFileStats: Write CSV stats, with each row prefixed by the file it describes. The merged rows have an empty file
+func writeCsvFileStats(env *Env, succeeded []fileStats, merged parser.Stats) error {
+	writer := csv.NewWriter(env.Stdout)
+	writeRows := func(path string, stats parser.Stats, includeHeader bool) error {
+		rendered, err := stats.RenderFormat(parser.StatsFormatCsv)
+		if err != nil {
+			return err
+		}
+
+		rows, err := csv.NewReader(strings.NewReader(rendered)).ReadAll()
+		if err != nil {
+			return err
+		}
+
+		for idx, row := range rows {
+			if idx == 0 && !includeHeader {
+				continue
+			}
+
+			file := path
+			if idx == 0 {
+				file = "file"
+			}
+
+			if err := writer.Write(append([]string{file}, row...)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := writeRows("", merged, true); err != nil {
+		return err
+	}
+
+	for _, result := range succeeded {
+		if err := writeRows(result.path, result.stats, false); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeTextFileStats: Write text or Markdown stats, with a heading for each file
+func writeTextFileStats(env *Env, succeeded []fileStats, merged parser.Stats, format parser.StatsFormat, mergedTitle string) error {
+	heading := func(title string) string {
+		if format == parser.StatsFormatMarkdown {
+			return fmt.Sprintf("# %v\n\n", title)
+		}
+		return fmt.Sprintf("\n==> %v <==\n", title)
+	}
+
+	for _, result := range succeeded {
+		rendered, err := result.stats.RenderFormat(format)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(env.Stdout, heading(result.path)+rendered+"\n")
+	}
+
+	rendered, err := merged.RenderFormat(format)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(env.Stdout, heading(mergedTitle)+rendered)
+	return err
+}
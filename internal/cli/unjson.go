@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+)
+
+var unjsonCommand = &Command{
+	Name:    "unjson",
+	Summary: "Convert JSON back to a Tag Document",
+	Description: []string{
+		"Convert json in the shape produced by the json command back into a tag document, written to standard out.",
+		"Provide a file with -i (or as an argument), or use -stdin to read your input from standard in.",
+	},
+	Examples: []string{
+		"%prog -i ./test.json > test.html",
+		"tagparser json -i ./test.html | jq '.lang = \"en\"' | %prog -stdin -pretty",
+	},
+	Run: runUnjson,
+}
+
+func runUnjson(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	pretty := fs.Bool("pretty", false, "Indent the output, as the fmt command would. Otherwise the document is written on a single line.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	doc, code, ok := in.readSingle(env, command, fs)
+	if !ok {
+		return code
+	}
+
+	tag, err := parser.FromJson(bytes.NewReader(doc.input))
+	if err != nil {
+		env.Errorf("error occurred reading json from %v - %v", doc.name(), err)
+		return ExitFailure
+	}
+
+	var markup string
+	if *pretty {
+		markup, err = parser.FormatTag(&tag, parser.FormatOptions{})
+	} else {
+		markup, err = parser.SerializeToString(&tag)
+		markup += "\n"
+	}
+
+	if err != nil {
+		env.Errorf("error occurred writing tag document - %v", err)
+		return ExitFailure
+	}
+
+	fmt.Fprint(env.Stdout, markup)
+	return ExitSuccess
+}
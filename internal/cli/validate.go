@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	parser "go-tagparser/pkg/tagparser"
+	"os"
+)
+
+var validateCommand = &Command{
+	Name:    "validate",
+	Summary: "Tag Document Schema Validator",
+	Description: []string{
+		"Check that tag documents match a schema, writing each error to standard out as file:line:col: path: message.",
+		"Provide the files to check as arguments (or with -i), or use -stdin to read your input from standard in.",
+		"The -schema file is itself a tag document, declaring the attributes, text and children of each element.",
+		"The exit code is 1 when any document is invalid, and 2 when the schema or a document could not be read or parsed.",
+	},
+	Examples: []string{
+		"%prog -schema=config.schema ./config.xml",
+		"%prog -schema=config.schema -ext=.xml ./configs",
+		"echo \"<config version='2'/>\" | %prog -schema=config.schema -stdin",
+	},
+	Run: runValidate,
+}
+
+func runValidate(env *Env, command *Command, args []string) int {
+	fs := newFlagSet(env)
+	in := addInputFlags(fs)
+	schemaPath := fs.String("schema", "", "Read the schema from this tag document. Required.")
+	extensions := fs.String("ext", ".html,.htm,.xml", "A comma separated list of the file extensions read from directories.")
+	if code, ok := parseFlags(env, command, fs, args); !ok {
+		return code
+	}
+
+	if *schemaPath == "" {
+		return usageError(env, command, fs, "you must provide the -schema to validate against")
+	}
+
+	input, err := os.ReadFile(*schemaPath)
+	if err != nil {
+		env.Errorf("%v", err)
+		return ExitError
+	}
+
+	schema, err := parser.ParseSchema([]rune(string(input)))
+	if err != nil {
+		env.Errorf("%v: %v", *schemaPath, err)
+		return ExitError
+	}
+
+	if in.count(fs) == 0 {
+		return usageError(env, command, fs, "you must provide the files to check, or -stdin to read from standard in")
+	}
+
+	if err := in.expand(fs, *extensions); err != nil {
+		env.Errorf("%v", err)
+		return ExitError
+	}
+
+	invalid, unparsable := false, false
+	code := in.forEachDocument(env, fs, func(doc document) error {
+		count, parsed, err := validateDocument(env, doc, schema)
+		invalid = invalid || count > 0
+		unparsable = unparsable || !parsed
+		return err
+	})
+
+	switch {
+	case code != ExitSuccess || unparsable:
+		return ExitError
+	case invalid:
+		return ExitFailure
+	}
+	return ExitSuccess
+}
+
+// validateDocument: Write the validation errors of a single document, returning the number of errors and whether the
+// document could be parsed. Parse errors are reported as an error, as lint does
+func validateDocument(env *Env, doc document, schema *parser.Schema) (int, bool, error) {
+	result, err := parser.Parse([]rune(string(doc.input)))
+	if err != nil {
+		var parseError *parser.ParseError
+		if !errors.As(err, &parseError) {
+			return 0, false, err
+		}
+
+		position := result.LineIndex().Position(parseError.StartIdx)
+		fmt.Fprintf(env.Stdout, "%v:%v:%v: %v (parse-error)\n", doc.name(), position.Line, position.Column, parseError.Reason)
+		return 1, false, nil
+	}
+
+	validationErrors := parser.Validate(result, schema)
+	for _, validationError := range validationErrors {
+		position := validationError.Position
+		fmt.Fprintf(env.Stdout, "%v:%v:%v: %v: %v\n", doc.name(), position.Line, position.Column, validationError.Path, validationError.Message)
+	}
+	return len(validationErrors), true, nil
+}
@@ -0,0 +1,403 @@
+package tagparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// EditOp is the kind of change made by an Edit
+type EditOp int
+
+const (
+	// A tag (and it's descendants) was added
+	EditInsert EditOp = iota
+	// A tag (and it's descendants) was removed
+	EditDelete
+	// A tag was moved to another position among it's siblings
+	EditMove
+	EditAddAttribute
+	EditRemoveAttribute
+	EditChangeAttribute
+	// The content of a text tag changed
+	EditChangeText
+)
+
+var editOpNames = []string{"insert", "delete", "move", "add-attribute", "remove-attribute", "change-attribute", "change-text"}
+
+func (op EditOp) String() string {
+	if op < 0 || int(op) >= len(editOpNames) {
+		return fmt.Sprintf("EditOp(%d)", int(op))
+	}
+	return editOpNames[op]
+}
+
+// Edit is a single change within an edit script produced by Diff.
+//
+// Paths are XPath expressions (which can be used with XPathSelect), i.e. /html/body/p[2]. Position predicates are
+// only included when a tag has siblings of the same name. OldPath locates the tag in the old tree, and NewPath in the
+// new tree. Inserts only have a NewPath and deletes only have an OldPath.
+type Edit struct {
+	Op      EditOp
+	OldPath string
+	NewPath string
+	// The inserted or deleted tag
+	Tag *Tag
+	// The name of the attribute, for attribute edits
+	Attribute string
+	// The previous and current attribute value or text
+	OldValue string
+	NewValue string
+}
+
+func (e Edit) String() string {
+	switch e.Op {
+	case EditInsert:
+		return fmt.Sprintf("+ %v: %v", e.NewPath, summarizeTag(e.Tag))
+	case EditDelete:
+		return fmt.Sprintf("- %v: %v", e.OldPath, summarizeTag(e.Tag))
+	case EditMove:
+		return fmt.Sprintf("> %v -> %v", e.OldPath, e.NewPath)
+	case EditAddAttribute:
+		return fmt.Sprintf("~ %v/@%v: added %q", e.NewPath, e.Attribute, e.NewValue)
+	case EditRemoveAttribute:
+		return fmt.Sprintf("~ %v/@%v: removed %q", e.NewPath, e.Attribute, e.OldValue)
+	case EditChangeAttribute:
+		return fmt.Sprintf("~ %v/@%v: %q -> %q", e.NewPath, e.Attribute, e.OldValue, e.NewValue)
+	case EditChangeText:
+		return fmt.Sprintf("~ %v: %q -> %q", e.NewPath, e.OldValue, e.NewValue)
+	}
+	return e.Op.String()
+}
+
+// MarshalJSON: Write the edit as a JSON Patch (RFC 6902) style operation, using the edit's XPath paths.
+// Inserts are "add" operations with the inserted tag as the value, and attributes are addressed as path/@name
+func (e Edit) MarshalJSON() ([]byte, error) {
+	type patchJson struct {
+		Op   string `json:"op"`
+		From string `json:"from,omitempty"`
+		Path string `json:"path"`
+		// A raw message, so empty strings are still written
+		Value json.RawMessage `json:"value,omitempty"`
+	}
+
+	var patch patchJson
+	var value any
+	switch e.Op {
+	case EditInsert:
+		patch, value = patchJson{Op: "add", Path: e.NewPath}, e.Tag
+	case EditDelete:
+		patch = patchJson{Op: "remove", Path: e.OldPath}
+	case EditMove:
+		patch = patchJson{Op: "move", From: e.OldPath, Path: e.NewPath}
+	case EditAddAttribute:
+		patch, value = patchJson{Op: "add", Path: e.NewPath + "/@" + e.Attribute}, e.NewValue
+	case EditRemoveAttribute:
+		patch = patchJson{Op: "remove", Path: e.NewPath + "/@" + e.Attribute}
+	case EditChangeAttribute:
+		patch, value = patchJson{Op: "replace", Path: e.NewPath + "/@" + e.Attribute}, e.NewValue
+	case EditChangeText:
+		patch, value = patchJson{Op: "replace", Path: e.NewPath}, e.NewValue
+	default:
+		return nil, fmt.Errorf("unknown edit op %v", e.Op)
+	}
+
+	if value != nil {
+		var err error
+		if patch.Value, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(patch)
+}
+
+// Diff: Find the edit script which turns the tree a into the tree b. Either tree may be nil.
+//
+// Only the structure of the trees is compared, so formatting-only differences are ignored: Positions, the order of
+// attributes and runs of whitespace within text are not significant. Children are matched by their name (and id
+// attribute), and moves are found among the siblings of a tag. A tag whose name changed is deleted and re-inserted.
+//
+// The edits for a tag are its attribute changes, then the deletion of it's children, followed by the insertions, moves
+// and edits of it's children in the order they appear in b. The script is empty exactly when the trees are Equal (with
+// the zero EqualOptions).
+func Diff(a, b *Tag) []Edit {
+	d := &differ{edits: make([]Edit, 0), keys: map[*Tag]string{}}
+
+	switch {
+	case a == nil && b == nil:
+	case a == nil:
+		d.edits = append(d.edits, Edit{Op: EditInsert, NewPath: "/" + pathStep(b, 1, 1), Tag: b})
+	case b == nil:
+		d.edits = append(d.edits, Edit{Op: EditDelete, OldPath: "/" + pathStep(a, 1, 1), Tag: a})
+	case a.Name != b.Name:
+		d.edits = append(d.edits,
+			Edit{Op: EditDelete, OldPath: "/" + pathStep(a, 1, 1), Tag: a},
+			Edit{Op: EditInsert, NewPath: "/" + pathStep(b, 1, 1), Tag: b})
+	default:
+		d.diffTags(a, b, "/"+pathStep(a, 1, 1), "/"+pathStep(b, 1, 1))
+	}
+
+	return d.edits
+}
+
+type differ struct {
+	edits []Edit
+	// The cached subtree keys of each tag
+	keys map[*Tag]string
+}
+
+// diffTags: Add the edits between two matching tags - tags of the same name, or two text tags
+func (d *differ) diffTags(a, b *Tag, oldPath, newPath string) {
+	if a.Name == TextTagName {
+		oldText, newText := a.Attributes[TextAttributeName], b.Attributes[TextAttributeName]
+		if !Equal(a, b, EqualOptions{}) {
+			d.edits = append(d.edits, Edit{Op: EditChangeText, OldPath: oldPath, NewPath: newPath, OldValue: oldText, NewValue: newText})
+		}
+		return
+	}
+
+	d.diffAttributes(a, b, oldPath, newPath)
+	d.diffChildren(a.Children, b.Children, oldPath, newPath)
+}
+
+func (d *differ) diffAttributes(a, b *Tag, oldPath, newPath string) {
+	names := make([]string, 0, len(a.Attributes)+len(b.Attributes))
+	for name := range a.Attributes {
+		names = append(names, name)
+	}
+	for name := range b.Attributes {
+		if _, ok := a.Attributes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldValue, inOld := a.Attributes[name]
+		newValue, inNew := b.Attributes[name]
+		edit := Edit{OldPath: oldPath, NewPath: newPath, Attribute: name, OldValue: oldValue, NewValue: newValue}
+		switch {
+		case !inOld:
+			edit.Op = EditAddAttribute
+		case !inNew:
+			edit.Op = EditRemoveAttribute
+		case oldValue != newValue:
+			edit.Op = EditChangeAttribute
+		default:
+			continue
+		}
+		d.edits = append(d.edits, edit)
+	}
+}
+
+// diffChildren: Match the old and new children, and add the edits between them.
+//
+// Identical subtrees are matched first (using the longest common subsequence), then the gaps between them are matched
+// by name. Any remaining identical subtrees (or tags with the same name and id) have moved, and the rest were deleted
+// or inserted
+func (d *differ) diffChildren(olds, news []Tag, oldPath, newPath string) {
+	oldSteps, newSteps := pathSteps(olds), pathSteps(news)
+	oldMatch, newMatch := make([]int, len(olds)), make([]int, len(news))
+	for idx := range oldMatch {
+		oldMatch[idx] = -1
+	}
+	for idx := range newMatch {
+		newMatch[idx] = -1
+	}
+
+	oldKeys, newKeys := make([]string, len(olds)), make([]string, len(news))
+	for idx := range olds {
+		oldKeys[idx] = d.subtreeKey(&olds[idx])
+	}
+	for idx := range news {
+		newKeys[idx] = d.subtreeKey(&news[idx])
+	}
+
+	match := func(oldIdx, newIdx int) {
+		oldMatch[oldIdx], newMatch[newIdx] = newIdx, oldIdx
+	}
+
+	anchors := longestCommonSubsequence(oldKeys, newKeys)
+	for _, pair := range anchors {
+		match(pair[0], pair[1])
+	}
+
+	// Match the gaps between (and around) the identical subtrees by name
+	oldStart, newStart := 0, 0
+	for _, pair := range append(anchors, [2]int{len(olds), len(news)}) {
+		gapOlds, gapNews := make([]string, 0), make([]string, 0)
+		for idx := oldStart; idx < pair[0]; idx++ {
+			gapOlds = append(gapOlds, shallowKey(&olds[idx]))
+		}
+		for idx := newStart; idx < pair[1]; idx++ {
+			gapNews = append(gapNews, shallowKey(&news[idx]))
+		}
+
+		for _, gapPair := range longestCommonSubsequence(gapOlds, gapNews) {
+			match(oldStart+gapPair[0], newStart+gapPair[1])
+		}
+		oldStart, newStart = pair[0]+1, pair[1]+1
+	}
+
+	// Find moves among the unmatched tags
+	moved := make([]bool, len(news))
+	for newIdx := range news {
+		if newMatch[newIdx] != -1 {
+			continue
+		}
+
+		for oldIdx := range olds {
+			if oldMatch[oldIdx] != -1 {
+				continue
+			}
+
+			sameId := news[newIdx].Attributes["id"] != "" && shallowKey(&olds[oldIdx]) == shallowKey(&news[newIdx])
+			if oldKeys[oldIdx] == newKeys[newIdx] || sameId {
+				match(oldIdx, newIdx)
+				moved[newIdx] = true
+				break
+			}
+		}
+	}
+
+	for oldIdx := range olds {
+		if oldMatch[oldIdx] == -1 {
+			d.edits = append(d.edits, Edit{Op: EditDelete, OldPath: oldPath + "/" + oldSteps[oldIdx], Tag: &olds[oldIdx]})
+		}
+	}
+
+	for newIdx := range news {
+		childNewPath := newPath + "/" + newSteps[newIdx]
+		oldIdx := newMatch[newIdx]
+		if oldIdx == -1 {
+			d.edits = append(d.edits, Edit{Op: EditInsert, NewPath: childNewPath, Tag: &news[newIdx]})
+			continue
+		}
+
+		childOldPath := oldPath + "/" + oldSteps[oldIdx]
+		if moved[newIdx] {
+			d.edits = append(d.edits, Edit{Op: EditMove, OldPath: childOldPath, NewPath: childNewPath})
+		}
+
+		if oldKeys[oldIdx] != newKeys[newIdx] {
+			d.diffTags(&olds[oldIdx], &news[newIdx], childOldPath, childNewPath)
+		}
+	}
+}
+
+// subtreeKey: The canonical form of a subtree, which is equal for two subtrees when Diff would find no edits between
+// them. Subtrees which can't be canonicalized (i.e. text tags without text) are never identical to another subtree
+func (d *differ) subtreeKey(tag *Tag) string {
+	if key, ok := d.keys[tag]; ok {
+		return key
+	}
+
+	key, err := Canonicalize(tag)
+	if err != nil {
+		key = fmt.Sprintf("%p: %v", tag, err)
+	}
+
+	d.keys[tag] = key
+	return key
+}
+
+// shallowKey: The key used to match tags which aren't identical - Their name, and id if they have one
+func shallowKey(tag *Tag) string {
+	if id, ok := tag.Attributes["id"]; ok && tag.Name != TextTagName {
+		return strconv.Quote(tag.Name) + "#" + strconv.Quote(id)
+	}
+	return strconv.Quote(tag.Name)
+}
+
+// longestCommonSubsequence: The index pairs of the longest common subsequence of two lists, in order
+func longestCommonSubsequence(a, b []string) [][2]int {
+	// lengths[i][j] is the length of the LCS of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	pairs := make([][2]int, 0, lengths[0][0])
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i += 1
+		default:
+			j += 1
+		}
+	}
+	return pairs
+}
+
+// pathSteps: The XPath step of each child. Positions are only included for names shared by several siblings. Nameless
+// tags are written as *, so their positions count every sibling element, as * matches any element
+func pathSteps(children []Tag) []string {
+	counts := map[string]int{}
+	elements := 0
+	for idx := range children {
+		counts[children[idx].Name] += 1
+		if children[idx].Name != TextTagName {
+			elements += 1
+		}
+	}
+
+	positions := map[string]int{}
+	element := 0
+	steps := make([]string, len(children))
+	for idx := range children {
+		name := children[idx].Name
+		positions[name] += 1
+		if name != TextTagName {
+			element += 1
+		}
+
+		if name == "" {
+			steps[idx] = pathStep(&children[idx], element, elements)
+		} else {
+			steps[idx] = pathStep(&children[idx], positions[name], counts[name])
+		}
+	}
+	return steps
+}
+
+func pathStep(tag *Tag, position int, count int) string {
+	step := tag.Name
+	switch tag.Name {
+	case TextTagName:
+		step = "text()"
+	case "":
+		step = "*"
+	}
+
+	if count > 1 {
+		step += "[" + strconv.Itoa(position) + "]"
+	}
+	return step
+}
+
+// summarizeTag: The markup of a tag, shortened to a single line for readable output
+func summarizeTag(tag *Tag) string {
+	markup, err := SerializeToString(tag)
+	if err != nil {
+		return "<" + tag.Name + ">"
+	}
+
+	markup = normalizeSpace(markup)
+	if runes := []rune(markup); len(runes) > 80 {
+		markup = string(runes[:77]) + "..."
+	}
+	return markup
+}
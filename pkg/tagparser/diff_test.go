@@ -0,0 +1,176 @@
+package tagparser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func diffDocuments(t *testing.T, old, new string) []string {
+	t.Helper()
+	a, err := Parse([]rune(old))
+	if err != nil {
+		t.Fatalf("Failed to parse %q - %v", old, err)
+	}
+	b, err := Parse([]rune(new))
+	if err != nil {
+		t.Fatalf("Failed to parse %q - %v", new, err)
+	}
+
+	got := make([]string, 0)
+	for _, edit := range Diff(&a.Root, &b.Root) {
+		got = append(got, edit.String())
+	}
+	return got
+}
+
+func TestDiff_IgnoresFormatting(t *testing.T) {
+	old := `<ul class="a" id="list"><li>One  item</li><li>Two</li></ul>`
+	new := "<ul id='list'   class='a'>\n    <li>One\n item</li>\n    <li>Two</li>\n</ul>"
+	if got := diffDocuments(t, old, new); len(got) != 0 {
+		t.Errorf("Diff should ignore formatting-only differences. Got %v", got)
+	}
+}
+
+func TestDiff_Edits(t *testing.T) {
+	cases := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{
+			"insert",
+			`<ul><li>A</li><li>C</li></ul>`,
+			`<ul><li>A</li><li>B</li><li>C</li></ul>`,
+			[]string{`+ /ul/li[2]: <li>B</li>`},
+		},
+		{
+			"delete",
+			`<div><p>Hi</p><br/></div>`,
+			`<div><p>Hi</p></div>`,
+			[]string{`- /div/br: <br />`},
+		},
+		{
+			"attributes",
+			`<a href="/old" title="t">Link</a>`,
+			`<a href="/new" target="_blank">Link</a>`,
+			[]string{
+				`~ /a/@href: "/old" -> "/new"`,
+				`~ /a/@target: added "_blank"`,
+				`~ /a/@title: removed "t"`,
+			},
+		},
+		{
+			"text",
+			`<p>Hello <b>there</b></p>`,
+			`<p>Goodbye <b>there</b></p>`,
+			[]string{`~ /p/text(): "Hello" -> "Goodbye"`},
+		},
+		{
+			"move",
+			`<ul><li>A</li><li>B</li><li>C</li></ul>`,
+			`<ul><li>C</li><li>A</li><li>B</li></ul>`,
+			[]string{`> /ul/li[3] -> /ul/li[1]`},
+		},
+		{
+			"move by id",
+			`<div><section id="a"><p>1</p></section><section id="b"/></div>`,
+			`<div><section id="b"/><section id="a"><p>2</p></section></div>`,
+			[]string{
+				`> /div/section[1] -> /div/section[2]`,
+				`~ /div/section[2]/p/text(): "1" -> "2"`,
+			},
+		},
+		{
+			"renamed root",
+			`<old/>`,
+			`<new/>`,
+			[]string{`- /old: <old />`, `+ /new: <new />`},
+		},
+	}
+
+	for _, c := range cases {
+		got := diffDocuments(t, c.old, c.new)
+		if diff := cmp.Diff(c.want, got); diff != "" {
+			t.Errorf("%v: Diff returned the wrong edits (-want +got):\n%v", c.name, diff)
+		}
+	}
+}
+
+func TestDiff_NilTrees(t *testing.T) {
+	tag := &Tag{Name: "div"}
+	if got := Diff(nil, nil); len(got) != 0 {
+		t.Errorf("Diff of nil trees. Got %v", got)
+	}
+
+	want := []Edit{{Op: EditInsert, NewPath: "/div", Tag: tag}}
+	if got := Diff(nil, tag); !cmp.Equal(got, want) {
+		t.Errorf("Diff from a nil tree. Got %v Want %v", got, want)
+	}
+}
+
+func TestEdit_MarshalJSON(t *testing.T) {
+	edits := []Edit{
+		{Op: EditInsert, NewPath: "/ul/li[2]", Tag: &Tag{Name: "li"}},
+		{Op: EditDelete, OldPath: "/ul/li[3]"},
+		{Op: EditMove, OldPath: "/ul/li[3]", NewPath: "/ul/li[1]"},
+		{Op: EditChangeAttribute, NewPath: "/ul", Attribute: "class", OldValue: "a", NewValue: ""},
+		{Op: EditChangeText, NewPath: "/ul/li/text()", NewValue: "Hi"},
+	}
+
+	got, err := json.Marshal(edits)
+	if err != nil {
+		t.Fatalf("Failed to marshal edits - %v", err)
+	}
+
+	want := `[{"op":"add","path":"/ul/li[2]","value":{"_name":"li"}},` +
+		`{"op":"remove","path":"/ul/li[3]"},` +
+		`{"op":"move","from":"/ul/li[3]","path":"/ul/li[1]"},` +
+		`{"op":"replace","path":"/ul/@class","value":""},` +
+		`{"op":"replace","path":"/ul/li/text()","value":"Hi"}]`
+	if string(got) != want {
+		t.Errorf("Edit.MarshalJSON. Got %v Want %v", string(got), want)
+	}
+}
+
+func TestDiff_PathsSelectTheEditedTag(t *testing.T) {
+	old, _ := Parse([]rune(`<div><p>A</p><p>B</p></div>`))
+	new, _ := Parse([]rune(`<div><p>A</p><p class="x">B</p></div>`))
+
+	edits := Diff(&old.Root, &new.Root)
+	if len(edits) != 1 {
+		t.Fatalf("Diff. Got %v, want a single edit", edits)
+	}
+
+	nodes, err := XPathSelect(&new.Root, edits[0].NewPath)
+	if err != nil || len(nodes) != 1 || nodes[0].Tag != &new.Root.Children[1] {
+		t.Errorf("The edit path %v should select the edited tag. Got %v %v", edits[0].NewPath, nodes, err)
+	}
+}
+
+func TestDiff_NamelessPathsCountEveryElement(t *testing.T) {
+	result, err := Parse([]rune(`<div>Text<p>A</p><>X</><p>B</p><>Y</></div>`))
+	if err != nil {
+		t.Fatalf("Failed to parse - %v", err)
+	}
+
+	got := pathSteps(result.Root.Children)
+	want := []string{"text()", "p[1]", "*[2]", "p[2]", "*[4]"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("pathSteps mismatch (-want +got):\n%s", diff)
+	}
+
+	old, _ := Parse([]rune(`<div><p>A</p><>X</><p>B</p><>Y</></div>`))
+	new, _ := Parse([]rune(`<div><p>A</p><>X</><p>B</p><>Z</></div>`))
+	edits := Diff(&old.Root, &new.Root)
+	if len(edits) != 1 || edits[0].NewPath != "/div/*[4]/text()" {
+		t.Fatalf("Diff. Got %v, want a single edit of /div/*[4]/text()", edits)
+	}
+
+	nodes, err := XPathSelect(&new.Root, edits[0].NewPath)
+	if err != nil || len(nodes) != 1 || nodes[0].Tag != &new.Root.Children[3].Children[0] {
+		t.Errorf("The edit path %v should select the edited tag. Got %v %v", edits[0].NewPath, nodes, err)
+	}
+}
@@ -0,0 +1,126 @@
+package tagparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// EqualOptions configures which differences Equal ignores. The zero value compares the structure of two trees -
+// Names, attributes, text and children - ignoring positions and runs of whitespace within text.
+// Attribute order is never significant, as a Tag's attributes are unordered. Depth is derived from the structure, so is
+// not compared either.
+type EqualOptions struct {
+	// Compare StartIdx and EndIdx
+	ComparePositions bool
+	// Compare text exactly, rather than collapsing runs of whitespace (and ignoring leading and trailing whitespace)
+	ExactText bool
+	// Compare tag names case-insensitively
+	IgnoreCase bool
+	// Attributes which aren't compared, i.e. ["style"]
+	IgnoreAttributes []string
+}
+
+// Equal: Check if two trees are equivalent. Two nil tags are equal
+func Equal(a, b *Tag, opts EqualOptions) bool {
+	ignored := make(map[string]bool, len(opts.IgnoreAttributes))
+	for _, name := range opts.IgnoreAttributes {
+		ignored[name] = true
+	}
+
+	pairs := [][2]*Tag{{a, b}}
+	for len(pairs) > 0 {
+		a, b := pairs[len(pairs)-1][0], pairs[len(pairs)-1][1]
+		pairs = pairs[:len(pairs)-1]
+
+		switch {
+		case a == nil || b == nil:
+			if a != b {
+				return false
+			}
+			continue
+		case opts.IgnoreCase && !strings.EqualFold(a.Name, b.Name):
+			return false
+		case !opts.IgnoreCase && a.Name != b.Name:
+			return false
+		case opts.ComparePositions && (a.StartIdx != b.StartIdx || a.EndIdx != b.EndIdx):
+			return false
+		case len(a.Children) != len(b.Children):
+			return false
+		}
+
+		if a.Name == TextTagName {
+			aText, bText := a.Attributes[TextAttributeName], b.Attributes[TextAttributeName]
+			if !opts.ExactText {
+				aText, bText = normalizeSpace(aText), normalizeSpace(bText)
+			}
+
+			if aText != bText {
+				return false
+			}
+		} else if !equalAttributes(a.Attributes, b.Attributes, ignored) {
+			return false
+		}
+
+		for idx := range a.Children {
+			pairs = append(pairs, [2]*Tag{&a.Children[idx], &b.Children[idx]})
+		}
+	}
+
+	return true
+}
+
+func equalAttributes(a, b map[string]string, ignored map[string]bool) bool {
+	for name, value := range a {
+		if ignored[name] {
+			continue
+		}
+
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+
+	for name := range b {
+		if _, ok := a[name]; !ok && !ignored[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteCanonical: Write the canonical form of a tree - Markup which is the same for any two trees Equal with the zero
+// EqualOptions, making it suitable for hashing and caching.
+//
+// The canonical form is the same as Serialize (attributes in name order, consistent quotation, no whitespace between
+// tags and self-closing tags for elements without children), with runs of whitespace within text collapsed into a
+// single space. So that different trees never share a canonical form, every & in text and attribute values is
+// written as &amp;, and adjacent text tags are separated by &;. The canonical form of a parsed document is therefore
+// not always the same as it's markup (i.e. &lt; is written as &amp;lt;)
+func WriteCanonical(w io.Writer, tag *Tag) error {
+	return serialize(w, tag, true)
+}
+
+// Canonicalize: The canonical form of a tree as a string. See WriteCanonical
+func Canonicalize(tag *Tag) (string, error) {
+	var sb strings.Builder
+	if err := WriteCanonical(&sb, tag); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// CanonicalHash: The hex encoded SHA-256 hash of the canonical form of a tree. See WriteCanonical
+func CanonicalHash(tag *Tag) (string, error) {
+	hash := sha256.New()
+	if err := WriteCanonical(hash, tag); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// normalizeSpace: Collapse runs of whitespace into a single space, as XPath's normalize-space does
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,137 @@
+package tagparser
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, document string) *Tag {
+	t.Helper()
+	result, err := Parse([]rune(document))
+	if err != nil {
+		t.Fatalf("Failed to parse %q - %v", document, err)
+	}
+	return &result.Root
+}
+
+func TestEqual_IgnoresFormatting(t *testing.T) {
+	a := mustParse(t, `<div id="a" class='b'><p>Hello   World</p><br/></div>`)
+	b := mustParse(t, "<div class=\"b\" id=\"a\">\n    <p>Hello\n    World</p>\n    <br></br>\n</div>")
+
+	if !Equal(a, b, EqualOptions{}) {
+		t.Errorf("Equal should ignore formatting-only differences")
+	}
+	if Equal(a, b, EqualOptions{ComparePositions: true}) {
+		t.Errorf("Equal with ComparePositions should compare StartIdx and EndIdx")
+	}
+	if Equal(a, b, EqualOptions{ExactText: true}) {
+		t.Errorf("Equal with ExactText should compare whitespace within text")
+	}
+}
+
+func TestEqual_Options(t *testing.T) {
+	cases := []struct {
+		name string
+		a    string
+		b    string
+		opts EqualOptions
+		want bool
+	}{
+		{"different text", `<p>Hi</p>`, `<p>Bye</p>`, EqualOptions{}, false},
+		{"different attribute", `<p class="a"/>`, `<p class="b"/>`, EqualOptions{}, false},
+		{"missing attribute", `<p class="a"/>`, `<p/>`, EqualOptions{}, false},
+		{"extra child", `<p><b/></p>`, `<p><b/><i/></p>`, EqualOptions{}, false},
+		{"different case", `<DIV><P/></DIV>`, `<div><p/></div>`, EqualOptions{}, false},
+		{"ignore case", `<DIV><P/></DIV>`, `<div><p/></div>`, EqualOptions{IgnoreCase: true}, true},
+		{"ignore attributes", `<p style="x" class="a"/>`, `<p class="a"/>`, EqualOptions{IgnoreAttributes: []string{"style"}}, true},
+	}
+
+	for _, c := range cases {
+		if got := Equal(mustParse(t, c.a), mustParse(t, c.b), c.opts); got != c.want {
+			t.Errorf("%v: Equal(%v, %v) = %v, want %v", c.name, c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestEqual_NilTags(t *testing.T) {
+	if !Equal(nil, nil, EqualOptions{}) {
+		t.Errorf("Two nil tags should be equal")
+	}
+	if Equal(&Tag{Name: "a"}, nil, EqualOptions{}) {
+		t.Errorf("A tag should not equal nil")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	a := mustParse(t, "<a title='x' href=\"/\">\n  Hello,\n  World  <b></b>\n</a>")
+	b := mustParse(t, `<a href="/" title="x">Hello, World<b /></a>`)
+
+	got, err := Canonicalize(a)
+	want := `<a href="/" title="x">Hello, World<b /></a>`
+	if err != nil || got != want {
+		t.Errorf("Canonicalize. Got %v (%v) Want %v", got, err, want)
+	}
+
+	hashA, errA := CanonicalHash(a)
+	hashB, errB := CanonicalHash(b)
+	if errA != nil || errB != nil || hashA != hashB || len(hashA) != 64 {
+		t.Errorf("Equal trees should have the same canonical hash. Got %v (%v) and %v (%v)", hashA, errA, hashB, errB)
+	}
+
+	c := mustParse(t, `<a href="/" title="y">Hello, World<b /></a>`)
+	if hashC, _ := CanonicalHash(c); hashC == hashA {
+		t.Errorf("Different trees should have different canonical hashes")
+	}
+}
+
+func TestCanonicalize_MatchesEqual(t *testing.T) {
+	documents := []string{
+		`<p>Hi <b>there</b></p>`,
+		"<p>Hi\n<b>there</b></p>",
+		`<p>Hi <b class="x">there</b></p>`,
+		`<p><b>there</b>Hi</p>`,
+	}
+
+	for i, first := range documents {
+		for _, second := range documents[i:] {
+			a, b := mustParse(t, first), mustParse(t, second)
+			canonicalA, _ := Canonicalize(a)
+			canonicalB, _ := Canonicalize(b)
+			if (canonicalA == canonicalB) != Equal(a, b, EqualOptions{}) {
+				t.Errorf("Canonicalize and Equal disagree for %q and %q", first, second)
+			}
+		}
+	}
+}
+
+func TestCanonicalize_DoesNotCollide(t *testing.T) {
+	withAttr := func(value string) Tag {
+		tag := NewElement("a")
+		tag.SetAttr("title", value)
+		return tag
+	}
+
+	pairs := map[string][2]Tag{
+		"escaped text":        {NewElement("p", NewText("<")), NewElement("p", NewText("&lt;"))},
+		"escaped ampersand":   {NewElement("p", NewText("&")), NewElement("p", NewText("&amp;"))},
+		"escaped attribute":   {withAttr(`'"`), withAttr(`'&quot;`)},
+		"escaped apostrophe":  {withAttr(`'"`), withAttr(`&#39;"`)},
+		"adjacent text":       {NewElement("p", NewText("a"), NewText("b")), NewElement("p", NewText("ab"))},
+		"empty adjacent text": {NewElement("p", NewText("a"), NewText("")), NewElement("p", NewText("a"))},
+	}
+
+	for name, pair := range pairs {
+		a, b := pair[0], pair[1]
+		if Equal(&a, &b, EqualOptions{}) {
+			t.Fatalf("%v: expected the trees to differ", name)
+		}
+
+		hashA, errA := CanonicalHash(&a)
+		hashB, errB := CanonicalHash(&b)
+		if errA != nil || errB != nil || hashA == hashB {
+			t.Errorf("%v: different trees should have different canonical hashes. Got %v (%v) and %v (%v)", name, hashA, errA, hashB, errB)
+		}
+		if edits := Diff(&a, &b); len(edits) == 0 {
+			t.Errorf("%v: Diff of different trees should find an edit", name)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package tagparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FormatOptions controls the layout produced by Format and FormatTag
+type FormatOptions struct {
+	// The string used for each level of indentation. Defaults to four spaces
+	Indent string
+	// Opening tags wider than this (in runes, including indentation) have each attribute wrapped onto it's own line.
+	// 0 disables wrapping
+	MaxWidth int
+	// Write attributes in name order, rather than the order they appear in the source document
+	SortAttributes bool
+	// The preferred attribute quotation, either " or '. Defaults to ".
+	// Values containing the preferred quotation (but not the alternative) use the alternative instead
+	Quote rune
+}
+
+// Format: Parse a tag document and write it out with a consistent layout.
+// Parse errors are returned unchanged, so they can be reported against the input.
+//
+// Note that:
+// - Each element is written on it's own line, indented by it's depth
+// - Elements containing text are written on a single line, so inline text runs are preserved.
+// // Whether there was whitespace between the text and neighbouring tags is kept from the source
+// - Elements without children are written as self-closing tags
+// - Formatting an already formatted document does not change it
+func Format(document []rune, opts FormatOptions) (string, error) {
+	result, err := Parse(document)
+	if err != nil {
+		return "", err
+	}
+
+	return formatTag(&result.Root, result.Document, opts)
+}
+
+// FormatTag: Write a tree built or modified in code with the same layout as Format.
+// Without a source document, unsorted attributes fall back to name order and inline tags are separated by a single space.
+func FormatTag(tag *Tag, opts FormatOptions) (string, error) {
+	return formatTag(tag, nil, opts)
+}
+
+type formatter struct {
+	opts     FormatOptions
+	document []rune
+	sw       *stickyWriter
+}
+
+type formatFrame struct {
+	tag *Tag
+	// The index of the next child to be visited
+	next int
+	// Whether the children are being written on a single line
+	inline bool
+}
+
+func formatTag(tag *Tag, document []rune, opts FormatOptions) (string, error) {
+	if opts.Indent == "" {
+		opts.Indent = "    "
+	}
+
+	if opts.Quote == 0 {
+		opts.Quote = '"'
+	}
+
+	if opts.Quote != '"' && opts.Quote != '\'' {
+		return "", fmt.Errorf("invalid quotation %q. must be \" or '", opts.Quote)
+	}
+
+	var sb strings.Builder
+	f := &formatter{opts: opts, document: document, sw: &stickyWriter{w: &sb}}
+
+	frames := make([]formatFrame, 0)
+
+	enter := func(t *Tag) error {
+		inline := false
+		var previous *Tag
+		if len(frames) > 0 {
+			parent := &frames[len(frames)-1]
+			inline = parent.inline
+			if parent.next > 0 {
+				previous = &parent.tag.Children[parent.next-1]
+			}
+			parent.next += 1
+		}
+
+		if inline {
+			if previous != nil && f.hasGap(previous, t) {
+				f.sw.WriteString(" ")
+			}
+		} else {
+			f.sw.WriteString(strings.Repeat(opts.Indent, len(frames)))
+		}
+
+		if t.Name == TextTagName {
+			text, ok := t.Attributes[TextAttributeName]
+			if !ok {
+				return fmt.Errorf("text tag is missing it's required text. cannot format")
+			}
+
+			f.sw.WriteString(escapeText(text))
+			return SkipChildren
+		}
+
+		if err := f.writeOpeningTag(t, len(frames), inline); err != nil {
+			return err
+		}
+
+		childrenInline := inline
+		if len(t.Children) == 0 {
+			f.sw.WriteString(" />")
+		} else {
+			f.sw.WriteString(">")
+
+			for _, child := range t.Children {
+				if child.Name == TextTagName {
+					childrenInline = true
+				}
+			}
+
+			if !childrenInline {
+				f.sw.WriteString("\n")
+			}
+		}
+
+		frames = append(frames, formatFrame{tag: t, inline: childrenInline})
+		return f.sw.err
+	}
+
+	exit := func(t *Tag) error {
+		if t.Name != TextTagName {
+			frame := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+
+			if len(t.Children) > 0 {
+				if !frame.inline {
+					f.sw.WriteString(strings.Repeat(opts.Indent, len(frames)))
+				}
+				writeClosingTag(f.sw, t)
+			}
+		}
+
+		if len(frames) == 0 || !frames[len(frames)-1].inline {
+			f.sw.WriteString("\n")
+		}
+		return f.sw.err
+	}
+
+	if err := WalkEnterExit(tag, enter, exit); err != nil {
+		return "", err
+	}
+
+	return sb.String(), f.sw.err
+}
+
+// writeOpeningTag: Write the opening tag, wrapping the attributes when it would be too wide
+func (f *formatter) writeOpeningTag(tag *Tag, level int, inline bool) error {
+	keys := f.attributeOrder(tag)
+
+	if !inline && f.opts.MaxWidth > 0 && len(keys) > 0 {
+		var sb strings.Builder
+		if err := writeOpeningTag(&stickyWriter{w: &sb}, tag, keys, f.opts.Quote, " ", false); err != nil {
+			return err
+		}
+
+		width := len([]rune(strings.Repeat(f.opts.Indent, level))) + len([]rune(sb.String())) + 1
+		if len(tag.Children) == 0 {
+			width += 2
+		}
+
+		if width > f.opts.MaxWidth {
+			separator := "\n" + strings.Repeat(f.opts.Indent, level+1)
+			return writeOpeningTag(f.sw, tag, keys, f.opts.Quote, separator, false)
+		}
+	}
+
+	return writeOpeningTag(f.sw, tag, keys, f.opts.Quote, " ", false)
+}
+
+// attributeOrder: Find the order to write a tag's attributes in.
+// Source order is recovered by re-reading the opening tag from the document.
+func (f *formatter) attributeOrder(tag *Tag) []string {
+	sorted := sortedAttributeKeys(tag)
+	if f.opts.SortAttributes || !f.hasSource(tag) {
+		return sorted
+	}
+
+	keys := make([]string, 0, len(sorted))
+	seen := map[string]bool{}
+
+	runes := f.document
+	idx := tag.StartIdx + 1
+	for idx < len(runes) && !unicode.IsSpace(runes[idx]) && runes[idx] != '/' && runes[idx] != '>' {
+		idx += 1
+	}
+
+	for idx < len(runes) {
+		if unicode.IsSpace(runes[idx]) {
+			idx += 1
+			continue
+		}
+
+		if runes[idx] == '/' || runes[idx] == '>' {
+			break
+		}
+
+		key, _, endIdx, err := parseAttribute(runes, idx)
+		if err != nil {
+			break
+		}
+
+		if _, ok := tag.Attributes[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		idx = endIdx + 1
+	}
+
+	// Attributes which weren't found in the source (i.e. they were added in code) go last
+	for _, key := range sorted {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// hasSource: Check if the tag's position refers to an opening tag in the document
+func (f *formatter) hasSource(tag *Tag) bool {
+	return f.document != nil && tag.StartIdx >= 0 && tag.EndIdx <= len(f.document) && tag.StartIdx < tag.EndIdx &&
+		f.document[tag.StartIdx] == '<'
+}
+
+// hasGap: Check if there was whitespace between two adjacent siblings in the source.
+// Raw text includes any trailing whitespace, so this is also checked.
+func (f *formatter) hasGap(previous *Tag, next *Tag) bool {
+	if f.document == nil || previous.StartIdx < 0 || previous.EndIdx > len(f.document) || next.StartIdx < previous.EndIdx {
+		return true
+	}
+
+	if next.StartIdx > previous.EndIdx {
+		return true
+	}
+
+	return previous.Name == TextTagName && previous.EndIdx > previous.StartIdx && unicode.IsSpace(f.document[previous.EndIdx-1])
+}
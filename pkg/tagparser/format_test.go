@@ -0,0 +1,125 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+)
+
+var formatInput string = `   <html lang='en'>
+<head><meta charset="utf-8"/>
+<meta name="robots" content='noindex,nofollow'     />
+  <title>The Bestest Site Ever!</title></head>
+    <body><div id="main" class="content"><h1>Graphic Design is my Passion!</h1>
+  <p><span style='color:red'>Culture</span> and <span>Beauty</span>!</p></div></body>
+</html>`
+
+func TestFormat_IndentsDocument(t *testing.T) {
+	got, err := Format([]rune(formatInput), FormatOptions{})
+	want := `<html lang="en">
+    <head>
+        <meta charset="utf-8" />
+        <meta name="robots" content="noindex,nofollow" />
+        <title>The Bestest Site Ever!</title>
+    </head>
+    <body>
+        <div id="main" class="content">
+            <h1>Graphic Design is my Passion!</h1>
+            <p><span style="color:red">Culture</span> and <span>Beauty</span>!</p>
+        </div>
+    </body>
+</html>
+`
+
+	if err != nil || got != want {
+		t.Errorf("Format produced incorrect output (%v).\nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestFormat_AppliesOptions(t *testing.T) {
+	opts := FormatOptions{Indent: "\t", MaxWidth: 40, SortAttributes: true, Quote: '\''}
+	got, err := Format([]rune(formatInput), opts)
+	want := `<html lang='en'>
+	<head>
+		<meta charset='utf-8' />
+		<meta
+			content='noindex,nofollow'
+			name='robots' />
+		<title>The Bestest Site Ever!</title>
+	</head>
+	<body>
+		<div class='content' id='main'>
+			<h1>Graphic Design is my Passion!</h1>
+			<p><span style='color:red'>Culture</span> and <span>Beauty</span>!</p>
+		</div>
+	</body>
+</html>
+`
+
+	if err != nil || got != want {
+		t.Errorf("Format produced incorrect output (%v).\nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestFormat_IsIdempotentAndPreservesTree(t *testing.T) {
+	for _, opts := range []FormatOptions{{}, {MaxWidth: 20}, {SortAttributes: true, Quote: '\''}} {
+		once, err := Format([]rune(formatInput), opts)
+		if err != nil {
+			t.Fatalf("Expected Format to succeed. Got %v", err)
+		}
+
+		twice, err := Format([]rune(once), opts)
+		if err != nil || once != twice {
+			t.Errorf("Formatting a formatted document changed it (%v).\nOnce:\n%v\nTwice:\n%v", err, once, twice)
+		}
+
+		original, _ := Parse([]rune(formatInput))
+		formatted, _ := Parse([]rune(once))
+		if !Equal(&original.Root, &formatted.Root, EqualOptions{ExactText: true}) {
+			t.Errorf("Formatting changed the document. Edits: %v", Diff(&original.Root, &formatted.Root))
+		}
+	}
+}
+
+func TestFormat_UsesAlternativeQuoteWhenNeeded(t *testing.T) {
+	got, err := Format([]rune(`<a title='say "hi"' alt="it's" />`), FormatOptions{Quote: '"'})
+	want := `<a title='say "hi"' alt="it's" />` + "\n"
+	if err != nil || got != want {
+		t.Errorf("Format quoted incorrectly. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestFormat_ReturnsParseErrors(t *testing.T) {
+	_, err := Format([]rune("<a><b></a>"), FormatOptions{})
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Expected a ParseError. Got %v", err)
+	}
+
+	_, err = Format([]rune("<a />"), FormatOptions{Quote: '`'})
+	if err == nil || !strings.Contains(err.Error(), "invalid quotation") {
+		t.Errorf("Expected an invalid quotation error. Got %v", err)
+	}
+}
+
+func TestFormatTag_WorksWithoutSource(t *testing.T) {
+	tag := &Tag{
+		Name:       "ul",
+		Attributes: map[string]string{"z": "1", "a": "2"},
+		Children: []Tag{
+			{Name: "li", Children: []Tag{
+				{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "One"}},
+				{Name: "b", Children: []Tag{{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "Two"}}}},
+			}},
+			{Name: "li"},
+		},
+	}
+
+	got, err := FormatTag(tag, FormatOptions{Indent: "  "})
+	want := `<ul a="2" z="1">
+  <li>One <b>Two</b></li>
+  <li />
+</ul>
+`
+	if err != nil || got != want {
+		t.Errorf("FormatTag produced incorrect output (%v).\nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
@@ -0,0 +1,326 @@
+package tagparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JsonFormat selects the shape of the JSON written by JsonEncoder
+type JsonFormat int
+
+const (
+	// The shape written by ToJson - {"_name": "p", "class": "x", "_children": ["Hello"]}.
+	// Attributes named _name or _children collide with the tag's name and children
+	JsonFormatDefault JsonFormat = iota
+	// A collision free shape - {"name": "p", "attributes": {"class": "x"}, "children": ["Hello"]}
+	JsonFormatStructured
+	// JsonML arrays - ["p", {"class": "x"}, "Hello"]. The attributes object is omitted when there are no attributes
+	JsonFormatJsonML
+	// The BadgerFish convention - {"p": {"@class": "x", "$": "Hello"}}.
+	// Child elements are grouped by name, with repeated names becoming arrays
+	JsonFormatBadgerFish
+	// The Parker convention - The root element is dropped, attributes are ignored and elements containing only text
+	// become strings. Child elements are grouped by name, with repeated names becoming arrays
+	JsonFormatParker
+)
+
+var jsonFormatNames = []string{"default", "structured", "jsonml", "badgerfish", "parker"}
+
+func (f JsonFormat) String() string {
+	if f < 0 || int(f) >= len(jsonFormatNames) {
+		return fmt.Sprintf("JsonFormat(%d)", int(f))
+	}
+	return jsonFormatNames[f]
+}
+
+// ParseJsonFormat: Find the JsonFormat with the given name (i.e. "jsonml"). Names are case insensitive
+func ParseJsonFormat(name string) (JsonFormat, error) {
+	for idx, formatName := range jsonFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return JsonFormat(idx), nil
+		}
+	}
+
+	return JsonFormatDefault, fmt.Errorf("unknown json format %q. expected one of %v", name, strings.Join(jsonFormatNames, ", "))
+}
+
+// textTagContent: Get the text of a <text> pseudo tag, which is required to write it out
+func textTagContent(tag *Tag) (string, error) {
+	text, ok := tag.Attributes[TextAttributeName]
+	if !ok {
+		return "", fmt.Errorf("text tag is missing it's required text. cannot render")
+	}
+	return text, nil
+}
+
+// writeStructuredJson: Write the tag as {"name": ..., "attributes": {...}, "children": [...]}.
+// When lines is not nil, a "position" object is included and text is written as {"text": ..., "position": {...}}
+func writeStructuredJson(jw *jsonWriter, tag *Tag, lines *LineIndex) error {
+	writePosition := func(t *Tag) {
+		jw.Key("position")
+		jw.BeginObject()
+		writeJsonPosition(jw, t, lines, "")
+		jw.EndObject()
+	}
+
+	enter := func(t *Tag) error {
+		if t.Name == TextTagName {
+			text, err := textTagContent(t)
+			if err != nil {
+				return err
+			}
+
+			if lines == nil {
+				jw.String(text)
+			} else {
+				jw.BeginObject()
+				jw.Key("text")
+				jw.String(text)
+				writePosition(t)
+				jw.EndObject()
+			}
+			return SkipChildren
+		}
+
+		jw.BeginObject()
+		jw.Key("name")
+		jw.String(t.Name)
+
+		if lines != nil {
+			writePosition(t)
+		}
+
+		jw.Key("attributes")
+		jw.BeginObject()
+		for _, key := range sortedAttributeKeys(t) {
+			jw.Key(key)
+			jw.String(t.Attributes[key])
+		}
+		jw.EndObject()
+
+		jw.Key("children")
+		jw.BeginArray()
+
+		return jw.sw.err
+	}
+
+	exit := func(t *Tag) error {
+		if t.Name == TextTagName {
+			return nil
+		}
+
+		jw.EndArray()
+		jw.EndObject()
+		return jw.sw.err
+	}
+
+	return WalkEnterExit(tag, enter, exit)
+}
+
+// writeJsonMLJson: Write the tag as a JsonML array - ["name", {attributes}, children...]
+func writeJsonMLJson(jw *jsonWriter, tag *Tag) error {
+	enter := func(t *Tag) error {
+		if t.Name == TextTagName {
+			text, err := textTagContent(t)
+			if err != nil {
+				return err
+			}
+			jw.String(text)
+			return SkipChildren
+		}
+
+		jw.BeginArray()
+		jw.String(t.Name)
+
+		keys := sortedAttributeKeys(t)
+		if len(keys) > 0 {
+			jw.BeginObject()
+			for _, key := range keys {
+				jw.Key(key)
+				jw.String(t.Attributes[key])
+			}
+			jw.EndObject()
+		}
+
+		return jw.sw.err
+	}
+
+	exit := func(t *Tag) error {
+		if t.Name == TextTagName {
+			return nil
+		}
+
+		jw.EndArray()
+		return jw.sw.err
+	}
+
+	return WalkEnterExit(tag, enter, exit)
+}
+
+// jsonGroup: Sibling elements sharing a name, which are written under a single key
+type jsonGroup struct {
+	name string
+	tags []*Tag
+}
+
+type groupedFrame struct {
+	groups []jsonGroup
+	// The group, and the tag within that group, to be written next
+	group int
+	item  int
+}
+
+// groupChildren: Group an element's child elements by name, in order of first appearance.
+// The element's text content is joined with spaces, as the grouped formats cannot represent mixed content.
+func groupChildren(tag *Tag) (groups []jsonGroup, text string, hasText bool, err error) {
+	texts := make([]string, 0)
+	indexes := map[string]int{}
+
+	for idx := range tag.Children {
+		child := &tag.Children[idx]
+		if child.Name == TextTagName {
+			content, err := textTagContent(child)
+			if err != nil {
+				return nil, "", false, err
+			}
+			texts = append(texts, content)
+			continue
+		}
+
+		groupIdx, ok := indexes[child.Name]
+		if !ok {
+			groupIdx = len(groups)
+			indexes[child.Name] = groupIdx
+			groups = append(groups, jsonGroup{name: child.Name})
+		}
+		groups[groupIdx].tags = append(groups[groupIdx].tags, child)
+	}
+
+	return groups, strings.Join(texts, " "), len(texts) > 0, nil
+}
+
+// writeGroupedJson: Write the tag using the BadgerFish or Parker conventions, which both key child elements by name.
+// Uses an explicit stack, as the children are not written in document order.
+func writeGroupedJson(jw *jsonWriter, tag *Tag, parker bool) error {
+	if tag.Name == TextTagName {
+		text, err := textTagContent(tag)
+		if err != nil {
+			return err
+		}
+
+		if parker {
+			jw.String(text)
+		} else {
+			jw.BeginObject()
+			jw.Key("$")
+			jw.String(text)
+			jw.EndObject()
+		}
+		return jw.sw.err
+	}
+
+	stack := make([]groupedFrame, 0)
+
+	// open: Write the start of an element's value. Elements with child elements are pushed onto the stack so the
+	// children can be written, otherwise the value is completed immediately.
+	open := func(t *Tag) (bool, error) {
+		groups, text, hasText, err := groupChildren(t)
+		if err != nil {
+			return false, err
+		}
+
+		if parker {
+			switch {
+			case len(groups) > 0:
+				jw.BeginObject()
+			case hasText:
+				jw.String(text)
+				return false, nil
+			default:
+				jw.Literal("null")
+				return false, nil
+			}
+		} else {
+			jw.BeginObject()
+			for _, key := range sortedAttributeKeys(t) {
+				jw.Key("@" + key)
+				jw.String(t.Attributes[key])
+			}
+
+			if hasText {
+				jw.Key("$")
+				jw.String(text)
+			}
+
+			if len(groups) == 0 {
+				jw.EndObject()
+				return false, nil
+			}
+		}
+
+		stack = append(stack, groupedFrame{groups: groups})
+		return true, nil
+	}
+
+	// advance: Move the frame on to it's next tag, closing any finished array
+	advance := func(frame *groupedFrame) {
+		group := frame.groups[frame.group]
+		frame.item += 1
+		if frame.item == len(group.tags) {
+			if len(group.tags) > 1 {
+				jw.EndArray()
+			}
+			frame.group += 1
+			frame.item = 0
+		}
+	}
+
+	if !parker {
+		// The Parker convention drops the root element, so only BadgerFish writes it's name
+		jw.BeginObject()
+		jw.Key(tag.Name)
+	}
+
+	if _, err := open(tag); err != nil {
+		return err
+	}
+
+	for len(stack) > 0 {
+		frame := &stack[len(stack)-1]
+		if frame.group == len(frame.groups) {
+			jw.EndObject()
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				advance(&stack[len(stack)-1])
+			}
+			continue
+		}
+
+		group := frame.groups[frame.group]
+		if frame.item == 0 {
+			jw.Key(group.name)
+			if len(group.tags) > 1 {
+				jw.BeginArray()
+			}
+		}
+
+		pushed, err := open(group.tags[frame.item])
+		if err != nil {
+			return err
+		}
+
+		if !pushed {
+			advance(frame)
+		}
+
+		if jw.sw.err != nil {
+			return jw.sw.err
+		}
+	}
+
+	if !parker {
+		jw.EndObject()
+	}
+
+	return jw.sw.err
+}
@@ -0,0 +1,134 @@
+package tagparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var jsonFormatInput string = `<library id="1"><book lang="en"><title>Go</title><price>10</price></book><book><title>Rust</title></book>Some<shelf />text</library>`
+
+// encodeCompact: Encode the tag with the given format, compacting the output to simplify comparisons
+func encodeCompact(t *testing.T, tag *Tag, format JsonFormat) (string, error) {
+	var buffer bytes.Buffer
+	encoder := NewJsonEncoder(&buffer)
+	encoder.SetFormat(format)
+	if err := encoder.Encode(tag); err != nil {
+		return "", err
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, buffer.Bytes()); err != nil {
+		t.Fatalf("Encoder produced invalid JSON: %v\n%v", err, buffer.String())
+	}
+	return compacted.String(), nil
+}
+
+func TestJsonEncoder_WritesFormats(t *testing.T) {
+	type Def struct {
+		format JsonFormat
+		want   string
+	}
+
+	test_defs := []Def{
+		{
+			format: JsonFormatDefault,
+			want: `{"_name":"library","id":"1","_children":[{"_name":"book","lang":"en","_children":[{"_name":"title","_children":["Go"]},` +
+				`{"_name":"price","_children":["10"]}]},{"_name":"book","_children":[{"_name":"title","_children":["Rust"]}]},"Some",{"_name":"shelf"},"text"]}`,
+		},
+		{
+			format: JsonFormatStructured,
+			want: `{"name":"library","attributes":{"id":"1"},"children":[{"name":"book","attributes":{"lang":"en"},"children":[` +
+				`{"name":"title","attributes":{},"children":["Go"]},{"name":"price","attributes":{},"children":["10"]}]},` +
+				`{"name":"book","attributes":{},"children":[{"name":"title","attributes":{},"children":["Rust"]}]},"Some",` +
+				`{"name":"shelf","attributes":{},"children":[]},"text"]}`,
+		},
+		{
+			format: JsonFormatJsonML,
+			want:   `["library",{"id":"1"},["book",{"lang":"en"},["title","Go"],["price","10"]],["book",["title","Rust"]],"Some",["shelf"],"text"]`,
+		},
+		{
+			format: JsonFormatBadgerFish,
+			want: `{"library":{"@id":"1","$":"Some text","book":[{"@lang":"en","title":{"$":"Go"},"price":{"$":"10"}},` +
+				`{"title":{"$":"Rust"}}],"shelf":{}}}`,
+		},
+		{
+			format: JsonFormatParker,
+			want:   `{"book":[{"title":"Go","price":"10"},{"title":"Rust"}],"shelf":null}`,
+		},
+	}
+
+	result, err := Parse([]rune(jsonFormatInput))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	for _, def := range test_defs {
+		got, err := encodeCompact(t, &result.Root, def.format)
+		if err != nil || got != def.want {
+			t.Errorf("Incorrect %v output (%v). \nGot:\n%v\nWant:\n%v", def.format, err, got, def.want)
+		}
+	}
+}
+
+func TestJsonEncoder_StructuredFormatAvoidsCollisions(t *testing.T) {
+	tag := &Tag{Name: "a", Attributes: map[string]string{"_name": "b", "_children": "c"}}
+	got, err := encodeCompact(t, tag, JsonFormatStructured)
+	want := `{"name":"a","attributes":{"_children":"c","_name":"b"},"children":[]}`
+	if err != nil || got != want {
+		t.Errorf("Structured format produced incorrect output (%v). Got %v Want %v", err, got, want)
+	}
+}
+
+func TestJsonEncoder_WritesTextRoots(t *testing.T) {
+	type Def struct {
+		format JsonFormat
+		want   string
+	}
+
+	test_defs := []Def{
+		{format: JsonFormatDefault, want: `"Lonely"`},
+		{format: JsonFormatStructured, want: `"Lonely"`},
+		{format: JsonFormatJsonML, want: `"Lonely"`},
+		{format: JsonFormatBadgerFish, want: `{"$":"Lonely"}`},
+		{format: JsonFormatParker, want: `"Lonely"`},
+	}
+
+	tag := &Tag{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "Lonely"}}
+	for _, def := range test_defs {
+		got, err := encodeCompact(t, tag, def.format)
+		if err != nil || got != def.want {
+			t.Errorf("Incorrect %v output for text (%v). Got %v Want %v", def.format, err, got, def.want)
+		}
+	}
+}
+
+func TestJsonEncoder_FormatsReturnErrors(t *testing.T) {
+	tag := &Tag{Name: "a", Children: []Tag{{Name: "b", Children: []Tag{{Name: TextTagName}}}}}
+	for format := JsonFormatDefault; format <= JsonFormatParker; format++ {
+		_, err := encodeCompact(t, tag, format)
+		if err == nil || !strings.Contains(err.Error(), "missing it's required text") {
+			t.Errorf("%v format should fail for text tags without text. Got %v", format, err)
+		}
+	}
+
+	_, err := encodeCompact(t, tag, JsonFormat(100))
+	if err == nil || !strings.Contains(err.Error(), "unknown json format") {
+		t.Errorf("Expected an unknown format error. Got %v", err)
+	}
+}
+
+func TestParseJsonFormat(t *testing.T) {
+	for format := JsonFormatDefault; format <= JsonFormatParker; format++ {
+		got, err := ParseJsonFormat(strings.ToUpper(format.String()))
+		if err != nil || got != format {
+			t.Errorf("ParseJsonFormat couldn't parse %v. Got %v (%v)", format, got, err)
+		}
+	}
+
+	_, err := ParseJsonFormat("xml")
+	if err == nil || !strings.Contains(err.Error(), "unknown json format") {
+		t.Errorf("Expected an unknown format error. Got %v", err)
+	}
+}
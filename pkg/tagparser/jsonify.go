@@ -1,85 +1,441 @@
 package tagparser
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"sort"
+	"io"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-func (tag *Tag) ToJson() string {
-	var sb strings.Builder
+// JsonEncoder writes Tag trees to an output stream as JSON.
+//
+// By default, each element is written as an object, with the tag name under "_name", each attribute as a string
+// property (in name order) and the children (if any) as an array under "_children". <text> pseudo tags are written as
+// strings. Other shapes can be selected with SetFormat.
+type JsonEncoder struct {
+	w      io.Writer
+	indent string
+	format JsonFormat
+	// The line index of the source document, when positions are included
+	lines *LineIndex
+}
 
-	depth := 0
-	if tag == nil {
-		return ""
+// NewJsonEncoder: Create an encoder which writes to w, indenting with four spaces
+func NewJsonEncoder(w io.Writer) *JsonEncoder {
+	return &JsonEncoder{w: w, indent: "    "}
+}
+
+// SetIndent: Set the string used for each level of indentation. An empty indent writes compact JSON, so each
+// encoded tag is written on a single line (i.e. as newline delimited JSON)
+func (e *JsonEncoder) SetIndent(indent string) {
+	e.indent = indent
+}
+
+// SetFormat: Select the shape of the JSON written for each tag. See JsonFormat
+func (e *JsonEncoder) SetFormat(format JsonFormat) {
+	e.format = format
+}
+
+// SetPositions: Include the source position of each tag, as found in the given document (i.e. ParseResult.Document).
+// Each element (and <text> pseudo tag) gets it's start and end offsets, the line and column of it's start, and it's depth.
+// <text> pseudo tags are written as objects rather than strings, so they can hold their position.
+// Only the default and structured formats support positions. Providing a nil document disables positions.
+func (e *JsonEncoder) SetPositions(document []rune) {
+	if document == nil {
+		e.lines = nil
+		return
 	}
+	e.lines = NewLineIndex(document)
+}
 
-	depth = tag.Depth
-	toJson(tag, &sb, depth)
-	sb.WriteString("\n")
-	return sb.String()
+// SetLineIndex: Include source positions as SetPositions does, finding lines and columns with the given index. Use
+// ParseResult.LineIndex to report positions within the input passed to Parse - the start and end offsets are then
+// offsets into the input too, counting any leading whitespace Parse stripped. A nil index disables positions
+func (e *JsonEncoder) SetLineIndex(lines *LineIndex) {
+	e.lines = lines
 }
 
-func toJson(tag *Tag, sb *strings.Builder, depth int) (error error) {
+// Encode: Write the JSON encoding of the tag to the stream, followed by a newline.
+// A nil tag writes nothing.
+func (e *JsonEncoder) Encode(tag *Tag) error {
 	if tag == nil {
-		return
+		return nil
+	}
+
+	jw := &jsonWriter{sw: &stickyWriter{w: e.w}, indent: e.indent}
+
+	if e.lines != nil && e.format != JsonFormatDefault && e.format != JsonFormatStructured {
+		return fmt.Errorf("positions are not supported by the %v json format", e.format)
+	}
+
+	var err error
+	switch e.format {
+	case JsonFormatDefault:
+		err = writeTagJson(jw, tag, e.lines)
+	case JsonFormatStructured:
+		err = writeStructuredJson(jw, tag, e.lines)
+	case JsonFormatJsonML:
+		err = writeJsonMLJson(jw, tag)
+	case JsonFormatBadgerFish:
+		err = writeGroupedJson(jw, tag, false)
+	case JsonFormatParker:
+		err = writeGroupedJson(jw, tag, true)
+	default:
+		err = fmt.Errorf("unknown json format %v", e.format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	jw.sw.WriteString("\n")
+	return jw.sw.err
+}
+
+// ToJson: Convert the tag to an indented JSON document. See JsonEncoder for the output shape.
+func (tag *Tag) ToJson() (string, error) {
+	return tag.ToJsonWithPositions(nil)
+}
+
+// ToJsonWithPositions: Convert the tag to an indented JSON document, including the position of each tag within the
+// source document. See JsonEncoder.SetPositions for the output shape.
+func (tag *Tag) ToJsonWithPositions(document []rune) (string, error) {
+	var sb strings.Builder
+	encoder := NewJsonEncoder(&sb)
+	encoder.SetPositions(document)
+	err := encoder.Encode(tag)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// MarshalJSON: Implements json.Marshaler, writing the tag in the same shape as ToJson.
+// Positions and depths are not included.
+func (t Tag) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	jw := &jsonWriter{sw: &stickyWriter{w: &buffer}}
+	if err := writeTagJson(jw, &t, nil); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalJSON: Implements json.Unmarshaler, reading a tag in the shape written by ToJson.
+// Strings become <text> pseudo tags. The tag is treated as a root, so depths start from 0.
+// Offsets written by ToJsonWithPositions are restored, otherwise tags are given synthetic positions (see SyntheticIdx).
+// A JSON null leaves the tag unchanged.
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	tag, err := tagFromJsonValue(value, 0)
+	if err != nil {
+		return err
+	}
+
+	*t = tag
+	return nil
+}
+
+// FromJson: Read a single JSON value in the shape written by ToJson, and rebuild the Tag tree.
+// Strings become <text> pseudo tags. Offsets written by ToJsonWithPositions are restored, otherwise tags are given
+// synthetic positions (see SyntheticIdx), so Render serializes them.
+func FromJson(r io.Reader) (Tag, error) {
+	var value any
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&value); err != nil {
+		return Tag{}, err
+	}
+
+	if decoder.More() {
+		return Tag{}, fmt.Errorf("unexpected input after the json value")
 	}
 
-	root_header := strings.Repeat("    ", depth)
-	inner_header := root_header + "    "
+	return tagFromJsonValue(value, 0)
+}
+
+// tagFromJsonValue: Build a tag from a decoded JSON value, in the shape written by ToJson
+func tagFromJsonValue(value any, depth int) (Tag, error) {
+	switch value := value.(type) {
+	case string:
+		return Tag{Name: TextTagName, StartIdx: SyntheticIdx, EndIdx: SyntheticIdx, Depth: depth,
+			Attributes: map[string]string{TextAttributeName: value}}, nil
+	case map[string]any:
+		tag := Tag{StartIdx: SyntheticIdx, EndIdx: SyntheticIdx, Depth: depth}
 
-	if tag.Name == "<text>" {
-		text, ok := tag.Attributes["text"]
+		name, ok := value["_name"]
 		if !ok {
-			return fmt.Errorf("text tag is missing it's required text. cannot render")
+			return Tag{}, fmt.Errorf("json object is missing it's required _name")
 		}
-		sb.WriteString(fmt.Sprintf("%v\"%v\"", root_header, text))
-		return
-	}
 
-	sb.WriteString(fmt.Sprintf("%v{\n", root_header))
+		if tag.Name, ok = name.(string); !ok {
+			return Tag{}, fmt.Errorf("_name must be a string. got %v", name)
+		}
 
-	sb.WriteString(fmt.Sprintf("%v\"_name\": \"%v\"", inner_header, tag.Name))
+		for key, attribute := range value {
+			if key == "_name" || key == "_children" {
+				continue
+			}
 
-	if tag.Attributes != nil {
-		sb.WriteString(",\n")
+			if offset, ok := attribute.(float64); ok && jsonPositionKeys[key] {
+				switch key {
+				case "_start":
+					tag.StartIdx = int(offset)
+				case "_end":
+					tag.EndIdx = int(offset)
+				}
+				continue
+			}
 
-		// Ensure that keys are sorted for a stable output
-		keys := make([]string, len(tag.Attributes))
-		i := 0
-		for k := range tag.Attributes {
-			keys[i] = k
-			i += 1
+			attributeValue, ok := attribute.(string)
+			if !ok {
+				return Tag{}, fmt.Errorf("attribute %v must be a string. got %v", key, attribute)
+			}
+
+			if tag.Attributes == nil {
+				tag.Attributes = map[string]string{}
+			}
+			tag.Attributes[key] = attributeValue
 		}
-		sort.Strings(keys)
 
-		for idx, key := range keys {
-			sb.WriteString(fmt.Sprintf("%v\"%v\": \"%v\"", inner_header, key, tag.Attributes[key]))
-			if idx != len(tag.Attributes)-1 {
-				sb.WriteString(",")
-				sb.WriteString("\n")
+		children, ok := value["_children"]
+		if !ok {
+			return tag, nil
+		}
+
+		childValues, ok := children.([]any)
+		if !ok {
+			return Tag{}, fmt.Errorf("_children must be an array. got %v", children)
+		}
+
+		for _, childValue := range childValues {
+			child, err := tagFromJsonValue(childValue, depth+1)
+			if err != nil {
+				return Tag{}, err
 			}
+			tag.Children = append(tag.Children, child)
 		}
+
+		return tag, nil
+	default:
+		return Tag{}, fmt.Errorf("expected a json object or string. got %v", value)
 	}
+}
 
-	if len(tag.Children) > 0 {
-		sb.WriteString(",\n")
+// The keys used for positions in the default format. Only numeric values are treated as positions
+var jsonPositionKeys = map[string]bool{"_start": true, "_end": true, "_line": true, "_column": true, "_depth": true}
 
-		sb.WriteString(fmt.Sprintf("%v\"_children\": [\n", inner_header))
+// writeJsonPosition: Write the position properties of a tag into the current object, prefixing each key. Offsets are
+// written within the indexed document, so they match the line and column
+func writeJsonPosition(jw *jsonWriter, tag *Tag, lines *LineIndex, prefix string) {
+	position := lines.Position(tag.StartIdx)
 
-		for idx, child := range tag.Children {
-			toJson(&child, sb, depth+2)
+	jw.Key(prefix + "start")
+	jw.Literal(strconv.Itoa(tag.StartIdx + lines.offset))
+	jw.Key(prefix + "end")
+	jw.Literal(strconv.Itoa(tag.EndIdx + lines.offset))
+	jw.Key(prefix + "line")
+	jw.Literal(strconv.Itoa(position.Line))
+	jw.Key(prefix + "column")
+	jw.Literal(strconv.Itoa(position.Column))
+	jw.Key(prefix + "depth")
+	jw.Literal(strconv.Itoa(tag.Depth))
+}
+
+// writeTagJson: Write the tag in the default format. When lines is not nil, positions are included
+func writeTagJson(jw *jsonWriter, tag *Tag, lines *LineIndex) error {
+	enter := func(t *Tag) error {
+		if t.Name == TextTagName {
+			text, err := textTagContent(t)
+			if err != nil {
+				return err
+			}
 
-			if idx != len(tag.Children)-1 {
-				sb.WriteString(",")
+			if lines == nil {
+				jw.String(text)
+				return SkipChildren
 			}
-			sb.WriteString("\n")
 		}
 
-		sb.WriteString(fmt.Sprintf("%v]", inner_header))
+		jw.BeginObject()
+		jw.Key("_name")
+		jw.String(t.Name)
+
+		if lines != nil {
+			writeJsonPosition(jw, t, lines, "_")
+		}
+
+		if t.Name == TextTagName {
+			jw.Key(TextAttributeName)
+			jw.String(t.Attributes[TextAttributeName])
+		}
+
+		// Ensure that keys are sorted for a stable output
+		for _, key := range sortedAttributeKeys(t) {
+			jw.Key(key)
+			jw.String(t.Attributes[key])
+		}
+
+		if len(t.Children) > 0 {
+			jw.Key("_children")
+			jw.BeginArray()
+		}
+
+		return jw.sw.err
 	}
 
-	sb.WriteString(fmt.Sprintf("\n%v}", root_header))
+	exit := func(t *Tag) error {
+		if t.Name == TextTagName && lines == nil {
+			return nil
+		}
+
+		if len(t.Children) > 0 {
+			jw.EndArray()
+		}
+		jw.EndObject()
 
-	return
+		return jw.sw.err
+	}
+
+	return WalkEnterExit(tag, enter, exit)
+}
+
+// jsonWriter is a minimal streaming JSON writer, handling separators, indentation and string escaping.
+// When indent is empty, the output is compact.
+type jsonWriter struct {
+	sw     *stickyWriter
+	indent string
+	// The number of values written to each open object or array
+	counts []int
+	// Whether a key has been written, and is waiting for it's value
+	afterKey bool
+}
+
+func (j *jsonWriter) newline() {
+	if j.indent == "" {
+		return
+	}
+	j.sw.WriteString("\n")
+	j.sw.WriteString(strings.Repeat(j.indent, len(j.counts)))
+}
+
+// beginValue: Write the separator required before a new value or key
+func (j *jsonWriter) beginValue() {
+	if j.afterKey {
+		j.afterKey = false
+		return
+	}
+
+	if len(j.counts) == 0 {
+		return
+	}
+
+	if j.counts[len(j.counts)-1] > 0 {
+		j.sw.WriteString(",")
+	}
+	j.counts[len(j.counts)-1] += 1
+	j.newline()
+}
+
+func (j *jsonWriter) open(bracket string) {
+	j.beginValue()
+	j.sw.WriteString(bracket)
+	j.counts = append(j.counts, 0)
+}
+
+func (j *jsonWriter) close(bracket string) {
+	count := j.counts[len(j.counts)-1]
+	j.counts = j.counts[:len(j.counts)-1]
+	if count > 0 {
+		j.newline()
+	}
+	j.sw.WriteString(bracket)
+}
+
+func (j *jsonWriter) BeginObject() {
+	j.open("{")
+}
+
+func (j *jsonWriter) EndObject() {
+	j.close("}")
+}
+
+func (j *jsonWriter) BeginArray() {
+	j.open("[")
+}
+
+func (j *jsonWriter) EndArray() {
+	j.close("]")
+}
+
+func (j *jsonWriter) Key(key string) {
+	j.beginValue()
+	j.sw.WriteString(quoteJsonString(key))
+	j.sw.WriteString(":")
+	if j.indent != "" {
+		j.sw.WriteString(" ")
+	}
+	j.afterKey = true
+}
+
+func (j *jsonWriter) String(value string) {
+	j.beginValue()
+	j.sw.WriteString(quoteJsonString(value))
+}
+
+// Literal: Write a value which needs no quoting, such as null
+func (j *jsonWriter) Literal(value string) {
+	j.beginValue()
+	j.sw.WriteString(value)
+}
+
+// quoteJsonString: Quote and escape a string as specified by RFC 8259
+func quoteJsonString(s string) string {
+	const hex = "0123456789abcdef"
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for idx, r := range s {
+		switch {
+		case r == '"':
+			sb.WriteString(`\"`)
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\b':
+			sb.WriteString(`\b`)
+		case r == '\f':
+			sb.WriteString(`\f`)
+		case r < 0x20 || r == '\u2028' || r == '\u2029':
+			// Remaining control characters, along with the line and paragraph separators which break JavaScript parsers
+			sb.WriteString(`\u`)
+			sb.WriteByte(hex[r>>12&0xF])
+			sb.WriteByte(hex[r>>8&0xF])
+			sb.WriteByte(hex[r>>4&0xF])
+			sb.WriteByte(hex[r&0xF])
+		case r == utf8.RuneError && !strings.HasPrefix(s[idx:], "\uFFFD"):
+			// Invalid UTF-8 - Replace with the replacement character so the output is still valid
+			sb.WriteString(`\ufffd`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+
+	return sb.String()
 }
@@ -1,15 +1,22 @@
 package tagparser
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestToJson_WorksWithNilTag(t *testing.T) {
 	var tag *Tag
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := ""
-	if got != want {
-		t.Errorf("ToJson doesn't work with nil tags. Got %v Want %v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with nil tags. Got %v (%v) Want %v", got, err, want)
 	}
 }
 
@@ -19,10 +26,10 @@ func TestToJson_WorksWithTextTags(t *testing.T) {
 		Attributes: map[string]string{"text": "Hello, World!"},
 	}
 
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := "\"Hello, World!\"\n"
-	if got != want {
-		t.Errorf("ToJson doesn't work with text tags. Got %v Want %v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with text tags. Got %v (%v) Want %v", got, err, want)
 	}
 }
 
@@ -30,10 +37,10 @@ func TestToJson_WorksWithSimpleTags(t *testing.T) {
 	tag := &Tag{
 		Name: "🐹",
 	}
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := "{\n    \"_name\": \"🐹\"\n}\n"
-	if got != want {
-		t.Errorf("ToJson doesn't work with simple documents. \nGot:\n%v\nWant:\n%v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with simple documents (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
 	}
 }
 
@@ -45,15 +52,15 @@ func TestToJson_WorksWithAttributes(t *testing.T) {
 			"🥳": "😭",
 		},
 	}
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := `{
     "_name": "🐹",
     "🥳": "😭",
     "🦊": "🐶"
 }
 `
-	if got != want {
-		t.Errorf("ToJson doesn't work with attributes. \nGot:\n%v\nWant:\n%v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with attributes (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
 	}
 }
 
@@ -65,7 +72,7 @@ func TestToJson_BasicSingleChild(t *testing.T) {
 		},
 	}
 
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := `{
     "_name": "Cool",
     "_children": [
@@ -74,8 +81,8 @@ func TestToJson_BasicSingleChild(t *testing.T) {
 }
 `
 
-	if got != want {
-		t.Errorf("ToJson doesn't work with single children. \nGot:\n%v\nWant:\n%v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with single children (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
 	}
 }
 
@@ -88,7 +95,7 @@ func TestToJson_WorksWithChildren(t *testing.T) {
 		},
 	}
 
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := `{
     "_name": "Cool",
     "_children": [
@@ -101,8 +108,8 @@ func TestToJson_WorksWithChildren(t *testing.T) {
 }
 `
 
-	if got != want {
-		t.Errorf("ToJson doesn't work with children. \nGot:\n%v\nWant:\n%v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with children (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
 	}
 }
 
@@ -116,7 +123,7 @@ func TestToJson_WorksWithAllFeatures(t *testing.T) {
 		},
 	}
 
-	got := tag.ToJson()
+	got, err := tag.ToJson()
 	want := `{
     "_name": "Cool",
     "Neat": "Attribute!",
@@ -130,7 +137,393 @@ func TestToJson_WorksWithAllFeatures(t *testing.T) {
 }
 `
 
-	if got != want {
-		t.Errorf("ToJson doesn't work with children. \nGot:\n%v\nWant:\n%v", got, want)
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with children (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestToJson_EscapesStrings(t *testing.T) {
+	tag := &Tag{
+		Name:       `say "hi"`,
+		Attributes: map[string]string{`back\slash`: "line\nbreak\ttab\u0001\u2028"},
+		Children: []Tag{
+			{Name: "<text>", Attributes: map[string]string{"text": `"quoted" 🐶`}},
+		},
+	}
+
+	got, err := tag.ToJson()
+	want := `{
+    "_name": "say \"hi\"",
+    "back\\slash": "line\nbreak\ttab\u0001\u2028",
+    "_children": [
+        "\"quoted\" 🐶"
+    ]
+}
+`
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't escape strings (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+		t.Fatalf("ToJson produced invalid JSON: %v", err)
+	}
+
+	if decoded["back\\slash"] != "line\nbreak\ttab\u0001\u2028" || decoded["_name"] != `say "hi"` {
+		t.Errorf("ToJson output decoded incorrectly. Got %v", decoded)
+	}
+}
+
+func TestToJson_WorksWithEmptyAttributes(t *testing.T) {
+	tag := &Tag{Name: "a", Attributes: map[string]string{}, Children: []Tag{}}
+	got, err := tag.ToJson()
+	want := "{\n    \"_name\": \"a\"\n}\n"
+	if err != nil || got != want {
+		t.Errorf("ToJson doesn't work with empty attributes (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestToJson_ReturnsErrors(t *testing.T) {
+	tag := &Tag{
+		Name:     "Cool",
+		Children: []Tag{{Name: "<text>"}},
+	}
+
+	got, err := tag.ToJson()
+	if err == nil || !strings.Contains(err.Error(), "missing it's required text") || got != "" {
+		t.Errorf("ToJson should fail for text tags without text. Got %v (%v)", got, err)
+	}
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("🔥")
+}
+
+func TestJsonEncoder_StreamsToWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := NewJsonEncoder(&buffer)
+	for _, name := range []string{"a", "b"} {
+		if err := encoder.Encode(&Tag{Name: name}); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+
+	want := "{\n    \"_name\": \"a\"\n}\n{\n    \"_name\": \"b\"\n}\n"
+	if buffer.String() != want {
+		t.Errorf("JsonEncoder wrote incorrect output. \nGot:\n%v\nWant:\n%v", buffer.String(), want)
+	}
+
+	err := NewJsonEncoder(failingWriter{}).Encode(&Tag{Name: "a"})
+	if err == nil || err.Error() != "🔥" {
+		t.Errorf("JsonEncoder should return writer errors. Got %v", err)
+	}
+}
+
+func TestTag_MarshalsWithEncodingJson(t *testing.T) {
+	type Payload struct {
+		Id       int  `json:"id"`
+		Document Tag  `json:"document"`
+		Missing  *Tag `json:"missing"`
+	}
+
+	result, err := Parse([]rune(`<p class="x">Hello <b>World</b></p>`))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	got, err := json.Marshal(Payload{Id: 1, Document: result.Root})
+	want := `{"id":1,"document":{"_name":"p","class":"x","_children":["Hello",{"_name":"b","_children":["World"]}]},"missing":null}`
+	if err != nil || string(got) != want {
+		t.Errorf("json.Marshal produced incorrect output (%v). \nGot:\n%v\nWant:\n%v", err, string(got), want)
+	}
+
+	_, err = json.Marshal(Tag{Name: "a", Children: []Tag{{Name: TextTagName}}})
+	if err == nil || !strings.Contains(err.Error(), "missing it's required text") {
+		t.Errorf("json.Marshal should fail for text tags without text. Got %v", err)
+	}
+}
+
+func TestTag_RoundTripsThroughEncodingJson(t *testing.T) {
+	ignorePositions := cmpopts.IgnoreFields(Tag{}, "StartIdx", "EndIdx")
+
+	documents := []string{
+		"<p>Hello, World! 🐶</p>",
+		"<><>Lonely!</></>",
+		"< />",
+		`<html lang='en'><head><meta charset="utf-8" /><title>The Bestest Site Ever!</title></head>
+			<body><div data-x='say "hi"'>Culture and <span>Beauty</span></div></body></html>`,
+	}
+
+	for _, document := range documents {
+		original, err := Parse([]rune(document))
+		if err != nil {
+			t.Fatalf("Expected document to parse. Got %v", err)
+		}
+
+		data, err := json.Marshal(original.Root)
+		if err != nil {
+			t.Errorf("Expected document to marshal. Got %v", err)
+			continue
+		}
+
+		var decoded Tag
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("Expected json to unmarshal. Got %v\n%v", err, string(data))
+			continue
+		}
+
+		if diff := cmp.Diff(original.Root, decoded, ignorePositions); diff != "" {
+			t.Errorf("Tag did not round trip through json (-want +got):\n%v", diff)
+		}
+	}
+}
+
+func TestTag_UnmarshalJsonReturnsErrors(t *testing.T) {
+	type Def struct {
+		json          string
+		expectedError string
+	}
+
+	test_defs := []Def{
+		{json: `{"_children": []}`, expectedError: "missing it's required _name"},
+		{json: `{"_name": 1}`, expectedError: "_name must be a string"},
+		{json: `{"_name": "a", "b": true}`, expectedError: "attribute b must be a string"},
+		{json: `{"_name": "a", "_children": "b"}`, expectedError: "_children must be an array"},
+		{json: `{"_name": "a", "_children": [1]}`, expectedError: "expected a json object or string"},
+		{json: `[]`, expectedError: "expected a json object or string"},
+		{json: `{"_name": `, expectedError: "unexpected end of JSON input"},
+	}
+
+	for _, def := range test_defs {
+		var tag Tag
+		err := json.Unmarshal([]byte(def.json), &tag)
+		if err == nil || !strings.Contains(err.Error(), def.expectedError) {
+			t.Errorf("Error was %v but expected %v", err, def.expectedError)
+		}
+	}
+}
+
+func TestFromJson_RebuildsToJsonOutput(t *testing.T) {
+	ignorePositions := cmpopts.IgnoreFields(Tag{}, "StartIdx", "EndIdx")
+
+	original, err := Parse([]rune(`<div id="main"><h1>Graphic Design is my Passion!</h1>
+		<p><span style='color:red'>Culture</span> and <span>Beauty</span> &amp; more</p><br /></div>`))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	json, err := original.Root.ToJson()
+	if err != nil {
+		t.Fatalf("Expected document to convert to json. Got %v", err)
+	}
+
+	got, err := FromJson(strings.NewReader(json))
+	if err != nil {
+		t.Fatalf("Expected json to convert back to a tag. Got %v", err)
+	}
+
+	if diff := cmp.Diff(original.Root, got, ignorePositions); diff != "" {
+		t.Errorf("FromJson did not rebuild the tree (-want +got):\n%v", diff)
+	}
+
+	markup, err := SerializeToString(&got)
+	want := `<div id="main"><h1>Graphic Design is my Passion!</h1><p><span style="color:red">Culture</span>and<span>Beauty</span>&amp; more</p><br /></div>`
+	if err != nil || markup != want {
+		t.Errorf("FromJson result serialized incorrectly (%v). \nGot:\n%v\nWant:\n%v", err, markup, want)
+	}
+}
+
+func TestFromJson_GivesSyntheticPositions(t *testing.T) {
+	got, err := FromJson(strings.NewReader(`{"_name": "p", "class": "a", "_children": ["Hi", {"_name": "br"}]}`))
+	if err != nil {
+		t.Fatalf("Expected json to convert to a tag. Got %v", err)
+	}
+
+	if !got.IsSynthetic() || !got.Children[0].IsSynthetic() || !got.Children[1].IsSynthetic() {
+		t.Errorf("Tags without _start and _end should have synthetic positions. Got %+v", got)
+	}
+	if markup, want := got.Render(nil), `<p class="a">Hi<br /></p>`; markup != want {
+		t.Errorf("Render of a FromJson tree should serialize it. Got %v Want %v", markup, want)
+	}
+
+	var tag Tag
+	if err := json.Unmarshal([]byte(`{"_name": "p", "_children": ["Hi"]}`), &tag); err != nil || tag.Render(nil) != "<p>Hi</p>" {
+		t.Errorf("Render of an unmarshalled tree should serialize it. Got %v (%v)", tag.Render(nil), err)
+	}
+}
+
+func TestFromJson_ReturnsErrors(t *testing.T) {
+	type Def struct {
+		json          string
+		expectedError string
+	}
+
+	test_defs := []Def{
+		{json: ``, expectedError: "EOF"},
+		{json: `null`, expectedError: "expected a json object or string"},
+		{json: `{"_name": "a"} {"_name": "b"}`, expectedError: "unexpected input after the json value"},
+		{json: `{"_name": "a", "_children": [{}]}`, expectedError: "missing it's required _name"},
+	}
+
+	for _, def := range test_defs {
+		_, err := FromJson(strings.NewReader(def.json))
+		if err == nil || !strings.Contains(err.Error(), def.expectedError) {
+			t.Errorf("Error was %v but expected %v", err, def.expectedError)
+		}
+	}
+}
+
+func TestToJsonWithPositions_IncludesPositions(t *testing.T) {
+	result, err := Parse([]rune("<p class=\"x\">\n  Hello <b />\n</p>"))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	got, err := result.Root.ToJsonWithPositions(result.Document)
+	want := `{
+    "_name": "p",
+    "_start": 0,
+    "_end": 32,
+    "_line": 1,
+    "_column": 1,
+    "_depth": 0,
+    "class": "x",
+    "_children": [
+        {
+            "_name": "<text>",
+            "_start": 16,
+            "_end": 22,
+            "_line": 2,
+            "_column": 3,
+            "_depth": 1,
+            "text": "Hello"
+        },
+        {
+            "_name": "b",
+            "_start": 22,
+            "_end": 27,
+            "_line": 2,
+            "_column": 9,
+            "_depth": 1
+        }
+    ]
+}
+`
+	if err != nil || got != want {
+		t.Errorf("ToJsonWithPositions produced incorrect output (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+
+	// Offsets are restored when reading the JSON back in
+	restored, err := FromJson(strings.NewReader(got))
+	if err != nil {
+		t.Fatalf("Expected json to convert back to a tag. Got %v", err)
+	}
+
+	if diff := cmp.Diff(result.Root, restored); diff != "" {
+		t.Errorf("FromJson did not restore positions (-want +got):\n%v", diff)
+	}
+}
+
+func TestJsonEncoder_StructuredFormatIncludesPositions(t *testing.T) {
+	result, err := Parse([]rune("<p>Hi</p>"))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	encoder := NewJsonEncoder(&buffer)
+	encoder.SetFormat(JsonFormatStructured)
+	encoder.SetPositions(result.Document)
+	err = encoder.Encode(&result.Root)
+
+	var got bytes.Buffer
+	if err == nil {
+		err = json.Compact(&got, buffer.Bytes())
+	}
+
+	want := `{"name":"p","position":{"start":0,"end":9,"line":1,"column":1,"depth":0},"attributes":{},"children":[` +
+		`{"text":"Hi","position":{"start":3,"end":5,"line":1,"column":4,"depth":1}}]}`
+	if err != nil || got.String() != want {
+		t.Errorf("Structured positions were incorrect (%v). \nGot:\n%v\nWant:\n%v", err, got.String(), want)
+	}
+
+	encoder.SetFormat(JsonFormatJsonML)
+	err = encoder.Encode(&result.Root)
+	if err == nil || !strings.Contains(err.Error(), "positions are not supported by the jsonml json format") {
+		t.Errorf("Expected an unsupported positions error. Got %v", err)
+	}
+}
+
+func TestJsonEncoder_SetIndent(t *testing.T) {
+	tag := &Tag{Name: "a", Attributes: map[string]string{"x": "1"}, Children: []Tag{{Name: "b"}}}
+
+	type Def struct {
+		indent string
+		want   string
+	}
+
+	test_defs := []Def{
+		{indent: "", want: `{"_name":"a","x":"1","_children":[{"_name":"b"}]}` + "\n"},
+		{indent: "\t", want: "{\n\t\"_name\": \"a\",\n\t\"x\": \"1\",\n\t\"_children\": [\n\t\t{\n\t\t\t\"_name\": \"b\"\n\t\t}\n\t]\n}\n"},
+	}
+
+	for _, def := range test_defs {
+		var buffer bytes.Buffer
+		encoder := NewJsonEncoder(&buffer)
+		encoder.SetIndent(def.indent)
+		err := encoder.Encode(tag)
+		if err != nil || buffer.String() != def.want {
+			t.Errorf("Incorrect output for indent %q (%v). \nGot:\n%v\nWant:\n%v", def.indent, err, buffer.String(), def.want)
+		}
+	}
+}
+
+func TestJsonEncoder_LineIndexPositionsAreWithinTheInput(t *testing.T) {
+	input := []rune("\n  <p>Hi</p>")
+	result, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	encoder := NewJsonEncoder(&buffer)
+	encoder.SetIndent("")
+	encoder.SetLineIndex(result.LineIndex())
+	if err := encoder.Encode(&result.Root); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	want := `{"_name":"p","_start":3,"_end":12,"_line":2,"_column":3,"_depth":0,"_children":[` +
+		`{"_name":"<text>","_start":6,"_end":8,"_line":2,"_column":6,"_depth":1,"text":"Hi"}]}` + "\n"
+	if buffer.String() != want {
+		t.Errorf("Incorrect positions with leading whitespace. \nGot:\n%v\nWant:\n%v", buffer.String(), want)
+	}
+	if got := string(input[3:12]); got != "<p>Hi</p>" {
+		t.Errorf("The offsets should index the input. Got %q", got)
+	}
+}
+
+func TestJsonEncoder_WritesNewlineDelimitedJson(t *testing.T) {
+	result, err := Parse([]rune("<ul>\n<li id='1'>One</li>\n<li id='2'>Two</li>\n</ul>"))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	var buffer bytes.Buffer
+	encoder := NewJsonEncoder(&buffer)
+	encoder.SetIndent("")
+	encoder.SetPositions(result.Document)
+	for idx := range result.Root.Children {
+		if err := encoder.Encode(&result.Root.Children[idx]); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+
+	want := `{"_name":"li","_start":5,"_end":24,"_line":2,"_column":1,"_depth":1,"id":"1","_children":[{"_name":"<text>","_start":16,"_end":19,"_line":2,"_column":12,"_depth":2,"text":"One"}]}` + "\n" +
+		`{"_name":"li","_start":25,"_end":44,"_line":3,"_column":1,"_depth":1,"id":"2","_children":[{"_name":"<text>","_start":36,"_end":39,"_line":3,"_column":12,"_depth":2,"text":"Two"}]}` + "\n"
+	if buffer.String() != want {
+		t.Errorf("Incorrect newline delimited output. \nGot:\n%v\nWant:\n%v", buffer.String(), want)
 	}
 }
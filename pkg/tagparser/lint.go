@@ -0,0 +1,214 @@
+package tagparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// The rules checked by Lint. Each LintIssue reports the rule it broke
+const (
+	LintRuleRequiredAttribute = "required-attribute"
+	LintRuleDisallowedTag     = "disallowed-tag"
+	LintRuleMaxDepth          = "max-depth"
+	LintRuleDuplicateId       = "duplicate-id"
+	LintRuleEmptyElement      = "empty-element"
+	LintRuleTagName           = "tag-name"
+	LintRuleAttributeName     = "attribute-name"
+)
+
+// LintConfig chooses the rules checked by Lint. The zero value checks nothing. Tag names are matched case-insensitively
+type LintConfig struct {
+	// The attributes each tag must have, i.e. {"img": ["alt"]}
+	RequiredAttributes map[string][]string `json:"requiredAttributes,omitempty"`
+	// Tags which may not be used, i.e. ["font", "center"]
+	DisallowedTags []string `json:"disallowedTags,omitempty"`
+	// The deepest an element may be nested, where the root has a depth of 0. 0 disables the check
+	MaxDepth int `json:"maxDepth,omitempty"`
+	// Report elements whose id attribute was already used by another element
+	UniqueIds bool `json:"uniqueIds,omitempty"`
+	// Report elements without any children, except those in AllowEmpty
+	EmptyElements bool     `json:"emptyElements,omitempty"`
+	AllowEmpty    []string `json:"allowEmpty,omitempty"`
+	// The naming convention of tag and attribute names. Either one of the NamingConventions or a regular expression
+	TagNames       string `json:"tagNames,omitempty"`
+	AttributeNames string `json:"attributeNames,omitempty"`
+}
+
+// NamingConventions are the named patterns which may be used for LintConfig.TagNames and LintConfig.AttributeNames
+var NamingConventions = map[string]string{
+	"lowercase":  `^[^\p{Lu}]*$`,
+	"kebab-case": `^[a-z][a-z0-9]*(-[a-z0-9]+)*$`,
+	"snake_case": `^[a-z][a-z0-9]*(_[a-z0-9]+)*$`,
+	"camelCase":  `^[a-z][a-zA-Z0-9]*$`,
+	"PascalCase": `^[A-Z][a-zA-Z0-9]*$`,
+}
+
+// DefaultLintConfig: The rules used when no configuration is provided - Duplicate ids, and images without alt text
+func DefaultLintConfig() LintConfig {
+	return LintConfig{
+		RequiredAttributes: map[string][]string{"img": {"alt"}},
+		UniqueIds:          true,
+	}
+}
+
+// ParseLintConfig: Read a JSON LintConfig. Unknown properties are an error, so misspelt rules aren't silently ignored
+func ParseLintConfig(r io.Reader) (LintConfig, error) {
+	var config LintConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return LintConfig{}, fmt.Errorf("invalid lint config - %v", err)
+	}
+	return config, nil
+}
+
+// LintIssue is a single problem found by Lint
+type LintIssue struct {
+	// The rule which was broken, i.e. LintRuleRequiredAttribute
+	Rule    string
+	Message string
+	// The offsets of the offending tag - [StartIdx, EndIdx)
+	StartIdx int
+	EndIdx   int
+	// The position of StartIdx within the input passed to Parse
+	Position Position
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%v:%v: %v (%v)", i.Position.Line, i.Position.Column, i.Message, i.Rule)
+}
+
+// Linter is a compiled LintConfig, which can be reused across documents
+type Linter struct {
+	config             LintConfig
+	requiredAttributes map[string][]string
+	disallowedTags     map[string]bool
+	allowEmpty         map[string]bool
+	tagNames           *regexp.Regexp
+	attributeNames     *regexp.Regexp
+}
+
+// NewLinter: Compile a LintConfig. Returns an error if a naming convention is not a valid regular expression
+func NewLinter(config LintConfig) (*Linter, error) {
+	l := &Linter{
+		config:             config,
+		requiredAttributes: map[string][]string{},
+		disallowedTags:     lowerSet(config.DisallowedTags),
+		allowEmpty:         lowerSet(config.AllowEmpty),
+	}
+
+	for name, attributes := range config.RequiredAttributes {
+		name = strings.ToLower(name)
+		l.requiredAttributes[name] = append(l.requiredAttributes[name], attributes...)
+	}
+
+	var err error
+	if l.tagNames, err = compileNamingConvention(config.TagNames); err != nil {
+		return nil, fmt.Errorf("invalid tagNames - %v", err)
+	}
+	if l.attributeNames, err = compileNamingConvention(config.AttributeNames); err != nil {
+		return nil, fmt.Errorf("invalid attributeNames - %v", err)
+	}
+
+	return l, nil
+}
+
+// Lint: Check a parsed document against the rules of a LintConfig. The issues are in document order
+func Lint(result ParseResult, config LintConfig) ([]LintIssue, error) {
+	linter, err := NewLinter(config)
+	if err != nil {
+		return nil, err
+	}
+	return linter.Lint(result), nil
+}
+
+// Lint: Check a parsed document. The issues are in document order, with the issues of a single tag in the order the
+// rules are listed above
+func (l *Linter) Lint(result ParseResult) []LintIssue {
+	lineIndex := result.LineIndex()
+	issues := make([]LintIssue, 0)
+	report := func(tag *Tag, rule string, format string, args ...any) {
+		issues = append(issues, LintIssue{
+			Rule:     rule,
+			Message:  fmt.Sprintf(format, args...),
+			StartIdx: tag.StartIdx,
+			EndIdx:   tag.EndIdx,
+			Position: lineIndex.Position(tag.StartIdx),
+		})
+	}
+
+	ids := map[string]*Tag{}
+	enter := func(tag *Tag, ancestors []*Tag) error {
+		if tag.Name == TextTagName {
+			return SkipChildren
+		}
+
+		name := strings.ToLower(tag.Name)
+		for _, attribute := range l.requiredAttributes[name] {
+			if _, ok := tag.Attributes[attribute]; !ok {
+				report(tag, LintRuleRequiredAttribute, "<%v> is missing the required %v attribute", tag.Name, attribute)
+			}
+		}
+
+		if l.disallowedTags[name] {
+			report(tag, LintRuleDisallowedTag, "<%v> is not allowed", tag.Name)
+		}
+
+		if depth := len(ancestors); l.config.MaxDepth > 0 && depth > l.config.MaxDepth {
+			report(tag, LintRuleMaxDepth, "<%v> is nested %v deep, more than the maximum of %v", tag.Name, depth, l.config.MaxDepth)
+		}
+
+		if id, ok := tag.Attributes["id"]; ok && l.config.UniqueIds {
+			if first, seen := ids[id]; seen {
+				report(tag, LintRuleDuplicateId, "duplicate id %q, already used by an earlier <%v>", id, first.Name)
+			} else {
+				ids[id] = tag
+			}
+		}
+
+		if l.config.EmptyElements && len(tag.Children) == 0 && !l.allowEmpty[name] {
+			report(tag, LintRuleEmptyElement, "<%v> is empty", tag.Name)
+		}
+
+		if l.tagNames != nil && tag.Name != "" && !l.tagNames.MatchString(tag.Name) {
+			report(tag, LintRuleTagName, "tag name %q doesn't match %v", tag.Name, l.config.TagNames)
+		}
+
+		if l.attributeNames != nil {
+			for _, attribute := range sortedAttributeKeys(tag) {
+				if !l.attributeNames.MatchString(attribute) {
+					report(tag, LintRuleAttributeName, "attribute name %q doesn't match %v", attribute, l.config.AttributeNames)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	exit := func(tag *Tag, ancestors []*Tag) error { return nil }
+	walk(&result.Root, enter, exit)
+	return issues
+}
+
+// compileNamingConvention: Compile a named convention or regular expression. The empty string disables the check
+func compileNamingConvention(convention string) (*regexp.Regexp, error) {
+	if convention == "" {
+		return nil, nil
+	}
+
+	if pattern, ok := NamingConventions[convention]; ok {
+		return regexp.Compile(pattern)
+	}
+	return regexp.Compile(convention)
+}
+
+func lowerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
@@ -0,0 +1,111 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func lintDocument(t *testing.T, document string, config LintConfig) []string {
+	t.Helper()
+	result, err := Parse([]rune(document))
+	if err != nil {
+		t.Fatalf("Failed to parse %q - %v", document, err)
+	}
+
+	issues, err := Lint(result, config)
+	if err != nil {
+		t.Fatalf("Lint failed - %v", err)
+	}
+
+	got := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	return got
+}
+
+func TestLint_ZeroConfigReportsNothing(t *testing.T) {
+	got := lintDocument(t, `<div id="a"><img/><p id="a"></p></div>`, LintConfig{})
+	if len(got) != 0 {
+		t.Errorf("Lint with the zero config should report nothing. Got %v", got)
+	}
+}
+
+func TestLint_Rules(t *testing.T) {
+	document := strings.Join([]string{
+		`<body>`,
+		`  <img src="a.png"/>`,
+		`  <IMG alt="b"/>`,
+		`  <font id="x">Old</font>`,
+		`  <div id="x"><p><span></span></p></div>`,
+		`  <myTag data_value="1">Hi</myTag>`,
+		`</body>`,
+	}, "\n")
+
+	config := LintConfig{
+		RequiredAttributes: map[string][]string{"IMG": {"alt"}},
+		DisallowedTags:     []string{"font"},
+		MaxDepth:           2,
+		UniqueIds:          true,
+		EmptyElements:      true,
+		AllowEmpty:         []string{"img"},
+		TagNames:           "lowercase",
+		AttributeNames:     "kebab-case",
+	}
+
+	got := lintDocument(t, document, config)
+	want := []string{
+		`2:3: <img> is missing the required alt attribute (required-attribute)`,
+		`3:3: tag name "IMG" doesn't match lowercase (tag-name)`,
+		`4:3: <font> is not allowed (disallowed-tag)`,
+		`5:3: duplicate id "x", already used by an earlier <font> (duplicate-id)`,
+		`5:18: <span> is nested 3 deep, more than the maximum of 2 (max-depth)`,
+		`5:18: <span> is empty (empty-element)`,
+		`6:3: tag name "myTag" doesn't match lowercase (tag-name)`,
+		`6:3: attribute name "data_value" doesn't match kebab-case (attribute-name)`,
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lint reported the wrong issues (-want +got):\n%v", diff)
+	}
+}
+
+func TestLint_PositionsCountLeadingWhitespace(t *testing.T) {
+	got := lintDocument(t, "\n\n  <div>\n  <font />\n</div>", LintConfig{DisallowedTags: []string{"font"}})
+	want := []string{`4:3: <font> is not allowed (disallowed-tag)`}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Lint positions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLint_RegularExpressionNames(t *testing.T) {
+	got := lintDocument(t, `<x-root><x-item/><item/></x-root>`, LintConfig{TagNames: `^x-`})
+	want := []string{`1:18: tag name "item" doesn't match ^x- (tag-name)`}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Lint with a regular expression. Got %v Want %v", got, want)
+	}
+}
+
+func TestNewLinter_InvalidConvention(t *testing.T) {
+	if _, err := NewLinter(LintConfig{AttributeNames: "("}); err == nil {
+		t.Errorf("NewLinter should fail for an invalid regular expression")
+	}
+}
+
+func TestParseLintConfig(t *testing.T) {
+	got, err := ParseLintConfig(strings.NewReader(`{"requiredAttributes": {"a": ["href"]}, "maxDepth": 4}`))
+	if err != nil {
+		t.Fatalf("ParseLintConfig failed - %v", err)
+	}
+
+	want := LintConfig{RequiredAttributes: map[string][]string{"a": {"href"}}, MaxDepth: 4}
+	if !cmp.Equal(got, want) {
+		t.Errorf("ParseLintConfig. Got %v Want %v", got, want)
+	}
+
+	if _, err := ParseLintConfig(strings.NewReader(`{"maxDepht": 4}`)); err == nil {
+		t.Errorf("ParseLintConfig should fail for unknown rules")
+	}
+}
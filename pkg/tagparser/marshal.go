@@ -0,0 +1,310 @@
+package tagparser
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TagMarshaler is implemented by types which build their own tag when marshalled.
+// When the type is stored in a struct field, the returned tag is renamed to match the field
+type TagMarshaler interface {
+	MarshalTag() (Tag, error)
+}
+
+var (
+	tagMarshalerType  = reflect.TypeOf((*TagMarshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Marshal: Write a struct as a tag document. See MarshalTag
+func Marshal(v any) ([]byte, error) {
+	tag, err := MarshalTag(v)
+	if err != nil {
+		return nil, err
+	}
+
+	markup, err := SerializeToString(&tag)
+	return []byte(markup), err
+}
+
+// MarshalIndent: Write a struct as a tag document, laid out by FormatTag with the given options. See MarshalTag
+func MarshalIndent(v any, opts FormatOptions) ([]byte, error) {
+	tag, err := MarshalTag(v)
+	if err != nil {
+		return nil, err
+	}
+
+	markup, err := FormatTag(&tag, opts)
+	return []byte(markup), err
+}
+
+// MarshalTag: Build a tree from a struct (or a pointer to one), using the same `tag:"..."` struct tags as UnmarshalTag.
+//
+// The root element is named by the struct's name field (`tag:",name"`) if it is set, then the name within that
+// field's struct tag, and then the struct's type name. Each field is written as:
+//   - (no option): A child element named after the field, containing the value's text or, for structs, it's fields.
+//     Each element of a slice is written as a separate child
+//   - attr: An attribute. The elements of slices are joined by a space
+//   - text: The tag's text content, written before any child elements
+//   - children: The elements of a slice, each named as a root element would be. A []Tag is written as-is
+//
+// The omitempty option leaves out fields with zero values, and empty slices. Nil pointers are always left out.
+// Types implementing TagMarshaler build their own tag, and values are converted to text as UnmarshalTag reads them
+// (including time.Duration and types implementing encoding.TextMarshaler). The built tags have synthetic positions
+func MarshalTag(v any) (Tag, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return Tag{}, fmt.Errorf("cannot marshal nil")
+	}
+
+	// Copy the value, so that the methods of pointer receivers can be called
+	addressable := reflect.New(rv.Type()).Elem()
+	addressable.Set(rv)
+
+	tag, ok, err := marshalElement(addressable, "", typeName(rv.Type()))
+	if err != nil {
+		return Tag{}, err
+	}
+	if !ok {
+		return Tag{}, fmt.Errorf("cannot marshal a nil %v", rv.Type())
+	}
+
+	setDepth(&tag, 0)
+	return tag, nil
+}
+
+// marshalElement: Build the element for a value. An empty name is taken from the value itself. Returns false for nil
+// values, which have no element
+func marshalElement(v reflect.Value, name string, fieldPath string) (Tag, bool, error) {
+	v, ok := dereference(v)
+	if !ok {
+		return Tag{}, false, nil
+	}
+
+	if marshaler, ok := asInterface(v, tagMarshalerType).(TagMarshaler); ok {
+		tag, err := marshaler.MarshalTag()
+		if err != nil {
+			return Tag{}, false, fmt.Errorf("cannot marshal %v (%v) - %w", fieldPath, v.Type(), err)
+		}
+		adopt(&tag, 0)
+		if name != "" {
+			tag.Name = name
+		}
+		return tag, true, nil
+	}
+
+	switch {
+	case v.Type() == tagType:
+		// Copy the tag, as its children are shared with the caller's tree, and give the copy synthetic positions, as
+		// any it has refer to the document it came from
+		tag := v.Interface().(Tag)
+		adopt(&tag, 0)
+		if name != "" && tag.Name != TextTagName {
+			tag.Name = name
+		}
+		return tag, true, nil
+	case v.Kind() == reflect.Struct && asInterface(v, textMarshalerType) == nil:
+		return marshalStruct(v, name, fieldPath)
+	}
+
+	text, err := marshalText(v)
+	if err != nil {
+		return Tag{}, false, fmt.Errorf("cannot marshal %v (%v) - %w", fieldPath, v.Type(), err)
+	}
+	if name == "" {
+		name = v.Type().Name()
+	}
+
+	tag := NewElement(name)
+	if text != "" {
+		tag.AppendChild(NewText(text))
+	}
+	return tag, true, nil
+}
+
+func marshalStruct(v reflect.Value, name string, fieldPath string) (Tag, bool, error) {
+	fields, err := tagFields(v.Type())
+	if err != nil {
+		return Tag{}, false, err
+	}
+
+	if name == "" {
+		name = v.Type().Name()
+		for _, field := range fields {
+			if field.option != "name" {
+				continue
+			}
+
+			name = field.name
+			if fv, err := v.FieldByIndexErr(field.index); err == nil && fv.Kind() == reflect.String && fv.String() != "" {
+				name = fv.String()
+			}
+		}
+	}
+
+	tag := NewElement(name)
+	for _, field := range fields {
+		fv, err := v.FieldByIndexErr(field.index)
+		fieldName := fieldPath + "." + v.Type().FieldByIndex(field.index).Name
+		switch {
+		case err != nil:
+			// The field is promoted from a nil embedded struct pointer
+			continue
+		case field.omitEmpty && isEmptyValue(fv):
+			continue
+		}
+
+		switch field.option {
+		case "name":
+		case "attr":
+			value, ok, err := marshalAttribute(fv)
+			if err != nil {
+				return Tag{}, false, fmt.Errorf("cannot marshal %v (%v) - %w", fieldName, fv.Type(), err)
+			}
+			if !ok {
+				continue
+			}
+			if err := tag.SetAttr(field.name, value); err != nil {
+				return Tag{}, false, fmt.Errorf("cannot marshal %v - %w", fieldName, err)
+			}
+		case "text":
+			text, err := marshalText(fv)
+			if err != nil {
+				return Tag{}, false, fmt.Errorf("cannot marshal %v (%v) - %w", fieldName, fv.Type(), err)
+			}
+			if text != "" {
+				// Text is written before the children, wherever the field is
+				tag.Children = append([]Tag{NewText(text)}, tag.Children...)
+			}
+		case "children":
+			if fv.Kind() != reflect.Slice {
+				return Tag{}, false, fmt.Errorf("cannot marshal %v (%v) - children must be stored in a slice", fieldName, fv.Type())
+			}
+			if err := appendElements(&tag, fv, "", fieldName); err != nil {
+				return Tag{}, false, err
+			}
+		default:
+			if fv.Kind() == reflect.Slice && asInterface(fv, textMarshalerType) == nil {
+				if err := appendElements(&tag, fv, field.name, fieldName); err != nil {
+					return Tag{}, false, err
+				}
+				continue
+			}
+
+			child, ok, err := marshalElement(fv, field.name, fieldName)
+			if err != nil {
+				return Tag{}, false, err
+			}
+			if ok {
+				tag.AppendChild(child)
+			}
+		}
+	}
+	return tag, true, nil
+}
+
+// appendElements: Append an element for each element of a slice
+func appendElements(tag *Tag, slice reflect.Value, name string, fieldPath string) error {
+	for idx := 0; idx < slice.Len(); idx++ {
+		child, ok, err := marshalElement(slice.Index(idx), name, fieldPath)
+		if err != nil {
+			return err
+		}
+		if ok {
+			tag.AppendChild(child)
+		}
+	}
+	return nil
+}
+
+// marshalAttribute: Convert a value to an attribute value, joining the elements of slices with a space. Returns false
+// for nil values, which have no attribute
+func marshalAttribute(v reflect.Value) (string, bool, error) {
+	v, ok := dereference(v)
+	if !ok {
+		return "", false, nil
+	}
+
+	if v.Kind() != reflect.Slice || asInterface(v, textMarshalerType) != nil {
+		text, err := marshalText(v)
+		return text, true, err
+	}
+
+	words := make([]string, 0, v.Len())
+	for idx := 0; idx < v.Len(); idx++ {
+		word, err := marshalText(v.Index(idx))
+		if err != nil {
+			return "", false, err
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), true, nil
+}
+
+// marshalText: Convert a value to text. Nil values are empty
+func marshalText(v reflect.Value) (string, error) {
+	v, ok := dereference(v)
+	if !ok {
+		return "", nil
+	}
+
+	if marshaler, ok := asInterface(v, textMarshalerType).(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported type %v", v.Type())
+	}
+}
+
+// dereference: Follow pointers and interfaces to the value they hold. Returns false if any are nil
+func dereference(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// asInterface: The value (or a pointer to it, for methods with pointer receivers) if it implements the interface type,
+// otherwise nil
+func asInterface(v reflect.Value, interfaceType reflect.Type) any {
+	switch {
+	case v.Type().Implements(interfaceType):
+		return v.Interface()
+	case v.CanAddr() && reflect.PointerTo(v.Type()).Implements(interfaceType):
+		return v.Addr().Interface()
+	}
+	return nil
+}
+
+// isEmptyValue: Check if a value is left out by the omitempty option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,187 @@
+package tagparser
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+// MarshalTag: Points are written as a single attribute, i.e. <point at="1,2" />
+func (p testPoint) MarshalTag() (Tag, error) {
+	if p.X < 0 {
+		return Tag{}, errors.New("negative x")
+	}
+
+	tag := NewElement("point")
+	err := tag.SetAttr("at", fmt.Sprintf("%v,%v", p.X, p.Y))
+	return tag, err
+}
+
+type testService struct {
+	Kind     string        `tag:"service,name"`
+	Name     string        `tag:"name,attr"`
+	Port     int           `tag:"port,attr,omitempty"`
+	Tags     []string      `tag:"tags,attr,omitempty"`
+	Enabled  *bool         `tag:"enabled,attr"`
+	Summary  string        `tag:",text"`
+	Hosts    []string      `tag:"host"`
+	Timeout  time.Duration `tag:"timeout,omitempty"`
+	Backup   *testService  `tag:"backup"`
+	Location testPoint     `tag:"location"`
+}
+
+func TestMarshal(t *testing.T) {
+	enabled := false
+	service := testService{
+		Name:     `"quoted"`,
+		Tags:     []string{"web", "public"},
+		Enabled:  &enabled,
+		Summary:  "The <main> service",
+		Hosts:    []string{"a.example.com", "b.example.com"},
+		Timeout:  90 * time.Second,
+		Backup:   &testService{Kind: "standby", Name: "spare"},
+		Location: testPoint{1, 2},
+	}
+
+	got, err := Marshal(&service)
+	want := `<service enabled="false" name='"quoted"' tags="web public">The &lt;main> service` +
+		`<host>a.example.com</host><host>b.example.com</host><timeout>1m30s</timeout>` +
+		`<backup name="spare"><location at="0,0" /></backup>` +
+		`<location at="1,2" /></service>`
+	if err != nil || string(got) != want {
+		t.Errorf("Marshal. Got\n%s (%v)\nWant\n%v", got, err, want)
+	}
+}
+
+func TestMarshal_Indent(t *testing.T) {
+	got, err := MarshalIndent(testService{Name: "a", Hosts: []string{"a.example.com"}}, FormatOptions{Indent: "  "})
+	want := `<service name="a">
+  <host>a.example.com</host>
+  <location at="0,0" />
+</service>
+`
+	if err != nil || string(got) != want {
+		t.Errorf("MarshalIndent. Got\n%s (%v)\nWant\n%v", got, err, want)
+	}
+}
+
+func TestMarshal_LeavesTagsUnchanged(t *testing.T) {
+	type doc struct {
+		Body  Tag   `tag:"body"`
+		Items []Tag `tag:",children"`
+	}
+
+	source := []rune(`<main><p class="a">One<b>Two</b></p></main>`)
+	parse := func() Tag {
+		result, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Failed to parse - %v", err)
+		}
+		return result.Root
+	}
+
+	want := parse()
+	root := parse()
+	if _, err := Marshal(doc{Body: root, Items: root.Children}); err != nil {
+		t.Fatalf("Marshal failed - %v", err)
+	}
+	if _, err := Marshal(root.Children[0]); err != nil {
+		t.Fatalf("Marshal failed - %v", err)
+	}
+
+	if diff := cmp.Diff(want, root); diff != "" {
+		t.Errorf("Marshal changed its input (-want +got):\n%v", diff)
+	}
+
+	// The marshalled copies mustn't keep positions within the source document, whether they are the root or a field
+	for _, v := range []any{root.Children[0], doc{Body: root}} {
+		marshalled, err := MarshalTag(v)
+		if err != nil {
+			t.Fatalf("MarshalTag failed - %v", err)
+		}
+
+		walk(&marshalled, func(tag *Tag, ancestors []*Tag) error {
+			if !tag.IsSynthetic() {
+				t.Errorf("Marshalled <%v> should have a synthetic position. Got [%v, %v)", tag.Name, tag.StartIdx, tag.EndIdx)
+			}
+			return nil
+		}, func(tag *Tag, ancestors []*Tag) error { return nil })
+	}
+
+	marshalled, _ := MarshalTag(root.Children[0])
+	if got, want := marshalled.Children[1].Render(source), `<b>Two</b>`; got != want {
+		t.Errorf("Render of a marshalled tag. Got %v Want %v", got, want)
+	}
+}
+
+func TestMarshal_RoundTrip(t *testing.T) {
+	type item struct {
+		Kind  string  `tag:",name"`
+		Label string  `tag:",text"`
+		Price float64 `tag:"price,attr"`
+	}
+	type catalog struct {
+		Name    string   `tag:"catalog,name"`
+		Version uint16   `tag:"version,attr"`
+		Owners  []string `tag:"owner"`
+		Items   []item   `tag:",children"`
+	}
+
+	want := catalog{
+		Name:    "catalog",
+		Version: 3,
+		Owners:  []string{"ann", "bob"},
+		Items:   []item{{"book", "Go", 12.5}, {"pen", "Blue", 0.99}},
+	}
+
+	markup, err := Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal failed - %v", err)
+	}
+
+	// The owners are children too, so are read into both fields
+	var got catalog
+	if err := Unmarshal([]rune(string(markup)), &got); err != nil {
+		t.Fatalf("Unmarshal of %s failed - %v", markup, err)
+	}
+	want.Items = append([]item{{Kind: "owner", Label: "ann"}, {Kind: "owner", Label: "bob"}}, want.Items...)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Round trip of %s mismatch (-want +got):\n%s", markup, diff)
+	}
+}
+
+func TestMarshal_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		v    any
+	}{
+		{"nil", nil},
+		{"nil pointer", (*testService)(nil)},
+		{"unsupported type", struct{ Values map[string]int }{map[string]int{"a": 1}}},
+		{"marshaler error", testService{Location: testPoint{X: -1}}},
+		{"invalid attribute name", struct {
+			Value string `tag:"bad name,attr"`
+		}{"x"}},
+		{"conflicting options", struct {
+			Value string `tag:"value,attr,text"`
+		}{"x"}},
+	}
+
+	for _, c := range cases {
+		if got, err := Marshal(c.v); err == nil {
+			t.Errorf("%v: Marshal should fail. Got %s", c.name, got)
+		}
+	}
+
+	_, err := Marshal(cases[2].v)
+	if want := "cannot marshal struct {...}.Values (map[string]int) - unsupported type map[string]int"; err == nil || err.Error() != want {
+		t.Errorf("Marshal error for an unnamed struct. Got %v Want %v", err, want)
+	}
+}
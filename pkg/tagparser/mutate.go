@@ -0,0 +1,205 @@
+package tagparser
+
+import (
+	"fmt"
+)
+
+// SyntheticIdx is the StartIdx and EndIdx of tags built in code, which have no position within a document
+const SyntheticIdx = -1
+
+// NewElement: Build an element with the given children. The element has a Depth of 0 (the depths of the children are
+// updated to match) and synthetic positions
+func NewElement(name string, children ...Tag) Tag {
+	tag := Tag{Name: name, StartIdx: SyntheticIdx, EndIdx: SyntheticIdx}
+	for _, child := range children {
+		tag.AppendChild(child)
+	}
+	return tag
+}
+
+// NewText: Build a <text> pseudo tag with synthetic positions
+func NewText(text string) Tag {
+	return Tag{
+		Name:       TextTagName,
+		StartIdx:   SyntheticIdx,
+		EndIdx:     SyntheticIdx,
+		Attributes: map[string]string{TextAttributeName: text},
+	}
+}
+
+// IsSynthetic: Check if the tag was built in code, so has no position within a document
+func (t *Tag) IsSynthetic() bool {
+	return t.StartIdx < 0 || t.EndIdx < t.StartIdx
+}
+
+// Note that the mutation methods below update the Depth of any tag they add to the tree, and give the added tags
+// (and the tag being edited) synthetic positions, as their markup no longer matches the document. The ancestors of an
+// edited tag keep their positions, but Render serializes any tag with a synthetic descendant, so reflects the edit.
+//
+// Added tags are deep copied, so the tree they came from is left unchanged. Children are stored by value, so adding or
+// removing a child may invalidate pointers to the tag's other children.
+
+// AppendChild: Add a child after the tag's existing children. Returns a pointer to the added child within the tree
+func (t *Tag) AppendChild(child Tag) *Tag {
+	adopt(&child, t.Depth+1)
+	t.markSynthetic()
+	t.Children = append(t.Children, child)
+	return &t.Children[len(t.Children)-1]
+}
+
+// InsertBefore: Add a child before the reference child, which must be one of the tag's children. A nil reference
+// appends the child. Returns a pointer to the added child within the tree
+func (t *Tag) InsertBefore(child Tag, reference *Tag) (*Tag, error) {
+	if reference == nil {
+		return t.AppendChild(child), nil
+	}
+
+	idx := t.childIndex(reference)
+	if idx == -1 {
+		return nil, fmt.Errorf("the reference tag is not a child of <%v>", t.Name)
+	}
+
+	adopt(&child, t.Depth+1)
+	t.markSynthetic()
+	t.Children = append(t.Children, Tag{})
+	copy(t.Children[idx+1:], t.Children[idx:])
+	t.Children[idx] = child
+	return &t.Children[idx], nil
+}
+
+// RemoveChild: Remove one of the tag's children, returning the removed child
+func (t *Tag) RemoveChild(child *Tag) (Tag, error) {
+	idx := t.childIndex(child)
+	if idx == -1 {
+		return Tag{}, fmt.Errorf("the tag is not a child of <%v>", t.Name)
+	}
+
+	removed := t.Children[idx]
+	t.markSynthetic()
+	t.Children = append(t.Children[:idx], t.Children[idx+1:]...)
+	if len(t.Children) == 0 {
+		t.Children = nil
+	}
+	return removed, nil
+}
+
+// ReplaceWith: Replace the tag (and it's descendants) with another tag, keeping the tag's Depth. As the tag is
+// replaced in place, this also replaces it within it's parent's children. The replacement is given synthetic
+// positions, as any it has refer to the document it came from
+func (t *Tag) ReplaceWith(replacement Tag) {
+	adopt(&replacement, t.Depth)
+	*t = replacement
+}
+
+// SetAttr: Set the value of an attribute. Text tags cannot have attributes, and nor can nameless tags
+func (t *Tag) SetAttr(name string, value string) error {
+	switch {
+	case t.Name == TextTagName:
+		return fmt.Errorf("text tags cannot have attributes. use SetText to change the text")
+	case t.Name == "":
+		return fmt.Errorf("nameless tags cannot have attributes")
+	case !isValidSerializedName(name, false):
+		return fmt.Errorf("invalid attribute name %q", name)
+	}
+
+	if t.Attributes == nil {
+		t.Attributes = map[string]string{}
+	}
+	t.Attributes[name] = value
+	t.markSynthetic()
+	return nil
+}
+
+// RemoveAttr: Remove an attribute, if the tag has it. Text tags cannot have attributes
+func (t *Tag) RemoveAttr(name string) error {
+	if t.Name == TextTagName {
+		return fmt.Errorf("text tags cannot have attributes. use SetText to change the text")
+	}
+
+	if _, ok := t.Attributes[name]; ok {
+		t.markSynthetic()
+	}
+	delete(t.Attributes, name)
+	if len(t.Attributes) == 0 {
+		t.Attributes = nil
+	}
+	return nil
+}
+
+// SetText: Change the text of a text tag. For elements, all of the children are replaced by a single text tag
+// (or removed, when the text is empty)
+func (t *Tag) SetText(text string) {
+	if t.Name == TextTagName {
+		if t.Attributes == nil {
+			t.Attributes = map[string]string{}
+		}
+		t.Attributes[TextAttributeName] = text
+		t.markSynthetic()
+		return
+	}
+
+	t.Children = nil
+	t.markSynthetic()
+	if text != "" {
+		t.AppendChild(NewText(text))
+	}
+}
+
+// childIndex: The index of a child, found by identity. Returns -1 if the tag isn't one of t's children
+func (t *Tag) childIndex(child *Tag) int {
+	for idx := range t.Children {
+		if &t.Children[idx] == child {
+			return idx
+		}
+	}
+	return -1
+}
+
+// markSynthetic: Give the tag synthetic positions, as it's markup has been edited
+func (t *Tag) markSynthetic() {
+	t.StartIdx = SyntheticIdx
+	t.EndIdx = SyntheticIdx
+}
+
+// adopt: Prepare a tag for adding to a tree at the given depth. The tag and it's descendants are copied, so that the
+// tree they came from is left unchanged, then given synthetic positions, and depths to match
+func adopt(tag *Tag, depth int) {
+	*tag = cloneTag(*tag)
+	enter := func(t *Tag, ancestors []*Tag) error {
+		t.Depth = depth + len(ancestors)
+		t.markSynthetic()
+		return nil
+	}
+	exit := func(t *Tag, ancestors []*Tag) error { return nil }
+	walk(tag, enter, exit)
+}
+
+// cloneTag: Deep copy a tag, so that the copy shares no children or attributes with the original
+func cloneTag(tag Tag) Tag {
+	if tag.Attributes != nil {
+		attributes := make(map[string]string, len(tag.Attributes))
+		for key, value := range tag.Attributes {
+			attributes[key] = value
+		}
+		tag.Attributes = attributes
+	}
+
+	if tag.Children != nil {
+		children := make([]Tag, len(tag.Children))
+		for idx := range tag.Children {
+			children[idx] = cloneTag(tag.Children[idx])
+		}
+		tag.Children = children
+	}
+	return tag
+}
+
+// setDepth: Set the depth of a tag, and the depths of it's descendants to match
+func setDepth(tag *Tag, depth int) {
+	enter := func(t *Tag, ancestors []*Tag) error {
+		t.Depth = depth + len(ancestors)
+		return nil
+	}
+	exit := func(t *Tag, ancestors []*Tag) error { return nil }
+	walk(tag, enter, exit)
+}
@@ -0,0 +1,196 @@
+package tagparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+// checkDepths: Check that the depth of every tag in a tree matches it's nesting
+func checkDepths(t *testing.T, root *Tag) {
+	t.Helper()
+	enter := func(tag *Tag, ancestors []*Tag) error {
+		if want := root.Depth + len(ancestors); tag.Depth != want {
+			t.Errorf("<%v> has a depth of %v, want %v", tag.Name, tag.Depth, want)
+		}
+		return nil
+	}
+	exit := func(tag *Tag, ancestors []*Tag) error { return nil }
+	walk(root, enter, exit)
+}
+
+func TestNewElement_BuildsSerializableTrees(t *testing.T) {
+	list := NewElement("ul",
+		NewElement("li", NewText("One")),
+		NewElement("li", NewText("Two")))
+	if err := list.SetAttr("class", "numbers"); err != nil {
+		t.Fatalf("SetAttr failed - %v", err)
+	}
+
+	got, err := SerializeToString(&list)
+	want := `<ul class="numbers"><li>One</li><li>Two</li></ul>`
+	if err != nil || got != want {
+		t.Errorf("Serialize of a built tree. Got %v (%v) Want %v", got, err, want)
+	}
+
+	if !list.IsSynthetic() || !list.Children[0].Children[0].IsSynthetic() {
+		t.Errorf("Built tags should have synthetic positions")
+	}
+	if got := list.Render(nil); got != want {
+		t.Errorf("Render of a synthetic tag should serialize it. Got %v Want %v", got, want)
+	}
+	checkDepths(t, &list)
+}
+
+func TestMutations_KeepDepthsConsistent(t *testing.T) {
+	result, err := Parse([]rune(`<html><body><p>Hello</p></body></html>`))
+	if err != nil {
+		t.Fatalf("Failed to parse - %v", err)
+	}
+
+	body := &result.Root.Children[0]
+	if result.Root.IsSynthetic() || body.Children[0].Children[0].Depth != 3 {
+		t.Errorf("Parsed text should have a real position, at it's parent's depth + 1. Got %+v", body.Children[0].Children[0])
+	}
+
+	footer := body.AppendChild(NewElement("footer", NewElement("small", NewText("Fin"))))
+	footer.SetAttr("id", "end")
+
+	heading, err := body.InsertBefore(NewElement("h1", NewText("Title")), &body.Children[0])
+	if err != nil {
+		t.Fatalf("InsertBefore failed - %v", err)
+	}
+	heading.Children[0].SetText("Welcome")
+
+	body.Children[1].ReplaceWith(NewElement("section", NewElement("p", NewText("Replaced"))))
+	body.Children[1].Children[0].SetText("")
+	body.Children[1].Children[0].SetAttr("hidden", "true")
+
+	removed, err := body.RemoveChild(&body.Children[2])
+	if err != nil || removed.Name != "footer" {
+		t.Errorf("RemoveChild should return the removed child. Got %v (%v)", removed.Name, err)
+	}
+
+	result.Root.SetAttr("lang", "en")
+	result.Root.SetAttr("dir", "ltr")
+	result.Root.RemoveAttr("dir")
+
+	got, err := SerializeToString(&result.Root)
+	want := `<html lang="en"><body><h1>Welcome</h1><section><p hidden="true" /></section></body></html>`
+	if err != nil || got != want {
+		t.Errorf("Serialize of a mutated tree. Got %v (%v) Want %v", got, err, want)
+	}
+	checkDepths(t, &result.Root)
+}
+
+func TestMutations_RenderReflectsEdits(t *testing.T) {
+	document := []rune(`<ul id="list">  <li>One</li>  <li>Two</li></ul>`)
+	result, err := Parse(document)
+	if err != nil {
+		t.Fatalf("Failed to parse - %v", err)
+	}
+
+	list := &result.Root
+	if got := list.Render(document); got != string(document) {
+		t.Errorf("Render of an unedited tag should return it's markup. Got %v", got)
+	}
+
+	first := &list.Children[0]
+	first.SetAttr("class", "first")
+	if got, want := first.Render(document), `<li class="first">One</li>`; got != want {
+		t.Errorf("Render of the edited tag. Got %v Want %v", got, want)
+	}
+	if got, want := list.Render(document), `<ul id="list"><li class="first">One</li><li>Two</li></ul>`; got != want {
+		t.Errorf("Render of the edited tag's parent. Got %v Want %v", got, want)
+	}
+	if got, want := list.Children[1].Render(document), `<li>Two</li>`; got != want {
+		t.Errorf("Render of an unedited sibling should return it's markup. Got %v Want %v", got, want)
+	}
+
+	// A tag cut from another document mustn't keep it's positions
+	other, _ := Parse([]rune(`<p>Elsewhere</p>`))
+	list.Children[1].ReplaceWith(other.Root)
+	if got, want := list.Children[1].Render(document), `<p>Elsewhere</p>`; got != want {
+		t.Errorf("Render of a replacement. Got %v Want %v", got, want)
+	}
+}
+
+func TestMutations_LeaveTheSourceTreeUnchanged(t *testing.T) {
+	source := []rune(`<div><p class="a">One<b>Two</b></p><p>Three</p></div>`)
+	parse := func() Tag {
+		result, err := Parse(source)
+		if err != nil {
+			t.Fatalf("Failed to parse - %v", err)
+		}
+		return result.Root
+	}
+
+	// Each mutation adds the child to the target, returning the added child
+	mutations := map[string]func(target *Tag, child Tag) *Tag{
+		"AppendChild": func(target *Tag, child Tag) *Tag { return target.AppendChild(child) },
+		"InsertBefore": func(target *Tag, child Tag) *Tag {
+			added, err := target.InsertBefore(child, &target.Children[0])
+			if err != nil {
+				t.Fatalf("InsertBefore failed - %v", err)
+			}
+			return added
+		},
+		"ReplaceWith": func(target *Tag, child Tag) *Tag {
+			target.Children[0].ReplaceWith(child)
+			return &target.Children[0]
+		},
+	}
+
+	for name, mutate := range mutations {
+		want := parse()
+		tree := parse()
+		target := NewElement("section", NewElement("h1"))
+		added := mutate(&target, tree.Children[0])
+
+		if !reflect.DeepEqual(tree, want) {
+			t.Errorf("%v changed the tree the child came from. Got %+v Want %+v", name, tree, want)
+		}
+
+		// Edits to the added copy mustn't reach the source either
+		added.SetAttr("class", "b")
+		added.Children[1].Children[0].SetText("Changed")
+		if !reflect.DeepEqual(tree, want) {
+			t.Errorf("Editing the child added by %v changed the tree it came from. Got %+v Want %+v", name, tree, want)
+		}
+		checkDepths(t, &target)
+	}
+}
+
+func TestRender_UnserializableTagsRenderEmpty(t *testing.T) {
+	div := NewElement("div", NewText("Hi"))
+	div.Attributes = map[string]string{"bad name": "x"}
+
+	if _, err := SerializeToString(&div); err == nil {
+		t.Fatalf("Serialize of an invalid attribute name should fail")
+	}
+	if got := div.Render(nil); got != "" {
+		t.Errorf("Render of a tag which cannot be serialized should be empty. Got %v", got)
+	}
+}
+
+func TestMutations_Errors(t *testing.T) {
+	text := NewText("Hi")
+	if err := text.SetAttr("class", "x"); err == nil {
+		t.Errorf("SetAttr on a text tag should fail")
+	}
+	if err := text.RemoveAttr(TextAttributeName); err == nil {
+		t.Errorf("RemoveAttr on a text tag should fail")
+	}
+
+	div := NewElement("div")
+	if err := div.SetAttr("bad name", "x"); err == nil {
+		t.Errorf("SetAttr with an invalid name should fail")
+	}
+
+	other := NewElement("p")
+	if _, err := div.RemoveChild(&other); err == nil {
+		t.Errorf("RemoveChild of a tag which isn't a child should fail")
+	}
+	if _, err := div.InsertBefore(NewText("x"), &other); err == nil {
+		t.Errorf("InsertBefore a tag which isn't a child should fail")
+	}
+}
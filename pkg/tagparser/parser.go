@@ -10,6 +10,11 @@ type ParseResult struct {
 	Root Tag
 	// After removing leading/trailing whitespace, may not be the same slice as the input
 	Document []rune
+	// The input passed to Parse
+	Input []rune
+	// The number of leading whitespace runes removed from the Input, so Document starts at Input[Offset]. Use
+	// LineIndex to find the position of a Document offset within the Input
+	Offset int
 }
 
 type ParseError struct {
@@ -32,6 +37,7 @@ func (e *ParseError) Error() string {
 // - Self closing tags are permitted
 // - Nameless tags are permitted (but must have no attributes). i.e. </> and <>MyContent</>
 // - Attributes can use either single and double quotes
+// - Any whitespace (including line breaks) may separate a tag's name and attributes
 // - Embedded text content will have a Tag.name of <text>.
 // // i.e. For <p>Content</p>, Content will be wrapped into a tag with Tag.name = "<text>" and attribute text == "Content",
 // - Empty tag attributes (valueless attributes) are not supported (i.e. <checkbox checked/>)
@@ -39,6 +45,7 @@ func (e *ParseError) Error() string {
 // - Whitespace is stripped from either side of raw text content
 func Parse(runes []rune) (result ParseResult, error error) {
 	if len(runes) == 0 {
+		result.Input = runes
 		return result, &ParseError{Reason: "Input in empty"}
 	}
 
@@ -52,6 +59,11 @@ func Parse(runes []rune) (result ParseResult, error error) {
 		first_none_space += 1
 	}
 
+	if first_none_space == len(runes) {
+		result.Input = runes
+		return result, &ParseError{Reason: "Input is only whitespace"}
+	}
+
 	for last_none_space > 0 {
 		r := runes[last_none_space]
 		if !unicode.IsSpace(r) {
@@ -60,7 +72,9 @@ func Parse(runes []rune) (result ParseResult, error error) {
 		last_none_space -= 1
 	}
 
-	return parse(runes[first_none_space : last_none_space+1])
+	result, error = parse(runes[first_none_space : last_none_space+1])
+	result.Input, result.Offset = runes, first_none_space
+	return result, error
 }
 
 func isRuneValidForName(r rune) bool {
@@ -165,7 +179,7 @@ func parseOpeningTag(runes []rune, startIdx int, parent *Tag, depth int) (tag *T
 	// Extract the name part of the tag
 	for currentIdx < len(runes) {
 		r := runes[currentIdx]
-		if r == ' ' || r == '/' || r == '>' {
+		if unicode.IsSpace(r) || r == '/' || r == '>' {
 			// Successfully found name bounds - Exit loop
 			tag.Name = string(runes[startIdx+1 : currentIdx])
 			break
@@ -182,7 +196,7 @@ func parseOpeningTag(runes []rune, startIdx int, parent *Tag, depth int) (tag *T
 	for currentIdx < len(runes) {
 		r := runes[currentIdx]
 
-		if r == ' ' {
+		if unicode.IsSpace(r) {
 			currentIdx += 1
 			continue
 		}
@@ -228,10 +242,10 @@ func parseOpeningTag(runes []rune, startIdx int, parent *Tag, depth int) (tag *T
 			// Step over closing quote
 			currentIdx += 1
 			if currentIdx < len(runes) {
-				// If there are multiple attributes, there must be a space between them.
+				// If there are multiple attributes, there must be whitespace between them.
 				// Otherwise, we need to immediately close the tag
 				next_rune := runes[currentIdx]
-				if next_rune == '>' || next_rune == ' ' || next_rune == '/' {
+				if next_rune == '>' || unicode.IsSpace(next_rune) || next_rune == '/' {
 					continue
 				} else {
 					return nil, -1, &ParseError{StartIdx: currentIdx, EndIdx: currentIdx + 1, Reason: "Attributes must be separated by a space"}
@@ -259,7 +273,7 @@ func parseClosingTag(runes []rune, startIdx int, opening *Tag) (exitIdx int, err
 	// Extra the name part of the tag
 	for currentIdx < len(runes) {
 		r := runes[currentIdx]
-		if r == ' ' || r == '>' {
+		if unicode.IsSpace(r) || r == '>' {
 			// Successfully found name bounds - Check to see if it matches opening tag
 			name := string(runes[startIdx+2 : currentIdx])
 			if name != opening.Name {
@@ -280,7 +294,7 @@ func parseClosingTag(runes []rune, startIdx int, opening *Tag) (exitIdx int, err
 
 	for currentIdx < len(runes) {
 		r := runes[currentIdx]
-		if r == ' ' {
+		if unicode.IsSpace(r) {
 			currentIdx += 1
 			continue
 		}
@@ -393,7 +407,7 @@ func parse(runes []rune) (result ParseResult, error error) {
 			startIdx := currentIdx
 			content, currentIdx = parseRawContent(runes, currentIdx)
 
-			previousTag.Children = append(previousTag.Children, Tag{Name: TextTagName, StartIdx: startIdx, EndIdx: currentIdx, Depth: len(tagStack) + 1,
+			previousTag.Children = append(previousTag.Children, Tag{Name: TextTagName, StartIdx: startIdx, EndIdx: currentIdx, Depth: len(tagStack),
 				Attributes: map[string]string{TextAttributeName: content}})
 		}
 	}
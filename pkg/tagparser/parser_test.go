@@ -2,6 +2,7 @@ package tagparser
 
 import (
 	"encoding/xml"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -327,6 +328,52 @@ func TestParseOpeningTag_CorrectlyParsesNormalOpeningTags(t *testing.T) {
 	}
 }
 
+func TestParseOpeningTag_AllowsWhitespaceBetweenAttributes(t *testing.T) {
+	inputs := [][]rune{
+		[]rune("<meta\n    name='robots'\n    content='noindex' />"),
+		[]rune("<meta\tname='robots'\r\n\tcontent='noindex'\n/>"),
+	}
+
+	for _, input := range inputs {
+		tag, endIdx, err := parseOpeningTag(input, 0, nil, 0)
+		if err != nil {
+			t.Errorf("Expected tag to parse correctly, but got error %v", err)
+			continue
+		}
+
+		if tag.Name != "meta" || tag.Attributes["name"] != "robots" || tag.Attributes["content"] != "noindex" {
+			t.Errorf("Tag parsed incorrectly. Got %v", tag)
+		}
+
+		if endIdx != len(input) {
+			t.Errorf("Got end index %v expected %v", endIdx, len(input))
+		}
+	}
+}
+
+func TestParse_AllowsTabsAndLineBreaksWithinTags(t *testing.T) {
+	inputs := []string{
+		"<a\tid='x'\tclass=\"y\"><b\n/></a\t>",
+		"<a\nid='x'\n\tclass=\"y\"\n><b\r\n/></a\n>",
+	}
+
+	for _, input := range inputs {
+		result, error := Parse([]rune(input))
+		if error != nil {
+			t.Errorf("Expecting parse of %q to succeed but it failed with error: %v", input, error)
+			continue
+		}
+
+		root := result.Root
+		if root.Name != "a" || root.Attributes["id"] != "x" || root.Attributes["class"] != "y" || root.EndIdx != len(input) {
+			t.Errorf("Root parsed incorrectly from %q. Got %+v", input, root)
+		}
+		if len(root.Children) != 1 || root.Children[0].Name != "b" {
+			t.Errorf("Child parsed incorrectly from %q. Got %+v", input, root.Children)
+		}
+	}
+}
+
 func TestParseOpeningTag_BreaksWithInvalidOpeningTags(t *testing.T) {
 	type Def struct {
 		input         []rune
@@ -368,6 +415,8 @@ func TestParseClosingTag_WorksWithValidSetup(t *testing.T) {
 	test_defs := []Def{
 		{input: []rune("<a>Hello</a>"), tagName: "a", startIdx: 8, expectedEndIdx: 12},
 		{input: []rune("<a>Hello</a     >"), tagName: "a", startIdx: 8, expectedEndIdx: 17},
+		{input: []rune("<a>Hello</a\t>"), tagName: "a", startIdx: 8, expectedEndIdx: 13},
+		{input: []rune("<a>Hello</a\r\n>"), tagName: "a", startIdx: 8, expectedEndIdx: 14},
 		{input: []rune("<🐶>Woof!</🐶>"), tagName: "🐶", startIdx: 8, expectedEndIdx: 12},
 		{input: []rune("<>< >Lonely</></ >"), tagName: "", startIdx: 11, expectedEndIdx: 14},
 	}
@@ -598,6 +647,33 @@ func TestParse_IgnoresSurroundingSpace(t *testing.T) {
 	}
 }
 
+func TestParse_RejectsWhitespaceOnlyInput(t *testing.T) {
+	for _, input := range []string{"", " ", "\n", "\t\r\n  "} {
+		_, error := Parse([]rune(input))
+		var parseError *ParseError
+		if !errors.As(error, &parseError) {
+			t.Errorf("Expecting parse of %q to fail with a ParseError. Got %v", input, error)
+		}
+	}
+}
+
+func TestParse_TextDepthIsParentDepthPlusOne(t *testing.T) {
+	result, err := Parse([]rune("<ul><li>One<b>Two</b></li></ul>"))
+	if err != nil {
+		t.Fatalf("Expecting parse to succeed but it failed with error: %v", err)
+	}
+
+	li := &result.Root.Children[0]
+	if text := li.Children[0]; text.Name != TextTagName || text.Depth != li.Depth+1 {
+		t.Errorf("Text should have it's parent's depth + 1. Got %v, parent depth %v", text.Depth, li.Depth)
+	}
+
+	b := &li.Children[1]
+	if text := b.Children[0]; text.Depth != b.Depth+1 || text.Depth != 3 {
+		t.Errorf("Nested text should have it's parent's depth + 1. Got %v, parent depth %v", text.Depth, b.Depth)
+	}
+}
+
 //region Benchmarks
 
 var simpleDocument []rune = []rune("<html><head><title>Small Test Document</title></head><body><div>Your Content Here!</div></body></html>")
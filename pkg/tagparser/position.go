@@ -0,0 +1,60 @@
+package tagparser
+
+import (
+	"sort"
+)
+
+// Position is a location within a document
+type Position struct {
+	// The 0-indexed rune offset into the document
+	Offset int
+	// The 1-indexed line number
+	Line int
+	// The 1-indexed column, counted in runes
+	Column int
+}
+
+// LineIndex converts rune offsets within a document to line and column positions
+type LineIndex struct {
+	// The offset of the first rune of each line
+	lineStarts []int
+	// Added to offsets before they are found, for offsets into a part of the indexed document
+	offset int
+}
+
+// NewLineIndex: Index the line breaks of a document. Lines are separated by '\n'
+func NewLineIndex(document []rune) *LineIndex {
+	lineStarts := []int{0}
+	for idx, r := range document {
+		if r == '\n' {
+			lineStarts = append(lineStarts, idx+1)
+		}
+	}
+
+	return &LineIndex{lineStarts: lineStarts}
+}
+
+// LineIndex: Index the lines of the input passed to Parse. Offsets into the result's Document (such as a tag's
+// StartIdx, or a ParseError's) are found at their line and column within the input, counting any leading whitespace
+// Parse stripped. Results built without an Input index the Document instead
+func (r *ParseResult) LineIndex() *LineIndex {
+	if r.Input == nil {
+		return NewLineIndex(r.Document)
+	}
+
+	lineIndex := NewLineIndex(r.Input)
+	lineIndex.offset = r.Offset
+	return lineIndex
+}
+
+// Position: Find the line and column of an offset. Offsets past the end of a line (or the document) are not clamped.
+// The returned Offset is the offset given
+func (li *LineIndex) Position(offset int) Position {
+	indexed := offset + li.offset
+	line := sort.Search(len(li.lineStarts), func(i int) bool { return li.lineStarts[i] > indexed }) - 1
+	if line < 0 {
+		line = 0
+	}
+
+	return Position{Offset: offset, Line: line + 1, Column: indexed - li.lineStarts[line] + 1}
+}
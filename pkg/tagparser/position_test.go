@@ -0,0 +1,58 @@
+package tagparser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLineIndex_FindsPositions(t *testing.T) {
+	type Def struct {
+		offset int
+		want   Position
+	}
+
+	document := []rune("<a>\n  🐶<b />\n\n</a>")
+	test_defs := []Def{
+		{offset: 0, want: Position{Offset: 0, Line: 1, Column: 1}},
+		{offset: 3, want: Position{Offset: 3, Line: 1, Column: 4}},
+		{offset: 4, want: Position{Offset: 4, Line: 2, Column: 1}},
+		{offset: 7, want: Position{Offset: 7, Line: 2, Column: 4}},
+		{offset: 13, want: Position{Offset: 13, Line: 3, Column: 1}},
+		{offset: 17, want: Position{Offset: 17, Line: 4, Column: 4}},
+	}
+
+	lines := NewLineIndex(document)
+	for _, def := range test_defs {
+		got := lines.Position(def.offset)
+		if got != def.want {
+			t.Errorf("Incorrect position for offset %v. Got %+v Want %+v", def.offset, got, def.want)
+		}
+	}
+}
+
+func TestParseResultLineIndex_CountsStrippedWhitespace(t *testing.T) {
+	input := []rune("\n\n  <a>\n <b /></a>")
+	result, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Expecting parse to succeed but it failed with error: %v", err)
+	}
+	if result.Offset != 4 || len(result.Input) != len(input) {
+		t.Errorf("Parse should record the stripped whitespace. Got offset %v", result.Offset)
+	}
+
+	b := result.Root.Children[0]
+	got := result.LineIndex().Position(b.StartIdx)
+	want := Position{Offset: b.StartIdx, Line: 4, Column: 2}
+	if got != want {
+		t.Errorf("Incorrect position for <b>. Got %+v Want %+v", got, want)
+	}
+
+	result, err = Parse([]rune("\n <a></b>"))
+	var parseError *ParseError
+	if !errors.As(err, &parseError) {
+		t.Fatalf("Expecting a parse error. Got %v", err)
+	}
+	if got := result.LineIndex().Position(parseError.StartIdx); got.Line != 2 || got.Column != 5 {
+		t.Errorf("Incorrect position for a parse error. Got %+v", got)
+	}
+}
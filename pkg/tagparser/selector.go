@@ -0,0 +1,689 @@
+package tagparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Selector is a compiled CSS selector which can be reused to query many Tag trees.
+//
+// Supported syntax:
+// - Type (div), universal (*), #id and .class selectors
+// - Attribute selectors: [attr], [attr=value], [attr~=value], [attr|=value], [attr^=value], [attr$=value] and [attr*=value].
+// // Values may be quoted or bare, and a trailing i (i.e. [lang=EN i]) makes the comparison case insensitive
+// - Descendant (a b), child (a > b), adjacent sibling (a + b) and general sibling (a ~ b) combinators
+// - Pseudo classes :root, :empty, :first-child, :last-child, :only-child, :nth-child(), :nth-last-child(),
+// // :first-of-type, :last-of-type, :nth-of-type() and :not()
+// - Selector lists separated by commas
+//
+// Note that:
+// - Names are case sensitive
+// - <text> pseudo tags are never matched, and are ignored when counting sibling positions
+// - Characters which are special in CSS (such as the : in name:now) can be escaped with a backslash
+type Selector struct {
+	source string
+	groups []complexSelector
+}
+
+type combinator rune
+
+const (
+	combinatorDescendant      combinator = ' '
+	combinatorChild           combinator = '>'
+	combinatorAdjacentSibling combinator = '+'
+	combinatorGeneralSibling  combinator = '~'
+)
+
+type complexSelector struct {
+	// compounds are ordered left to right. combinators[i] sits between compounds[i] and compounds[i+1]
+	compounds   []compoundSelector
+	combinators []combinator
+}
+
+type compoundSelector struct {
+	// The required tag name. The empty string matches any name
+	name       string
+	attributes []attributeSelector
+	pseudos    []pseudoSelector
+}
+
+type attributeSelector struct {
+	key string
+	// One of "", "=", "~=", "|=", "^=", "$=", "*=". The empty string only tests for presence
+	operator        string
+	value           string
+	caseInsensitive bool
+}
+
+type pseudoSelector struct {
+	name string
+	// Position arguments for the nth-* pseudo classes. Matches positions a*n + b
+	a, b int
+	// Selector list for :not()
+	not *Selector
+}
+
+// CompileSelector: Compile a CSS selector so that it can be used to query Tag trees.
+// Syntax errors are reported as a *ParseError with indices into the selector.
+func CompileSelector(selector string) (*Selector, error) {
+	p := &selectorParser{runes: []rune(selector)}
+
+	groups, err := p.parseSelectorList()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if !p.done() {
+		return nil, p.errorf("Unexpected rune in selector - %v", string(p.peek()))
+	}
+
+	return &Selector{source: selector, groups: groups}, nil
+}
+
+// MustCompileSelector: Like CompileSelector, but panics if the selector cannot be compiled.
+// Intended for selectors which are known to be valid, such as package level variables.
+func MustCompileSelector(selector string) *Selector {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		panic(fmt.Sprintf("tagparser: cannot compile selector %q: %v", selector, err))
+	}
+	return s
+}
+
+// Select: Find all tags in the tree (including root) which match the selector, in document order.
+func Select(root *Tag, selector string) ([]*Tag, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Select(root), nil
+}
+
+// SelectFirst: Find the first tag in document order which matches the selector. Returns nil if nothing matches.
+func SelectFirst(root *Tag, selector string) (*Tag, error) {
+	s, err := CompileSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.SelectFirst(root), nil
+}
+
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Select: Find all tags in the tree (including root) which match the selector, in document order.
+// The returned pointers refer to tags inside the tree.
+func (s *Selector) Select(root *Tag) []*Tag {
+	matches := make([]*Tag, 0)
+	walk(root,
+		func(t *Tag, ancestors []*Tag) error {
+			if s.matches(t, ancestors) {
+				matches = append(matches, t)
+			}
+			return nil
+		},
+		func(t *Tag, ancestors []*Tag) error { return nil })
+
+	return matches
+}
+
+// SelectFirst: Find the first tag in document order which matches the selector. Returns nil if nothing matches.
+func (s *Selector) SelectFirst(root *Tag) (match *Tag) {
+	walk(root,
+		func(t *Tag, ancestors []*Tag) error {
+			if s.matches(t, ancestors) {
+				match = t
+				return StopWalk
+			}
+			return nil
+		},
+		func(t *Tag, ancestors []*Tag) error { return nil })
+
+	return match
+}
+
+func (s *Selector) matches(tag *Tag, ancestors []*Tag) bool {
+	if tag.Name == TextTagName {
+		return false
+	}
+
+	for _, group := range s.groups {
+		if group.matches(len(group.compounds)-1, tag, ancestors) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches: Check if compounds[:idx+1] match, with compounds[idx] matching tag. Works right to left.
+func (c *complexSelector) matches(idx int, tag *Tag, ancestors []*Tag) bool {
+	if !c.compounds[idx].matches(tag, ancestors) {
+		return false
+	}
+
+	if idx == 0 {
+		return true
+	}
+
+	switch c.combinators[idx-1] {
+	case combinatorDescendant:
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			if c.matches(idx-1, ancestors[i], ancestors[:i]) {
+				return true
+			}
+		}
+	case combinatorChild:
+		if len(ancestors) > 0 {
+			last := len(ancestors) - 1
+			return c.matches(idx-1, ancestors[last], ancestors[:last])
+		}
+	case combinatorAdjacentSibling:
+		siblings, position := elementSiblings(tag, ancestors)
+		if position > 0 {
+			return c.matches(idx-1, siblings[position-1], ancestors)
+		}
+	case combinatorGeneralSibling:
+		siblings, position := elementSiblings(tag, ancestors)
+		for i := position - 1; i >= 0; i-- {
+			if c.matches(idx-1, siblings[i], ancestors) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *compoundSelector) matches(tag *Tag, ancestors []*Tag) bool {
+	if c.name != "" && c.name != tag.Name {
+		return false
+	}
+
+	for _, attribute := range c.attributes {
+		if !attribute.matches(tag) {
+			return false
+		}
+	}
+
+	for _, pseudo := range c.pseudos {
+		if !pseudo.matches(tag, ancestors) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *attributeSelector) matches(tag *Tag) bool {
+	actual, ok := tag.Attributes[a.key]
+	if !ok {
+		return false
+	}
+
+	expected := a.value
+	if a.caseInsensitive {
+		actual = strings.ToLower(actual)
+		expected = strings.ToLower(expected)
+	}
+
+	switch a.operator {
+	case "":
+		return true
+	case "=":
+		return actual == expected
+	case "~=":
+		for _, word := range strings.Fields(actual) {
+			if word == expected {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return actual == expected || strings.HasPrefix(actual, expected+"-")
+	case "^=":
+		return expected != "" && strings.HasPrefix(actual, expected)
+	case "$=":
+		return expected != "" && strings.HasSuffix(actual, expected)
+	case "*=":
+		return expected != "" && strings.Contains(actual, expected)
+	}
+
+	return false
+}
+
+func (p *pseudoSelector) matches(tag *Tag, ancestors []*Tag) bool {
+	switch p.name {
+	case "root":
+		return len(ancestors) == 0
+	case "empty":
+		return len(tag.Children) == 0
+	case "not":
+		return !p.not.matches(tag, ancestors)
+	}
+
+	siblings, position := elementSiblings(tag, ancestors)
+	if strings.HasSuffix(p.name, "-of-type") {
+		siblings, position = siblingsOfType(siblings, position)
+	}
+
+	switch p.name {
+	case "first-child", "first-of-type":
+		return position == 0
+	case "last-child", "last-of-type":
+		return position == len(siblings)-1
+	case "only-child":
+		return len(siblings) == 1
+	case "nth-child", "nth-of-type":
+		return nthMatches(p.a, p.b, position+1)
+	case "nth-last-child":
+		return nthMatches(p.a, p.b, len(siblings)-position)
+	}
+
+	return false
+}
+
+// nthMatches: Check if the 1-based position can be written as a*n + b for some n >= 0
+func nthMatches(a, b, position int) bool {
+	if a == 0 {
+		return position == b
+	}
+
+	diff := position - b
+	return diff/a >= 0 && diff%a == 0
+}
+
+// elementSiblings: Find the element (non text) children of tag's parent, and tag's position amongst them.
+// A tag without a parent is treated as an only child.
+func elementSiblings(tag *Tag, ancestors []*Tag) (siblings []*Tag, position int) {
+	if len(ancestors) == 0 {
+		return []*Tag{tag}, 0
+	}
+
+	parent := ancestors[len(ancestors)-1]
+	siblings = make([]*Tag, 0, len(parent.Children))
+	position = -1
+	for i := range parent.Children {
+		child := &parent.Children[i]
+		if child.Name == TextTagName {
+			continue
+		}
+
+		if child == tag {
+			position = len(siblings)
+		}
+		siblings = append(siblings, child)
+	}
+
+	return siblings, position
+}
+
+func siblingsOfType(siblings []*Tag, position int) (ofType []*Tag, newPosition int) {
+	name := siblings[position].Name
+	for i, sibling := range siblings {
+		if sibling.Name != name {
+			continue
+		}
+
+		if i == position {
+			newPosition = len(ofType)
+		}
+		ofType = append(ofType, sibling)
+	}
+
+	return ofType, newPosition
+}
+
+//region Parsing
+
+type selectorParser struct {
+	runes []rune
+	idx   int
+}
+
+func (p *selectorParser) done() bool {
+	return p.idx >= len(p.runes)
+}
+
+func (p *selectorParser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.runes[p.idx]
+}
+
+func (p *selectorParser) skipSpace() (skipped bool) {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.idx += 1
+		skipped = true
+	}
+	return skipped
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return &ParseError{StartIdx: p.idx, EndIdx: min(p.idx+1, len(p.runes)), Reason: fmt.Sprintf(format, args...)}
+}
+
+func isRuneValidForSelectorName(r rune) bool {
+	return r != ':' && r != '.' && isRuneValidForName(r) && !strings.ContainsRune("<>+~=|^$*", r)
+}
+
+// parseName: Read a (possibly escaped) name. Returns the empty string if there is no name at the current position.
+func (p *selectorParser) parseName() (string, error) {
+	var sb strings.Builder
+	for !p.done() {
+		r := p.peek()
+		if r == '\\' {
+			if p.idx+1 >= len(p.runes) {
+				return "", p.errorf("Selector ended in the middle of an escape sequence")
+			}
+			sb.WriteRune(p.runes[p.idx+1])
+			p.idx += 2
+			continue
+		}
+
+		if !isRuneValidForSelectorName(r) {
+			break
+		}
+
+		sb.WriteRune(r)
+		p.idx += 1
+	}
+
+	return sb.String(), nil
+}
+
+func (p *selectorParser) parseSelectorList() ([]complexSelector, error) {
+	groups := make([]complexSelector, 0)
+	for {
+		p.skipSpace()
+		group, err := p.parseComplexSelector()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+
+		p.skipSpace()
+		if p.peek() != ',' {
+			return groups, nil
+		}
+		p.idx += 1
+	}
+}
+
+func (p *selectorParser) parseComplexSelector() (complex complexSelector, err error) {
+	compound, err := p.parseCompoundSelector()
+	if err != nil {
+		return
+	}
+	complex.compounds = append(complex.compounds, compound)
+
+	for {
+		sawSpace := p.skipSpace()
+
+		var comb combinator
+		switch r := p.peek(); {
+		case r == '>' || r == '+' || r == '~':
+			comb = combinator(r)
+			p.idx += 1
+			p.skipSpace()
+		case sawSpace && !p.done() && r != ',' && r != ')':
+			comb = combinatorDescendant
+		default:
+			return complex, nil
+		}
+
+		compound, err = p.parseCompoundSelector()
+		if err != nil {
+			return
+		}
+		complex.compounds = append(complex.compounds, compound)
+		complex.combinators = append(complex.combinators, comb)
+	}
+}
+
+func (p *selectorParser) parseCompoundSelector() (compound compoundSelector, err error) {
+	start := p.idx
+
+	if p.peek() == '*' {
+		p.idx += 1
+	} else {
+		compound.name, err = p.parseName()
+		if err != nil {
+			return
+		}
+	}
+
+	for !p.done() {
+		switch p.peek() {
+		case '#', '.':
+			kind := p.peek()
+			p.idx += 1
+
+			var name string
+			name, err = p.parseName()
+			if err != nil {
+				return
+			}
+			if name == "" {
+				err = p.errorf("Expected a name after %v", string(kind))
+				return
+			}
+
+			if kind == '#' {
+				compound.attributes = append(compound.attributes, attributeSelector{key: "id", operator: "=", value: name})
+			} else {
+				compound.attributes = append(compound.attributes, attributeSelector{key: "class", operator: "~=", value: name})
+			}
+		case '[':
+			var attribute attributeSelector
+			attribute, err = p.parseAttributeSelector()
+			if err != nil {
+				return
+			}
+			compound.attributes = append(compound.attributes, attribute)
+		case ':':
+			var pseudo pseudoSelector
+			pseudo, err = p.parsePseudoSelector()
+			if err != nil {
+				return
+			}
+			compound.pseudos = append(compound.pseudos, pseudo)
+		default:
+			if p.idx == start {
+				err = p.errorf("Expected a selector - got %v", string(p.peek()))
+			}
+			return
+		}
+	}
+
+	if p.idx == start {
+		err = p.errorf("Expected a selector - got end of input")
+	}
+
+	return
+}
+
+func (p *selectorParser) parseAttributeSelector() (attribute attributeSelector, err error) {
+	// Step over [
+	p.idx += 1
+	p.skipSpace()
+
+	attribute.key, err = p.parseName()
+	if err != nil {
+		return
+	}
+	if attribute.key == "" {
+		err = p.errorf("Expected an attribute name")
+		return
+	}
+
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.idx += 1
+		return
+	}
+
+	for _, operator := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		end := p.idx + len(operator)
+		if end <= len(p.runes) && string(p.runes[p.idx:end]) == operator {
+			attribute.operator = operator
+			p.idx = end
+			break
+		}
+	}
+	if attribute.operator == "" {
+		err = p.errorf("Expected an attribute operator or ] - got %v", string(p.peek()))
+		return
+	}
+
+	p.skipSpace()
+	attribute.value, err = p.parseAttributeSelectorValue()
+	if err != nil {
+		return
+	}
+
+	p.skipSpace()
+	if p.peek() == 'i' || p.peek() == 'I' {
+		attribute.caseInsensitive = true
+		p.idx += 1
+		p.skipSpace()
+	}
+
+	if p.peek() != ']' {
+		err = p.errorf("Expected ] to close the attribute selector")
+		return
+	}
+	p.idx += 1
+
+	return
+}
+
+func (p *selectorParser) parseAttributeSelectorValue() (string, error) {
+	quotation := p.peek()
+	if quotation != '"' && quotation != '\'' {
+		start := p.idx
+		for !p.done() && p.peek() != ']' && !unicode.IsSpace(p.peek()) {
+			p.idx += 1
+		}
+		return string(p.runes[start:p.idx]), nil
+	}
+
+	p.idx += 1
+	start := p.idx
+	for !p.done() {
+		if p.peek() == quotation {
+			value := string(p.runes[start:p.idx])
+			p.idx += 1
+			return value, nil
+		}
+		p.idx += 1
+	}
+
+	return "", &ParseError{StartIdx: start, EndIdx: p.idx, Reason: "Selector ended without closing the attribute value quotation"}
+}
+
+func (p *selectorParser) parsePseudoSelector() (pseudo pseudoSelector, err error) {
+	// Step over :
+	p.idx += 1
+	start := p.idx
+
+	pseudo.name, err = p.parseName()
+	if err != nil {
+		return
+	}
+
+	switch pseudo.name {
+	case "root", "empty", "first-child", "last-child", "only-child", "first-of-type", "last-of-type":
+		return
+	case "nth-child", "nth-last-child", "nth-of-type", "not":
+	default:
+		return pseudo, &ParseError{StartIdx: start, EndIdx: p.idx, Reason: fmt.Sprintf("Unsupported pseudo class :%v", pseudo.name)}
+	}
+
+	if p.peek() != '(' {
+		err = p.errorf("Expected ( after :%v", pseudo.name)
+		return
+	}
+	p.idx += 1
+
+	if pseudo.name == "not" {
+		var groups []complexSelector
+		groups, err = p.parseSelectorList()
+		if err != nil {
+			return
+		}
+		pseudo.not = &Selector{groups: groups}
+	} else {
+		argStart := p.idx
+		for !p.done() && p.peek() != ')' {
+			p.idx += 1
+		}
+
+		pseudo.a, pseudo.b, err = parseNth(string(p.runes[argStart:p.idx]))
+		if err != nil {
+			return pseudo, &ParseError{StartIdx: argStart, EndIdx: p.idx, Reason: err.Error()}
+		}
+	}
+
+	p.skipSpace()
+	if p.peek() != ')' {
+		err = p.errorf("Expected ) to close :%v", pseudo.name)
+		return
+	}
+	p.idx += 1
+
+	return
+}
+
+// parseNth: Parse the an+b syntax used by :nth-child(), including the odd and even keywords
+func parseNth(arg string) (a, b int, err error) {
+	arg = strings.ToLower(strings.Join(strings.Fields(arg), ""))
+	switch arg {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	}
+
+	invalid := fmt.Errorf("Invalid nth expression - %v", arg)
+
+	nIdx := strings.IndexRune(arg, 'n')
+	if nIdx == -1 {
+		b, err = strconv.Atoi(arg)
+		if err != nil {
+			return 0, 0, invalid
+		}
+		return 0, b, nil
+	}
+
+	switch coefficient := arg[:nIdx]; coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		a, err = strconv.Atoi(coefficient)
+		if err != nil {
+			return 0, 0, invalid
+		}
+	}
+
+	if offset := arg[nIdx+1:]; offset != "" {
+		if offset[0] != '+' && offset[0] != '-' {
+			return 0, 0, invalid
+		}
+		b, err = strconv.Atoi(offset)
+		if err != nil {
+			return 0, 0, invalid
+		}
+	}
+
+	return a, b, nil
+}
+
+//endregion Parsing
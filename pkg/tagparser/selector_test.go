@@ -0,0 +1,217 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var selectorDocument []rune = []rune(`<html lang="en-GB">
+	<head><title>Selectors</title></head>
+	<body>
+		<nav id="main" class="menu top">
+			<a href="https://example.com">Home</a>
+			<a href="/about" class="internal">About</a>
+			<a href="http://example.com/blog" lang="en">Blog</a>
+		</nav>
+		<ul>
+			<li>One</li>
+			<li class="special">Two</li>
+			<li>Three</li>
+			<li>Four</li>
+			<li>Five</li>
+		</ul>
+		<p>Text <a href="https://example.com/inline">inline</a></p>
+		<name:now />
+	</body>
+</html>`)
+
+// selectTexts: Run the selector over the test document, returning the text (or name) of each matched tag
+func selectTexts(t *testing.T, selector string) []string {
+	result, err := Parse(selectorDocument)
+	if err != nil {
+		t.Fatalf("Expected test document to parse. Got %v", err)
+	}
+
+	matches, err := Select(&result.Root, selector)
+	if err != nil {
+		t.Fatalf("Expected selector %v to compile. Got %v", selector, err)
+	}
+
+	got := make([]string, 0)
+	for _, match := range matches {
+		if len(match.Children) > 0 && match.Children[0].Name == TextTagName {
+			got = append(got, match.Children[0].Attributes[TextAttributeName])
+		} else {
+			got = append(got, match.Name)
+		}
+	}
+	return got
+}
+
+func TestSelect_MatchesSimpleSelectors(t *testing.T) {
+	type Def struct {
+		selector string
+		want     []string
+	}
+
+	test_defs := []Def{
+		{selector: "title", want: []string{"Selectors"}},
+		{selector: "#main", want: []string{"nav"}},
+		{selector: ".menu.top", want: []string{"nav"}},
+		{selector: ".internal", want: []string{"About"}},
+		{selector: "li.special", want: []string{"Two"}},
+		{selector: "a[href]", want: []string{"Home", "About", "Blog", "inline"}},
+		{selector: "a[href^=https]", want: []string{"Home", "inline"}},
+		{selector: "a[href$='blog']", want: []string{"Blog"}},
+		{selector: "a[href*=\"example\"]", want: []string{"Home", "Blog", "inline"}},
+		{selector: "[lang|=en]", want: []string{"html", "Blog"}},
+		{selector: "[lang=EN-gb i]", want: []string{"html"}},
+		{selector: "[class~=top]", want: []string{"nav"}},
+		{selector: "name\\:now", want: []string{"name:now"}},
+		{selector: "*:root", want: []string{"html"}},
+		{selector: "body > :empty", want: []string{"name:now"}},
+	}
+
+	for _, def := range test_defs {
+		got := selectTexts(t, def.selector)
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("Selector %v matched incorrectly. Got %v Want %v", def.selector, got, def.want)
+		}
+	}
+}
+
+func TestSelect_MatchesCombinators(t *testing.T) {
+	type Def struct {
+		selector string
+		want     []string
+	}
+
+	test_defs := []Def{
+		{selector: "nav a", want: []string{"Home", "About", "Blog"}},
+		{selector: "body a", want: []string{"Home", "About", "Blog", "inline"}},
+		{selector: "body > a", want: []string{}},
+		{selector: "p > a", want: []string{"inline"}},
+		{selector: "html>body>ul>li.special", want: []string{"Two"}},
+		{selector: "li.special + li", want: []string{"Three"}},
+		{selector: "li.special ~ li", want: []string{"Three", "Four", "Five"}},
+		{selector: "nav ~ p a", want: []string{"inline"}},
+		{selector: "title, nav .internal", want: []string{"Selectors", "About"}},
+	}
+
+	for _, def := range test_defs {
+		got := selectTexts(t, def.selector)
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("Selector %v matched incorrectly. Got %v Want %v", def.selector, got, def.want)
+		}
+	}
+}
+
+func TestSelect_MatchesPseudoClasses(t *testing.T) {
+	type Def struct {
+		selector string
+		want     []string
+	}
+
+	test_defs := []Def{
+		{selector: "li:first-child", want: []string{"One"}},
+		{selector: "li:last-child", want: []string{"Five"}},
+		{selector: "title:only-child", want: []string{"Selectors"}},
+		{selector: "li:nth-child(2)", want: []string{"Two"}},
+		{selector: "li:nth-child(odd)", want: []string{"One", "Three", "Five"}},
+		{selector: "li:nth-child(even)", want: []string{"Two", "Four"}},
+		{selector: "li:nth-child(3n+1)", want: []string{"One", "Four"}},
+		{selector: "li:nth-child(-n+2)", want: []string{"One", "Two"}},
+		{selector: "li:nth-last-child(1)", want: []string{"Five"}},
+		{selector: "a:first-of-type", want: []string{"Home", "inline"}},
+		{selector: "a:nth-of-type(2)", want: []string{"About"}},
+		{selector: "li:not(.special)", want: []string{"One", "Three", "Four", "Five"}},
+		{selector: "li:not(:first-child, :last-child)", want: []string{"Two", "Three", "Four"}},
+		// Text content should not be counted as a child element
+		{selector: "p > a:first-child", want: []string{"inline"}},
+	}
+
+	for _, def := range test_defs {
+		got := selectTexts(t, def.selector)
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("Selector %v matched incorrectly. Got %v Want %v", def.selector, got, def.want)
+		}
+	}
+}
+
+func TestSelectFirst_ReturnsFirstMatch(t *testing.T) {
+	result, _ := Parse(selectorDocument)
+
+	got, err := SelectFirst(&result.Root, "a")
+	if err != nil || got == nil || got.Attributes["href"] != "https://example.com" {
+		t.Errorf("SelectFirst returned the wrong tag. Got %v (%v)", got, err)
+	}
+
+	got, err = SelectFirst(&result.Root, "table")
+	if err != nil || got != nil {
+		t.Errorf("SelectFirst should return nil when nothing matches. Got %v (%v)", got, err)
+	}
+}
+
+func TestSelect_ReturnsPointersIntoTree(t *testing.T) {
+	result, _ := Parse(selectorDocument)
+	root := &result.Root
+
+	matches := MustCompileSelector("title").Select(root)
+	if len(matches) != 1 || matches[0] != &root.Children[0].Children[0] {
+		t.Errorf("Select did not return pointers into the tree. Got %v", matches)
+	}
+}
+
+func TestCompileSelector_ErrorsWithInvalidSelectors(t *testing.T) {
+	type Def struct {
+		selector      string
+		expectedError string
+	}
+
+	test_defs := []Def{
+		{selector: "", expectedError: "Expected a selector"},
+		{selector: "a >", expectedError: "Expected a selector"},
+		{selector: "a,", expectedError: "Expected a selector"},
+		{selector: "#", expectedError: "Expected a name after #"},
+		{selector: "a[href", expectedError: "Expected an attribute operator"},
+		{selector: "a[href='x", expectedError: "without closing the attribute value"},
+		{selector: "a[href!=x]", expectedError: "Expected an attribute operator"},
+		{selector: "a:hover", expectedError: "Unsupported pseudo class"},
+		{selector: "li:nth-child(x)", expectedError: "Invalid nth expression"},
+		{selector: "li:not(a", expectedError: "Expected ) to close :not"},
+		{selector: "a)", expectedError: "Unexpected rune in selector"},
+	}
+
+	for _, def := range test_defs {
+		_, err := CompileSelector(def.selector)
+		if err == nil || !strings.Contains(err.Error(), def.expectedError) {
+			t.Errorf("Selector %q error was %v but expected %v", def.selector, err, def.expectedError)
+		}
+	}
+}
+
+func TestParseNth_ParsesExpressions(t *testing.T) {
+	type Def struct {
+		input string
+		a, b  int
+	}
+
+	test_defs := []Def{
+		{input: "odd", a: 2, b: 1},
+		{input: "even", a: 2, b: 0},
+		{input: "7", a: 0, b: 7},
+		{input: "n", a: 1, b: 0},
+		{input: "-n+3", a: -1, b: 3},
+		{input: "2n + 1", a: 2, b: 1},
+		{input: "3n-2", a: 3, b: -2},
+	}
+
+	for _, def := range test_defs {
+		a, b, err := parseNth(def.input)
+		if err != nil || a != def.a || b != def.b {
+			t.Errorf("parseNth(%v) got %v,%v (%v) want %v,%v", def.input, a, b, err, def.a, def.b)
+		}
+	}
+}
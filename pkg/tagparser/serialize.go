@@ -0,0 +1,195 @@
+package tagparser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Serialize: Write the tag and all of it's descendants to w as markup.
+// Unlike Tag.Render, this does not need the original document, so it works with trees built or modified in code.
+//
+// Note that:
+// - Attributes are written in name order, with double quotes unless the value contains a double quote (but no single quote)
+// - Characters the parser cannot read in attribute values (quotes which clash with the quotation, control characters
+// and line breaks) are written as character references such as &quot;
+// - A < in text content is written as &lt;. All other text is written as-is, as the parser leaves escaped characters in-tact
+// - Elements without children are written as self-closing tags (i.e. <br />)
+// - No whitespace is added between tags
+//
+// For any tree produced by Parse, Parse(Serialize(tag)) reproduces an equal tree (ignoring StartIdx and EndIdx).
+// Text content is trimmed by the parser, and adjacent text tags are merged, so trees built in code only round trip
+// when their text tags are non-empty, have no surrounding whitespace and are not adjacent to one another.
+func Serialize(w io.Writer, tag *Tag) error {
+	return serialize(w, tag, false)
+}
+
+// serialize: Serialize a tag, optionally in the canonical form. The canonical form collapses runs of whitespace within
+// text, escapes every & in text and attribute values (so that escaped and unescaped characters can't be confused) and
+// separates adjacent text tags with &; (so that they can't be confused with a single text tag)
+func serialize(w io.Writer, tag *Tag, canonical bool) error {
+	sw := &stickyWriter{w: w}
+	previousText := false
+
+	err := WalkEnterExit(tag,
+		func(t *Tag) error {
+			if t.Name == TextTagName {
+				text, ok := t.Attributes[TextAttributeName]
+				if !ok {
+					return fmt.Errorf("text tag is missing it's required text. cannot serialize")
+				}
+
+				if canonical {
+					if previousText {
+						sw.WriteString("&;")
+					}
+					text = escapeAmpersands(normalizeSpace(text))
+				}
+
+				sw.WriteString(escapeText(text))
+				previousText = true
+				return SkipChildren
+			}
+
+			previousText = false
+			if err := writeOpeningTag(sw, t, sortedAttributeKeys(t), '"', " ", canonical); err != nil {
+				return err
+			}
+
+			if len(t.Children) == 0 {
+				sw.WriteString(" />")
+			} else {
+				sw.WriteString(">")
+			}
+			return sw.err
+		},
+		func(t *Tag) error {
+			if t.Name != TextTagName && len(t.Children) > 0 {
+				writeClosingTag(sw, t)
+				previousText = false
+			}
+			return sw.err
+		})
+
+	if err != nil {
+		return err
+	}
+	return sw.err
+}
+
+// SerializeToString: Serialize the tag to a string. See Serialize
+func SerializeToString(tag *Tag) (string, error) {
+	var sb strings.Builder
+	err := Serialize(&sb, tag)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// stickyWriter remembers the first error encountered, so that a sequence of writes only needs a single error check.
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) WriteString(str string) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = io.WriteString(s.w, str)
+}
+
+// writeOpeningTag: Write a tag's name and attributes (in the given order) without the closing angle bracket.
+// The separator is written before each attribute. For the canonical form, every & in the values is escaped
+func writeOpeningTag(sw *stickyWriter, tag *Tag, keys []string, quotation rune, separator string, canonical bool) error {
+	if !isValidSerializedName(tag.Name, true) {
+		return fmt.Errorf("tag name %q cannot be serialized", tag.Name)
+	}
+
+	if tag.Name == "" && len(tag.Attributes) > 0 {
+		return fmt.Errorf("nameless tags cannot contain attributes. cannot serialize")
+	}
+
+	sw.WriteString("<")
+	sw.WriteString(tag.Name)
+
+	for _, key := range keys {
+		if !isValidSerializedName(key, false) {
+			return fmt.Errorf("attribute name %q on tag %q cannot be serialized", key, tag.Name)
+		}
+
+		sw.WriteString(separator)
+		sw.WriteString(key)
+		sw.WriteString("=")
+		value := tag.Attributes[key]
+		if canonical {
+			value = escapeAmpersands(value)
+		}
+		sw.WriteString(quoteAttributeValue(value, quotation))
+	}
+
+	return nil
+}
+
+func writeClosingTag(sw *stickyWriter, tag *Tag) {
+	sw.WriteString("</")
+	sw.WriteString(tag.Name)
+	sw.WriteString(">")
+}
+
+// isValidSerializedName: Check that a tag or attribute name will be read back unchanged by the parser
+func isValidSerializedName(name string, allowEmpty bool) bool {
+	if name == "" {
+		return allowEmpty
+	}
+
+	for _, r := range name {
+		if !isRuneValidForName(r) || r == '<' || r == '>' || r == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteAttributeValue: Quote an attribute value so that the parser reads it back unchanged where possible.
+// The preferred quotation is used unless the value contains it but not the alternative.
+func quoteAttributeValue(value string, preferred rune) string {
+	alternative := '\''
+	if preferred == '\'' {
+		alternative = '"'
+	}
+
+	quotation := preferred
+	if strings.ContainsRune(value, preferred) && !strings.ContainsRune(value, alternative) {
+		quotation = alternative
+	}
+
+	var sb strings.Builder
+	sb.WriteRune(quotation)
+	for _, r := range value {
+		switch {
+		case r == quotation && r == '"':
+			sb.WriteString("&quot;")
+		case r == quotation:
+			sb.WriteString("&#39;")
+		case !isRuneValidForValue(r):
+			sb.WriteString(fmt.Sprintf("&#x%X;", r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(quotation)
+
+	return sb.String()
+}
+
+// escapeText: Escape text content so that it cannot be read as a tag
+func escapeText(text string) string {
+	return strings.ReplaceAll(text, "<", "&lt;")
+}
+
+// escapeAmpersands: Escape every & as &amp;, so that the escapes written afterwards can't be confused with the text
+func escapeAmpersands(text string) string {
+	return strings.ReplaceAll(text, "&", "&amp;")
+}
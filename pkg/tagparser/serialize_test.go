@@ -0,0 +1,143 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSerialize_WorksWithNilTag(t *testing.T) {
+	var tag *Tag
+	got, err := SerializeToString(tag)
+	if err != nil || got != "" {
+		t.Errorf("Serialize doesn't work with nil tags. Got %v (%v)", got, err)
+	}
+}
+
+func TestSerialize_WritesMarkup(t *testing.T) {
+	type Def struct {
+		tag  Tag
+		want string
+	}
+
+	test_defs := []Def{
+		{tag: Tag{Name: "br"}, want: "<br />"},
+		{tag: Tag{Name: ""}, want: "< />"},
+		{tag: Tag{Name: "", Children: []Tag{{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "Lonely"}}}}, want: "<>Lonely</>"},
+		{tag: Tag{Name: "p", Children: []Tag{{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "🐶"}}}}, want: "<p>🐶</p>"},
+		{tag: Tag{Name: "a", Attributes: map[string]string{"z": "1", "b": "2"}}, want: `<a b="2" z="1" />`},
+		{
+			tag: Tag{Name: "html", Children: []Tag{
+				{Name: "head"},
+				{Name: "body", Children: []Tag{{Name: "p", Attributes: map[string]string{"class": "x"}}}},
+			}},
+			want: `<html><head /><body><p class="x" /></body></html>`,
+		},
+	}
+
+	for _, def := range test_defs {
+		got, err := SerializeToString(&def.tag)
+		if err != nil || got != def.want {
+			t.Errorf("Serialize wrote incorrect markup. Got %v (%v) Want %v", got, err, def.want)
+		}
+	}
+}
+
+func TestSerialize_QuotesAndEscapesValues(t *testing.T) {
+	type Def struct {
+		value string
+		want  string
+	}
+
+	test_defs := []Def{
+		{value: "plain", want: `<a v="plain" />`},
+		{value: `say "hi"`, want: `<a v='say "hi"' />`},
+		{value: `it's`, want: `<a v="it's" />`},
+		{value: `"it's"`, want: `<a v="&quot;it's&quot;" />`},
+		{value: "line\nbreak\t", want: `<a v="line&#xA;break&#x9;" />`},
+		// The parser leaves escaped characters in-tact, so existing references are not double escaped
+		{value: "&amp;", want: `<a v="&amp;" />`},
+	}
+
+	for _, def := range test_defs {
+		tag := &Tag{Name: "a", Attributes: map[string]string{"v": def.value}}
+		got, err := SerializeToString(tag)
+		if err != nil || got != def.want {
+			t.Errorf("Serialize quoted %q incorrectly. Got %v (%v) Want %v", def.value, got, err, def.want)
+		}
+	}
+}
+
+func TestSerialize_EscapesText(t *testing.T) {
+	tag := &Tag{Name: "p", Children: []Tag{{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "1 < 2 & 3 > 2"}}}}
+	got, err := SerializeToString(tag)
+	want := "<p>1 &lt; 2 & 3 > 2</p>"
+	if err != nil || got != want {
+		t.Errorf("Serialize escaped text incorrectly. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestSerialize_ErrorsWithInvalidTrees(t *testing.T) {
+	type Def struct {
+		tag           Tag
+		expectedError string
+	}
+
+	test_defs := []Def{
+		{tag: Tag{Name: "a b"}, expectedError: "tag name"},
+		{tag: Tag{Name: "a>"}, expectedError: "tag name"},
+		{tag: Tag{Name: "", Attributes: map[string]string{"a": "b"}}, expectedError: "nameless tags cannot contain attributes"},
+		{tag: Tag{Name: "a", Attributes: map[string]string{"b=c": "d"}}, expectedError: "attribute name"},
+		{tag: Tag{Name: "a", Attributes: map[string]string{"": "d"}}, expectedError: "attribute name"},
+		{tag: Tag{Name: "a", Children: []Tag{{Name: TextTagName}}}, expectedError: "missing it's required text"},
+	}
+
+	for _, def := range test_defs {
+		_, err := SerializeToString(&def.tag)
+		if err == nil || !strings.Contains(err.Error(), def.expectedError) {
+			t.Errorf("Error was %v but expected %v", err, def.expectedError)
+		}
+	}
+}
+
+func TestSerialize_RoundTripsParsedDocuments(t *testing.T) {
+	documents := []string{
+		"<p>Hello, World! 🐶</p>",
+		"<><>Lonely!</></>",
+		"< />",
+		`<html lang='en'><head><meta charset="utf-8" /><title>The Bestest Site Ever!</title></head>
+			<body>
+				<a href="https://www.youtube.com/watch?v=p3G5IXn0K7A">🐹</a>
+				<span style="color:red">Culture</span> and <span style='font-family:"Comic Sans"'>Beauty</span>
+				<p>Escaped &lt;b&gt; &amp; friends</p>
+			</body>
+		</html>`,
+	}
+
+	ignorePositions := cmpopts.IgnoreFields(Tag{}, "StartIdx", "EndIdx")
+
+	for _, document := range documents {
+		original, err := Parse([]rune(document))
+		if err != nil {
+			t.Fatalf("Expected document to parse. Got %v", err)
+		}
+
+		serialized, err := SerializeToString(&original.Root)
+		if err != nil {
+			t.Errorf("Expected document to serialize. Got %v", err)
+			continue
+		}
+
+		reparsed, err := Parse([]rune(serialized))
+		if err != nil {
+			t.Errorf("Expected serialized document to parse. Got %v\n%v", err, serialized)
+			continue
+		}
+
+		if diff := cmp.Diff(original.Root, reparsed.Root, ignorePositions); diff != "" {
+			t.Errorf("Serialized document did not round trip (-want +got):\n%v", diff)
+		}
+	}
+}
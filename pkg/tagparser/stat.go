@@ -1,87 +1,555 @@
 package tagparser
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// Stats summarises a tag document.
+// <text> pseudo tags are counted as text contents rather than tags, so they never appear in the tag statistics.
+// Only totals are stored (rather than averages), so Stats for several documents can be combined.
 type Stats struct {
 	TotalTags          int
 	TotalTextContents  int
 	TotalAttributes    int
 	TagHistogram       map[string]int
 	AttributeHistogram map[string]int
+
+	// The number of tags without a name (i.e. <>Content</>)
+	NamelessTags int
+	// The number of tags written as <tag /> and as <tag></tag>.
+	// These can only be found in the source document, so are only calculated by CalculateDocumentStats
+	SelfClosingTags int
+	PairedTags      int
+
+	// The deepest tag, where the tag the stats were calculated for has a depth of 0
+	MaxDepth int
+	// The sum of the depth of every tag. See AverageDepth
+	TotalDepth int
+	// The number of tags at each depth
+	DepthHistogram map[int]int
+
+	// The total number of children (including text) of the tags with each name. See AverageChildren
+	ChildHistogram map[string]int
+
+	// The total length of all text contents, in runes. See AverageTextLength
+	TotalTextLength int
+	// The number of text contents in each length range (i.e. "10-99"). See TextLengthRanges
+	TextLengthHistogram map[string]int
+
+	// The number of times each value is used, for each attribute name
+	AttributeValueHistogram map[string]map[string]int
 }
 
-func (s *Stats) Render() string {
-	var builder strings.Builder
+// Merge: Add the stats of another document to these stats, as if both documents had been calculated together.
+// Depths are relative to each document's root, so MaxDepth is the deepest tag across every document
+func (s *Stats) Merge(other Stats) {
+	s.TotalTags += other.TotalTags
+	s.TotalTextContents += other.TotalTextContents
+	s.TotalAttributes += other.TotalAttributes
+	s.NamelessTags += other.NamelessTags
+	s.SelfClosingTags += other.SelfClosingTags
+	s.PairedTags += other.PairedTags
+	s.MaxDepth = max(s.MaxDepth, other.MaxDepth)
+	s.TotalDepth += other.TotalDepth
+	s.TotalTextLength += other.TotalTextLength
+
+	s.TagHistogram = mergeHistogram(s.TagHistogram, other.TagHistogram)
+	s.AttributeHistogram = mergeHistogram(s.AttributeHistogram, other.AttributeHistogram)
+	s.DepthHistogram = mergeHistogram(s.DepthHistogram, other.DepthHistogram)
+	s.ChildHistogram = mergeHistogram(s.ChildHistogram, other.ChildHistogram)
+	s.TextLengthHistogram = mergeHistogram(s.TextLengthHistogram, other.TextLengthHistogram)
+
+	if s.AttributeValueHistogram == nil && other.AttributeValueHistogram != nil {
+		s.AttributeValueHistogram = map[string]map[string]int{}
+	}
+	for key, values := range other.AttributeValueHistogram {
+		s.AttributeValueHistogram[key] = mergeHistogram(s.AttributeValueHistogram[key], values)
+	}
+}
+
+// mergeHistogram: Add the counts of other to histogram, creating histogram if required
+func mergeHistogram[K comparable](histogram map[K]int, other map[K]int) map[K]int {
+	if histogram == nil && other != nil {
+		histogram = map[K]int{}
+	}
+
+	for key, count := range other {
+		histogram[key] += count
+	}
+	return histogram
+}
+
+// TextLengthRanges are the keys of Stats.TextLengthHistogram, from shortest to longest
+var TextLengthRanges = []string{"0-9", "10-99", "100-999", "1000-9999", "10000+"}
+
+// textLengthRange: Find the TextLengthRanges entry for a text length
+func textLengthRange(length int) string {
+	idx := 0
+	for limit := 10; length >= limit && idx < len(TextLengthRanges)-1; limit *= 10 {
+		idx += 1
+	}
+	return TextLengthRanges[idx]
+}
+
+// AverageDepth: The mean depth of all tags
+func (s *Stats) AverageDepth() float64 {
+	return average(s.TotalDepth, s.TotalTags)
+}
+
+// AverageChildren: The mean number of children (including text) of tags with the given name
+func (s *Stats) AverageChildren(name string) float64 {
+	return average(s.ChildHistogram[name], s.TagHistogram[name])
+}
+
+// AverageTextLength: The mean length of text contents, in runes
+func (s *Stats) AverageTextLength() float64 {
+	return average(s.TotalTextLength, s.TotalTextContents)
+}
+
+// AttributeCardinality: The number of distinct values used for an attribute
+func (s *Stats) AttributeCardinality(name string) int {
+	return len(s.AttributeValueHistogram[name])
+}
+
+// TopAttributeValues: The n most used values of an attribute, ordered as SortHistogram. n < 0 returns every value
+func (s *Stats) TopAttributeValues(name string, n int) []HistogramEntry {
+	entries := SortHistogram(s.AttributeValueHistogram[name])
+	if n >= 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+func average(total int, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count)
+}
+
+// StatsFormat selects how Stats are rendered by RenderFormat
+type StatsFormat int
+
+const (
+	// The human readable summary written by Render
+	StatsFormatText StatsFormat = iota
+	StatsFormatJson
+	// A section,name,value row for each total and histogram entry
+	StatsFormatCsv
+	// Markdown tables
+	StatsFormatMarkdown
+)
+
+var statsFormatNames = []string{"text", "json", "csv", "markdown"}
+
+func (f StatsFormat) String() string {
+	if f < 0 || int(f) >= len(statsFormatNames) {
+		return fmt.Sprintf("StatsFormat(%d)", int(f))
+	}
+	return statsFormatNames[f]
+}
+
+// ParseStatsFormat: Find the StatsFormat with the given name (i.e. "csv"). Names are case insensitive
+func ParseStatsFormat(name string) (StatsFormat, error) {
+	for idx, formatName := range statsFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return StatsFormat(idx), nil
+		}
+	}
+
+	return StatsFormatText, fmt.Errorf("unknown stats format %q. expected one of %v", name, strings.Join(statsFormatNames, ", "))
+}
+
+// HistogramEntry is a single name and count from a histogram
+type HistogramEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// SortHistogram: Order a histogram's entries by count (descending) and then by name, so output is deterministic
+func SortHistogram(histogram map[string]int) []HistogramEntry {
+	entries := make([]HistogramEntry, 0, len(histogram))
+	for name, count := range histogram {
+		entries = append(entries, HistogramEntry{Name: name, Count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries
+}
+
+// The number of values shown for each attribute when rendering
+const renderedTopValues = 5
+
+// RenderFormat: Render the stats in the given format. Histograms are always ordered with SortHistogram
+func (s *Stats) RenderFormat(format StatsFormat) (string, error) {
+	switch format {
+	case StatsFormatText:
+		return s.Render(), nil
+	case StatsFormatJson:
+		return s.renderJson()
+	case StatsFormatCsv:
+		return s.renderCsv()
+	case StatsFormatMarkdown:
+		return s.renderMarkdown(), nil
+	default:
+		return "", fmt.Errorf("unknown stats format %v", format)
+	}
+}
+
+// statsValue is a single named statistic, shared by the text, CSV and Markdown renderers
+type statsValue struct {
+	// The human readable label, and the name used in CSV output
+	label string
+	name  string
+	value string
+}
+
+// statsTable is a list of named values, such as a histogram
+type statsTable struct {
+	title string
+	// The CSV section name
+	section    string
+	nameHeader string
+	header     string
+	rows       []statsValue
+}
+
+func formatAverage(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+func (s *Stats) totals() []statsValue {
+	return []statsValue{
+		{label: "Total Tags", name: "tags", value: strconv.Itoa(s.TotalTags)},
+		{label: "Nameless Tags", name: "namelessTags", value: strconv.Itoa(s.NamelessTags)},
+		{label: "Self Closing Tags", name: "selfClosingTags", value: strconv.Itoa(s.SelfClosingTags)},
+		{label: "Paired Tags", name: "pairedTags", value: strconv.Itoa(s.PairedTags)},
+		{label: "Total Text Contents", name: "textContents", value: strconv.Itoa(s.TotalTextContents)},
+		{label: "Total Text Length", name: "textLength", value: strconv.Itoa(s.TotalTextLength)},
+		{label: "Average Text Length", name: "averageTextLength", value: formatAverage(s.AverageTextLength())},
+		{label: "Total Attributes", name: "attributes", value: strconv.Itoa(s.TotalAttributes)},
+		{label: "Max Depth", name: "maxDepth", value: strconv.Itoa(s.MaxDepth)},
+		{label: "Average Depth", name: "averageDepth", value: formatAverage(s.AverageDepth())},
+	}
+}
+
+func (s *Stats) tables() []statsTable {
+	histogramRows := func(histogram map[string]int) []statsValue {
+		rows := make([]statsValue, 0, len(histogram))
+		for _, entry := range SortHistogram(histogram) {
+			rows = append(rows, statsValue{name: entry.Name, value: strconv.Itoa(entry.Count)})
+		}
+		return rows
+	}
+
+	tagHistogram := SortHistogram(s.TagHistogram)
+	averageChildren := make([]statsValue, 0, len(tagHistogram))
+	for _, entry := range tagHistogram {
+		averageChildren = append(averageChildren, statsValue{name: entry.Name, value: formatAverage(s.AverageChildren(entry.Name))})
+	}
+
+	depthHistogram := make([]statsValue, 0, len(s.DepthHistogram))
+	for _, depth := range sortedDepths(s.DepthHistogram) {
+		depthHistogram = append(depthHistogram, statsValue{name: strconv.Itoa(depth), value: strconv.Itoa(s.DepthHistogram[depth])})
+	}
+
+	textLengthHistogram := make([]statsValue, 0)
+	for _, lengthRange := range TextLengthRanges {
+		if count, ok := s.TextLengthHistogram[lengthRange]; ok {
+			textLengthHistogram = append(textLengthHistogram, statsValue{name: lengthRange, value: strconv.Itoa(count)})
+		}
+	}
 
-	printMap := func(m map[string]int) {
-		for key, value := range m {
-			builder.WriteString(fmt.Sprintf("\t%v\t%v\n", key, value))
+	cardinalities := map[string]int{}
+	for name := range s.AttributeValueHistogram {
+		cardinalities[name] = s.AttributeCardinality(name)
+	}
+
+	topValues := make([]statsValue, 0)
+	for _, attribute := range SortHistogram(s.AttributeHistogram) {
+		for _, entry := range s.TopAttributeValues(attribute.Name, renderedTopValues) {
+			topValues = append(topValues, statsValue{name: attribute.Name + "=" + entry.Name, value: strconv.Itoa(entry.Count)})
 		}
 	}
 
+	return []statsTable{
+		{title: "Tag Histogram", section: "tag", nameHeader: "Tag", header: "Count", rows: histogramRows(s.TagHistogram)},
+		{title: "Average Children", section: "averageChildren", nameHeader: "Tag", header: "Average", rows: averageChildren},
+		{title: "Depth Histogram", section: "depth", nameHeader: "Depth", header: "Count", rows: depthHistogram},
+		{title: "Text Length Histogram", section: "textLength", nameHeader: "Length", header: "Count", rows: textLengthHistogram},
+		{title: "Attribute Histogram", section: "attribute", nameHeader: "Attribute", header: "Count", rows: histogramRows(s.AttributeHistogram)},
+		{title: "Attribute Cardinality", section: "attributeCardinality", nameHeader: "Attribute", header: "Distinct Values", rows: histogramRows(cardinalities)},
+		{title: "Top Attribute Values", section: "attributeValue", nameHeader: "Value", header: "Count", rows: topValues},
+	}
+}
+
+func sortedDepths(histogram map[int]int) []int {
+	depths := make([]int, 0, len(histogram))
+	for depth := range histogram {
+		depths = append(depths, depth)
+	}
+	sort.Ints(depths)
+	return depths
+}
+
+// Render: Render a human readable summary of the stats
+func (s *Stats) Render() string {
+	var builder strings.Builder
+
 	builder.WriteString("\nTag Document Statistics:\n")
 	builder.WriteString("------------------------\n")
-	builder.WriteString(fmt.Sprintf("Total Tags: %v\n", s.TotalTags))
-	builder.WriteString(fmt.Sprintf("Total Text Contents: %v\n", s.TotalTextContents))
-	builder.WriteString(fmt.Sprintf("Total Attributes: %v\n", s.TotalAttributes))
+	for _, total := range s.totals() {
+		builder.WriteString(fmt.Sprintf("%v: %v\n", total.label, total.value))
+	}
+
+	for _, table := range s.tables() {
+		builder.WriteString(fmt.Sprintf("\n%v:\n", table.title))
+		for _, row := range table.rows {
+			builder.WriteString(fmt.Sprintf("\t%v\t%v\n", row.name, row.value))
+		}
+	}
+
+	return builder.String()
+}
+
+type depthEntry struct {
+	Depth int `json:"depth"`
+	Count int `json:"count"`
+}
+
+type averageEntry struct {
+	Name    string  `json:"name"`
+	Average float64 `json:"average"`
+}
+
+type attributeValuesEntry struct {
+	Name        string           `json:"name"`
+	Cardinality int              `json:"cardinality"`
+	TopValues   []HistogramEntry `json:"topValues"`
+}
+
+type statsJson struct {
+	TotalTags           int                    `json:"totalTags"`
+	NamelessTags        int                    `json:"namelessTags"`
+	SelfClosingTags     int                    `json:"selfClosingTags"`
+	PairedTags          int                    `json:"pairedTags"`
+	TotalTextContents   int                    `json:"totalTextContents"`
+	TotalTextLength     int                    `json:"totalTextLength"`
+	AverageTextLength   float64                `json:"averageTextLength"`
+	TotalAttributes     int                    `json:"totalAttributes"`
+	MaxDepth            int                    `json:"maxDepth"`
+	AverageDepth        float64                `json:"averageDepth"`
+	TagHistogram        []HistogramEntry       `json:"tagHistogram"`
+	AverageChildren     []averageEntry         `json:"averageChildren"`
+	DepthHistogram      []depthEntry           `json:"depthHistogram"`
+	TextLengthHistogram []HistogramEntry       `json:"textLengthHistogram"`
+	AttributeHistogram  []HistogramEntry       `json:"attributeHistogram"`
+	AttributeValues     []attributeValuesEntry `json:"attributeValues"`
+}
+
+// renderJson: Render the stats as a JSON object. Histograms are written as arrays of {"name", "count"} objects,
+// as JSON objects are unordered
+func (s *Stats) renderJson() (string, error) {
+	output := statsJson{
+		TotalTags:           s.TotalTags,
+		NamelessTags:        s.NamelessTags,
+		SelfClosingTags:     s.SelfClosingTags,
+		PairedTags:          s.PairedTags,
+		TotalTextContents:   s.TotalTextContents,
+		TotalTextLength:     s.TotalTextLength,
+		AverageTextLength:   s.AverageTextLength(),
+		TotalAttributes:     s.TotalAttributes,
+		MaxDepth:            s.MaxDepth,
+		AverageDepth:        s.AverageDepth(),
+		TagHistogram:        SortHistogram(s.TagHistogram),
+		AverageChildren:     make([]averageEntry, 0),
+		DepthHistogram:      make([]depthEntry, 0),
+		TextLengthHistogram: make([]HistogramEntry, 0),
+		AttributeHistogram:  SortHistogram(s.AttributeHistogram),
+		AttributeValues:     make([]attributeValuesEntry, 0),
+	}
+
+	for _, entry := range output.TagHistogram {
+		output.AverageChildren = append(output.AverageChildren, averageEntry{Name: entry.Name, Average: s.AverageChildren(entry.Name)})
+	}
+
+	for _, depth := range sortedDepths(s.DepthHistogram) {
+		output.DepthHistogram = append(output.DepthHistogram, depthEntry{Depth: depth, Count: s.DepthHistogram[depth]})
+	}
+
+	for _, lengthRange := range TextLengthRanges {
+		if count, ok := s.TextLengthHistogram[lengthRange]; ok {
+			output.TextLengthHistogram = append(output.TextLengthHistogram, HistogramEntry{Name: lengthRange, Count: count})
+		}
+	}
+
+	for _, entry := range output.AttributeHistogram {
+		output.AttributeValues = append(output.AttributeValues, attributeValuesEntry{
+			Name:        entry.Name,
+			Cardinality: s.AttributeCardinality(entry.Name),
+			TopValues:   s.TopAttributeValues(entry.Name, renderedTopValues),
+		})
+	}
+
+	bytes, err := json.MarshalIndent(output, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes) + "\n", nil
+}
+
+// renderCsv: Render the stats as CSV rows of section,name,value
+func (s *Stats) renderCsv() (string, error) {
+	var builder strings.Builder
+	writer := csv.NewWriter(&builder)
+
+	rows := [][]string{{"section", "name", "value"}}
+	for _, total := range s.totals() {
+		rows = append(rows, []string{"total", total.name, total.value})
+	}
 
-	builder.WriteString("\nTag Histogram:\n")
-	printMap(s.TagHistogram)
+	for _, table := range s.tables() {
+		for _, row := range table.rows {
+			rows = append(rows, []string{table.section, row.name, row.value})
+		}
+	}
 
-	builder.WriteString("\nAttribute Histogram:\n")
-	printMap(s.AttributeHistogram)
+	if err := writer.WriteAll(rows); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// renderMarkdown: Render the stats as Markdown tables
+func (s *Stats) renderMarkdown() string {
+	var builder strings.Builder
+
+	// Pipes would end the table cell, and line breaks the row
+	escape := strings.NewReplacer("|", "\\|", "\n", " ", "\r", " ")
+
+	builder.WriteString("## Tag Document Statistics\n\n")
+	builder.WriteString("| Statistic | Value |\n")
+	builder.WriteString("| --- | ---: |\n")
+	for _, total := range s.totals() {
+		builder.WriteString(fmt.Sprintf("| %v | %v |\n", total.label, total.value))
+	}
+
+	for _, table := range s.tables() {
+		builder.WriteString(fmt.Sprintf("\n### %v\n\n", table.title))
+		builder.WriteString(fmt.Sprintf("| %v | %v |\n", table.nameHeader, table.header))
+		builder.WriteString("| --- | ---: |\n")
+		for _, row := range table.rows {
+			builder.WriteString(fmt.Sprintf("| %v | %v |\n", escape.Replace(row.name), row.value))
+		}
+	}
 
 	return builder.String()
 }
 
+// CalculateStats: Calculate the stats for a tag and all of it's descendants.
+// Self closing and paired tags are not counted, as they require the source document. See CalculateDocumentStats
 func CalculateStats(tag *Tag) (stats Stats) {
+	return calculateStats(tag, nil)
+}
+
+// CalculateDocumentStats: Calculate the stats for a parsed document, including the counts of self closing and paired tags
+func CalculateDocumentStats(result ParseResult) Stats {
+	return calculateStats(&result.Root, result.Document)
+}
+
+func calculateStats(tag *Tag, document []rune) (stats Stats) {
 	if tag == nil {
 		return
 	}
 
 	stats.TagHistogram = map[string]int{}
 	stats.AttributeHistogram = map[string]int{}
+	stats.DepthHistogram = map[int]int{}
+	stats.ChildHistogram = map[string]int{}
+	stats.TextLengthHistogram = map[string]int{}
+	stats.AttributeValueHistogram = map[string]map[string]int{}
 
-	var visit func(t *Tag)
-	visit = func(t *Tag) {
+	enter := func(t *Tag, ancestors []*Tag) error {
+		if t.Name == TextTagName {
+			length := len([]rune(t.Attributes[TextAttributeName]))
+			stats.TotalTextContents += 1
+			stats.TotalTextLength += length
+			stats.TextLengthHistogram[textLengthRange(length)] += 1
+			return SkipChildren
+		}
+
+		depth := len(ancestors)
 		stats.TotalTags += 1
+		stats.TotalDepth += depth
+		stats.MaxDepth = max(stats.MaxDepth, depth)
+		stats.DepthHistogram[depth] += 1
+		stats.TagHistogram[t.Name] += 1
+		stats.ChildHistogram[t.Name] += len(t.Children)
 
-		tagCount, ok := stats.TagHistogram[t.Name]
-		if !ok {
-			stats.TagHistogram[t.Name] = 1
-		} else {
-			stats.TagHistogram[t.Name] = tagCount + 1
+		if t.Name == "" {
+			stats.NamelessTags += 1
 		}
 
-		if t.Attributes != nil {
-			for key := range t.Attributes {
-				stats.TotalAttributes += 1
-				count, ok := stats.AttributeHistogram[key]
-				if !ok {
-					stats.AttributeHistogram[key] = 1
-				} else {
-					stats.AttributeHistogram[key] = count + 1
-				}
+		if document != nil {
+			if isSelfClosing(t, document) {
+				stats.SelfClosingTags += 1
+			} else {
+				stats.PairedTags += 1
 			}
 		}
 
-		if t.Children == nil {
-			return
-		}
+		for key, value := range t.Attributes {
+			stats.TotalAttributes += 1
+			stats.AttributeHistogram[key] += 1
 
-		for _, child := range t.Children {
-			if child.Name == TextTagName {
-				stats.TotalTextContents += 1
-			} else {
-				visit(&child)
+			values, ok := stats.AttributeValueHistogram[key]
+			if !ok {
+				values = map[string]int{}
+				stats.AttributeValueHistogram[key] = values
 			}
+			values[value] += 1
 		}
+
+		return nil
 	}
 
-	visit(tag)
+	walk(tag, enter, func(*Tag, []*Tag) error { return nil })
 
 	return
 }
+
+// isSelfClosing: Check if a tag was written as <tag />, by finding the end of it's opening tag in the document.
+// Quoted attribute values are skipped, as they may contain '>'
+func isSelfClosing(tag *Tag, document []rune) bool {
+	if len(tag.Children) > 0 || tag.StartIdx < 0 || tag.EndIdx > len(document) {
+		return false
+	}
+
+	var quote rune
+	for idx := tag.StartIdx; idx < tag.EndIdx; idx++ {
+		r := document[idx]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '>':
+			return idx == tag.EndIdx-1 && idx > tag.StartIdx && document[idx-1] == '/'
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,7 @@
 package tagparser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -22,9 +23,13 @@ func TestCalcStats_BasicTag(t *testing.T) {
 
 	got := CalculateStats(tag)
 	want := Stats{
-		TotalTags:          1,
-		TagHistogram:       map[string]int{"Hello!": 1},
-		AttributeHistogram: map[string]int{},
+		TotalTags:               1,
+		TagHistogram:            map[string]int{"Hello!": 1},
+		AttributeHistogram:      map[string]int{},
+		DepthHistogram:          map[int]int{0: 1},
+		ChildHistogram:          map[string]int{"Hello!": 0},
+		TextLengthHistogram:     map[string]int{},
+		AttributeValueHistogram: map[string]map[string]int{},
 	}
 
 	if !cmp.Equal(got, want) {
@@ -56,14 +61,474 @@ func TestCalcStats_ComplexTags(t *testing.T) {
 
 	got := CalculateStats(tag)
 	want := Stats{
-		TotalTags:          3,
-		TotalTextContents:  1,
-		TotalAttributes:    3,
-		TagHistogram:       map[string]int{"Beans": 1, "Cool": 2},
-		AttributeHistogram: map[string]int{"A": 2, "B": 1},
+		TotalTags:               3,
+		TotalTextContents:       1,
+		TotalAttributes:         3,
+		TagHistogram:            map[string]int{"Beans": 1, "Cool": 2},
+		AttributeHistogram:      map[string]int{"A": 2, "B": 1},
+		MaxDepth:                1,
+		TotalDepth:              2,
+		DepthHistogram:          map[int]int{0: 1, 1: 2},
+		ChildHistogram:          map[string]int{"Beans": 0, "Cool": 3},
+		TotalTextLength:         1,
+		TextLengthHistogram:     map[string]int{"0-9": 1},
+		AttributeValueHistogram: map[string]map[string]int{"A": {"B": 1, "C": 1}, "B": {"C": 1}},
 	}
 
 	if !cmp.Equal(got, want) {
 		t.Errorf("CalculateStats doesn't work for Complex Tags. Got %v Want %v", got, want)
 	}
 }
+
+func TestCalculateStats_CountsTextConsistently(t *testing.T) {
+	text := &Tag{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "Lonely"}}
+	got := CalculateStats(text)
+	if got.TotalTags != 0 || got.TotalTextContents != 1 || got.TotalAttributes != 0 || len(got.TagHistogram) != 0 || got.TotalTextLength != 6 {
+		t.Errorf("CalculateStats should count a text root as text content. Got %+v", got)
+	}
+}
+
+func TestCalculateDocumentStats_CountsTagForms(t *testing.T) {
+	result, err := Parse([]rune(`<html><br/><img alt="a />"   /><p>Hi</p><></><a href=">"></a>< /></html>`))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	got := CalculateDocumentStats(result)
+	if got.SelfClosingTags != 3 || got.PairedTags != 4 || got.NamelessTags != 2 {
+		t.Errorf("Incorrect tag form counts. Got %v self closing, %v paired and %v nameless", got.SelfClosingTags, got.PairedTags, got.NamelessTags)
+	}
+
+	if stats := CalculateStats(&result.Root); stats.SelfClosingTags != 0 || stats.PairedTags != 0 {
+		t.Errorf("CalculateStats shouldn't count tag forms without the document. Got %+v", stats)
+	}
+}
+
+func TestStats_CalculatesAverages(t *testing.T) {
+	result, err := Parse([]rune(`<ul><li class="a">One</li><li class="a">A much longer item</li><li class="b"><b>3</b></li></ul>`))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	stats := CalculateStats(&result.Root)
+
+	if got := stats.AverageDepth(); got != 1.0 {
+		t.Errorf("Incorrect average depth. Got %v", got)
+	}
+
+	if got := stats.AverageChildren("ul"); got != 3 {
+		t.Errorf("Incorrect average children for ul. Got %v", got)
+	}
+
+	if got := stats.AverageChildren("missing"); got != 0 {
+		t.Errorf("Incorrect average children for a missing tag. Got %v", got)
+	}
+
+	if got := stats.AverageTextLength(); got != 22.0/3.0 {
+		t.Errorf("Incorrect average text length. Got %v", got)
+	}
+
+	if got := stats.TextLengthHistogram; !cmp.Equal(got, map[string]int{"0-9": 2, "10-99": 1}) {
+		t.Errorf("Incorrect text length histogram. Got %v", got)
+	}
+
+	if got := stats.AttributeCardinality("class"); got != 2 {
+		t.Errorf("Incorrect attribute cardinality. Got %v", got)
+	}
+
+	want := []HistogramEntry{{Name: "a", Count: 2}}
+	if got := stats.TopAttributeValues("class", 1); !cmp.Equal(got, want) {
+		t.Errorf("Incorrect top attribute values. Got %v Want %v", got, want)
+	}
+}
+
+func TestTextLengthRange(t *testing.T) {
+	type Def struct {
+		length int
+		want   string
+	}
+
+	test_defs := []Def{{0, "0-9"}, {9, "0-9"}, {10, "10-99"}, {999, "100-999"}, {1000, "1000-9999"}, {10000, "10000+"}, {1000000, "10000+"}}
+	for _, def := range test_defs {
+		if got := textLengthRange(def.length); got != def.want {
+			t.Errorf("Incorrect range for length %v. Got %v Want %v", def.length, got, def.want)
+		}
+	}
+}
+
+var renderInput string = `<html lang="en"><body><p class="x">Hello</p><p class="x">World</p><span class="y|z" /></body></html>`
+
+func renderStats(t *testing.T) Stats {
+	result, err := Parse([]rune(renderInput))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+	return CalculateDocumentStats(result)
+}
+
+func TestStatsRender_IsDeterministic(t *testing.T) {
+	want := `
+Tag Document Statistics:
+------------------------
+Total Tags: 5
+Nameless Tags: 0
+Self Closing Tags: 1
+Paired Tags: 4
+Total Text Contents: 2
+Total Text Length: 10
+Average Text Length: 5.00
+Total Attributes: 4
+Max Depth: 2
+Average Depth: 1.40
+
+Tag Histogram:
+	p	2
+	body	1
+	html	1
+	span	1
+
+Average Children:
+	p	1.00
+	body	3.00
+	html	1.00
+	span	0.00
+
+Depth Histogram:
+	0	1
+	1	1
+	2	3
+
+Text Length Histogram:
+	0-9	2
+
+Attribute Histogram:
+	class	3
+	lang	1
+
+Attribute Cardinality:
+	class	2
+	lang	1
+
+Top Attribute Values:
+	class=x	2
+	class=y|z	1
+	lang=en	1
+`
+
+	stats := renderStats(t)
+	for i := 0; i < 10; i++ {
+		got := stats.Render()
+		if got != want {
+			t.Fatalf("Render produced incorrect output.\nGot:\n%v\nWant:\n%v", got, want)
+		}
+	}
+}
+
+func TestStatsRenderFormat_WritesFormats(t *testing.T) {
+	type Def struct {
+		format StatsFormat
+		want   string
+	}
+
+	stats := renderStats(t)
+	test_defs := []Def{
+		{format: StatsFormatText, want: stats.Render()},
+		{
+			format: StatsFormatJson,
+			want: `{
+    "totalTags": 5,
+    "namelessTags": 0,
+    "selfClosingTags": 1,
+    "pairedTags": 4,
+    "totalTextContents": 2,
+    "totalTextLength": 10,
+    "averageTextLength": 5,
+    "totalAttributes": 4,
+    "maxDepth": 2,
+    "averageDepth": 1.4,
+    "tagHistogram": [
+        {
+            "name": "p",
+            "count": 2
+        },
+        {
+            "name": "body",
+            "count": 1
+        },
+        {
+            "name": "html",
+            "count": 1
+        },
+        {
+            "name": "span",
+            "count": 1
+        }
+    ],
+    "averageChildren": [
+        {
+            "name": "p",
+            "average": 1
+        },
+        {
+            "name": "body",
+            "average": 3
+        },
+        {
+            "name": "html",
+            "average": 1
+        },
+        {
+            "name": "span",
+            "average": 0
+        }
+    ],
+    "depthHistogram": [
+        {
+            "depth": 0,
+            "count": 1
+        },
+        {
+            "depth": 1,
+            "count": 1
+        },
+        {
+            "depth": 2,
+            "count": 3
+        }
+    ],
+    "textLengthHistogram": [
+        {
+            "name": "0-9",
+            "count": 2
+        }
+    ],
+    "attributeHistogram": [
+        {
+            "name": "class",
+            "count": 3
+        },
+        {
+            "name": "lang",
+            "count": 1
+        }
+    ],
+    "attributeValues": [
+        {
+            "name": "class",
+            "cardinality": 2,
+            "topValues": [
+                {
+                    "name": "x",
+                    "count": 2
+                },
+                {
+                    "name": "y|z",
+                    "count": 1
+                }
+            ]
+        },
+        {
+            "name": "lang",
+            "cardinality": 1,
+            "topValues": [
+                {
+                    "name": "en",
+                    "count": 1
+                }
+            ]
+        }
+    ]
+}
+`,
+		},
+		{
+			format: StatsFormatCsv,
+			want: `section,name,value
+total,tags,5
+total,namelessTags,0
+total,selfClosingTags,1
+total,pairedTags,4
+total,textContents,2
+total,textLength,10
+total,averageTextLength,5.00
+total,attributes,4
+total,maxDepth,2
+total,averageDepth,1.40
+tag,p,2
+tag,body,1
+tag,html,1
+tag,span,1
+averageChildren,p,1.00
+averageChildren,body,3.00
+averageChildren,html,1.00
+averageChildren,span,0.00
+depth,0,1
+depth,1,1
+depth,2,3
+textLength,0-9,2
+attribute,class,3
+attribute,lang,1
+attributeCardinality,class,2
+attributeCardinality,lang,1
+attributeValue,class=x,2
+attributeValue,class=y|z,1
+attributeValue,lang=en,1
+`,
+		},
+		{
+			format: StatsFormatMarkdown,
+			want: `## Tag Document Statistics
+
+| Statistic | Value |
+| --- | ---: |
+| Total Tags | 5 |
+| Nameless Tags | 0 |
+| Self Closing Tags | 1 |
+| Paired Tags | 4 |
+| Total Text Contents | 2 |
+| Total Text Length | 10 |
+| Average Text Length | 5.00 |
+| Total Attributes | 4 |
+| Max Depth | 2 |
+| Average Depth | 1.40 |
+
+### Tag Histogram
+
+| Tag | Count |
+| --- | ---: |
+| p | 2 |
+| body | 1 |
+| html | 1 |
+| span | 1 |
+
+### Average Children
+
+| Tag | Average |
+| --- | ---: |
+| p | 1.00 |
+| body | 3.00 |
+| html | 1.00 |
+| span | 0.00 |
+
+### Depth Histogram
+
+| Depth | Count |
+| --- | ---: |
+| 0 | 1 |
+| 1 | 1 |
+| 2 | 3 |
+
+### Text Length Histogram
+
+| Length | Count |
+| --- | ---: |
+| 0-9 | 2 |
+
+### Attribute Histogram
+
+| Attribute | Count |
+| --- | ---: |
+| class | 3 |
+| lang | 1 |
+
+### Attribute Cardinality
+
+| Attribute | Distinct Values |
+| --- | ---: |
+| class | 2 |
+| lang | 1 |
+
+### Top Attribute Values
+
+| Value | Count |
+| --- | ---: |
+| class=x | 2 |
+| class=y\|z | 1 |
+| lang=en | 1 |
+`,
+		},
+	}
+
+	for _, def := range test_defs {
+		got, err := stats.RenderFormat(def.format)
+		if err != nil || got != def.want {
+			t.Errorf("Incorrect %v output (%v).\nGot:\n%v\nWant:\n%v", def.format, err, got, def.want)
+		}
+	}
+
+	_, err := stats.RenderFormat(StatsFormat(100))
+	if err == nil || !strings.Contains(err.Error(), "unknown stats format") {
+		t.Errorf("Expected an unknown format error. Got %v", err)
+	}
+}
+
+func TestParseStatsFormat(t *testing.T) {
+	for format := StatsFormatText; format <= StatsFormatMarkdown; format++ {
+		got, err := ParseStatsFormat(strings.ToUpper(format.String()))
+		if err != nil || got != format {
+			t.Errorf("ParseStatsFormat couldn't parse %v. Got %v (%v)", format, got, err)
+		}
+	}
+
+	_, err := ParseStatsFormat("xml")
+	if err == nil || !strings.Contains(err.Error(), "unknown stats format") {
+		t.Errorf("Expected an unknown format error. Got %v", err)
+	}
+}
+
+func TestStatsMerge_CombinesDocuments(t *testing.T) {
+	documents := []string{
+		`<html lang="en"><body><p class="x">Hello</p><br /></body></html>`,
+		`<ul><li class="x">One</li><li class="y"><b>A much longer item</b></li></ul>`,
+		`<>Lonely</>`,
+	}
+
+	var merged Stats
+	combined := `<all>`
+	for _, document := range documents {
+		result, err := Parse([]rune(document))
+		if err != nil {
+			t.Fatalf("Expected document to parse. Got %v", err)
+		}
+
+		merged.Merge(CalculateDocumentStats(result))
+		combined += document
+	}
+	combined += `</all>`
+
+	// Calculating the stats of every document inside a single root should give the same result, once the root is removed
+	result, err := Parse([]rune(combined))
+	if err != nil {
+		t.Fatalf("Expected combined document to parse. Got %v", err)
+	}
+
+	var want Stats
+	for idx := range result.Root.Children {
+		want.Merge(calculateStats(&result.Root.Children[idx], result.Document))
+	}
+
+	if diff := cmp.Diff(want, merged); diff != "" {
+		t.Errorf("Merged stats were incorrect (-want +got):\n%v", diff)
+	}
+
+	if merged.TotalTags != 9 || merged.MaxDepth != 2 || merged.SelfClosingTags != 1 || merged.NamelessTags != 1 ||
+		merged.AttributeValueHistogram["class"]["x"] != 2 || merged.TagHistogram["li"] != 2 {
+		t.Errorf("Merged stats were incorrect. Got %+v", merged)
+	}
+}
+
+func TestStatsMerge_WorksWithEmptyStats(t *testing.T) {
+	stats := CalculateStats(&Tag{Name: "a", Attributes: map[string]string{"b": "c"}})
+	want := CalculateStats(&Tag{Name: "a", Attributes: map[string]string{"b": "c"}})
+
+	stats.Merge(Stats{})
+	if diff := cmp.Diff(want, stats); diff != "" {
+		t.Errorf("Merging empty stats changed the stats (-want +got):\n%v", diff)
+	}
+
+	var empty Stats
+	empty.Merge(want)
+	if diff := cmp.Diff(want, empty); diff != "" {
+		t.Errorf("Merging into empty stats was incorrect (-want +got):\n%v", diff)
+	}
+}
@@ -1,5 +1,10 @@
 package tagparser
 
+import (
+	"sort"
+	"strings"
+)
+
 var TextTagName string = "<text>"
 var TextAttributeName string = "text"
 
@@ -16,6 +21,61 @@ type Tag struct {
 	Attributes map[string]string
 }
 
+// Render: The original markup of the tag within the document it was parsed from.
+// Tags without a position in the document (such as those built with NewElement, or edited since they were parsed) are
+// serialized instead, as are tags containing them.
+// Serialized tags which cannot be written as markup (i.e. with an attribute name the parser cannot read) render as the
+// empty string. Use Serialize to find out why
 func (t *Tag) Render(document []rune) string {
-	return string(document[t.StartIdx:t.EndIdx])
+	var sb strings.Builder
+	if renderDocument(&sb, t, document) {
+		return sb.String()
+	}
+
+	markup, err := SerializeToString(t)
+	if err != nil {
+		return ""
+	}
+	return markup
+}
+
+// renderDocument: Write the markup of a tag from the document, copying the markup between it's children as they are
+// visited, so that the tree is only walked once. Returns false (having written part of the markup) if the tag or any
+// of it's descendants has no position within the document
+func renderDocument(sb *strings.Builder, t *Tag, document []rune) bool {
+	if t.IsSynthetic() || t.EndIdx > len(document) {
+		return false
+	}
+
+	idx := t.StartIdx
+	for childIdx := range t.Children {
+		child := &t.Children[childIdx]
+		if child.StartIdx < idx || child.EndIdx > t.EndIdx {
+			return false
+		}
+
+		sb.WriteString(string(document[idx:child.StartIdx]))
+		if !renderDocument(sb, child, document) {
+			return false
+		}
+		idx = child.EndIdx
+	}
+
+	sb.WriteString(string(document[idx:t.EndIdx]))
+	return true
+}
+
+// sortedAttributeKeys: Get the attribute names of an element in sorted order, as Tag attributes are unordered.
+// The text of a <text> pseudo tag is not treated as an attribute.
+func sortedAttributeKeys(tag *Tag) []string {
+	keys := make([]string, 0, len(tag.Attributes))
+	if tag.Name == TextTagName {
+		return keys
+	}
+
+	for key := range tag.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,216 @@
+package tagparser
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// InnerText: The text content of the tag and all of it's descendants, in document order, joined by the separator.
+// For a text tag, this is it's own text
+func (t *Tag) InnerText(separator string) string {
+	texts := make([]string, 0)
+	Walk(t, func(tag *Tag) error {
+		if tag.Name == TextTagName {
+			texts = append(texts, tag.Attributes[TextAttributeName])
+			return SkipChildren
+		}
+		return nil
+	})
+	return strings.Join(texts, separator)
+}
+
+// PlainTextOptions configures WritePlainText. The zero value writes link URLs and uses "- " for list items
+type PlainTextOptions struct {
+	// Leave out the URLs of links
+	OmitLinks bool
+	// The marker written before unordered list items. Defaults to "- "
+	Bullet string
+}
+
+// Elements which are written on their own lines, separated by a blank line
+var plainTextParagraphs = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "table": true, "figure": true, "hr": true,
+}
+
+// Elements which are written on their own lines
+var plainTextBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "body": true, "dd": true, "details": true, "dialog": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true, "footer": true, "form": true,
+	"header": true, "html": true, "li": true, "main": true, "nav": true, "ol": true, "section": true, "summary": true,
+	"tr": true, "ul": true, "title": true, "caption": true,
+}
+
+// Elements whose content is never written
+var plainTextSkipped = map[string]bool{"head": true, "script": true, "style": true, "template": true}
+
+// WritePlainText: Write a tree as readable plain text, such as for a search index.
+//
+// Block-level elements (i.e. p, div, li and headings) start new lines, with paragraphs and headings separated by a blank
+// line. br elements are written as line breaks. List items are prefixed with a bullet (or their number in ordered lists),
+// and nested lists are indented. Links are followed by their URL in brackets (unless the text is the URL, or the link
+// is to a fragment or script), and images are written as their [alt text]. Table cells are separated by tabs, and other
+// text by a single space (except before punctuation).
+// The content of head, script, style and template elements is left out. Tag names are matched case-insensitively
+func WritePlainText(w io.Writer, tag *Tag, opts PlainTextOptions) error {
+	if opts.Bullet == "" {
+		opts.Bullet = "- "
+	}
+
+	pw := &plainTextWriter{}
+	err := WalkEnterExit(tag,
+		func(t *Tag) error {
+			if t.Name == TextTagName {
+				pw.write(t.Attributes[TextAttributeName])
+				return SkipChildren
+			}
+
+			name := strings.ToLower(t.Name)
+			switch {
+			case plainTextSkipped[name]:
+				return SkipChildren
+			case plainTextParagraphs[name]:
+				pw.requestBreak(2)
+			case plainTextBlocks[name]:
+				pw.requestBreak(1)
+			}
+
+			switch name {
+			case "br":
+				pw.lineBreak()
+			case "ul", "ol":
+				pw.lists = append(pw.lists, plainTextList{ordered: name == "ol"})
+			case "li":
+				marker := opts.Bullet
+				if len(pw.lists) > 0 && pw.lists[len(pw.lists)-1].ordered {
+					pw.lists[len(pw.lists)-1].count += 1
+					marker = strconv.Itoa(pw.lists[len(pw.lists)-1].count) + ". "
+				}
+
+				indent := strings.Repeat("  ", max(len(pw.lists)-1, 0))
+				pw.bullet = indent + marker
+				pw.indents = append(pw.indents, indent+strings.Repeat(" ", len([]rune(marker))))
+			case "td", "th":
+				pw.cell()
+			case "img":
+				if alt := t.Attributes["alt"]; alt != "" {
+					pw.write("[" + alt + "]")
+				}
+			}
+			return nil
+		},
+		func(t *Tag) error {
+			name := strings.ToLower(t.Name)
+			switch name {
+			case "ul", "ol":
+				pw.lists = pw.lists[:len(pw.lists)-1]
+			case "li":
+				pw.indents = pw.indents[:len(pw.indents)-1]
+			case "a":
+				href := t.Attributes["href"]
+				if !opts.OmitLinks && href != "" && !strings.HasPrefix(href, "#") &&
+					!strings.HasPrefix(strings.ToLower(href), "javascript:") && t.InnerText(" ") != href {
+					pw.write("(" + href + ")")
+				}
+			}
+
+			switch {
+			case plainTextParagraphs[name]:
+				pw.requestBreak(2)
+			case plainTextBlocks[name]:
+				pw.requestBreak(1)
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	text := strings.TrimRight(pw.sb.String(), " \t\n")
+	if text != "" {
+		text += "\n"
+	}
+	_, err = io.WriteString(w, text)
+	return err
+}
+
+// PlainText: A tree as plain text. See WritePlainText
+func PlainText(tag *Tag, opts PlainTextOptions) (string, error) {
+	var sb strings.Builder
+	if err := WritePlainText(&sb, tag, opts); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+type plainTextList struct {
+	ordered bool
+	// The number of items so far
+	count int
+}
+
+// plainTextWriter lays out text, collapsing whitespace and deferring line breaks until there is more text to write
+type plainTextWriter struct {
+	sb strings.Builder
+	// The number of line breaks to write before the next text
+	pendingBreaks int
+	// Written between the previous text on the current line and the next
+	separator string
+	lists     []plainTextList
+	// The indentation of the lines within each open list item
+	indents []string
+	// The marker to write at the start of the next line, for a new list item
+	bullet string
+}
+
+func (pw *plainTextWriter) requestBreak(breaks int) {
+	pw.pendingBreaks = max(pw.pendingBreaks, breaks)
+}
+
+func (pw *plainTextWriter) lineBreak() {
+	if pw.sb.Len() == 0 {
+		return
+	}
+
+	// A line break after a line break leaves a blank line
+	pw.pendingBreaks = min(pw.pendingBreaks+1, 2)
+}
+
+// cell: Separate the next text from the previous cell in the row. The first cell of a row starts a line, so has no separator
+func (pw *plainTextWriter) cell() {
+	pw.separator = "\t"
+}
+
+func (pw *plainTextWriter) write(text string) {
+	text = normalizeSpace(text)
+	if text == "" {
+		return
+	}
+
+	switch {
+	case pw.sb.Len() > 0 && pw.pendingBreaks > 0:
+		pw.sb.WriteString(strings.Repeat("\n", pw.pendingBreaks))
+		pw.startLine()
+	case pw.sb.Len() == 0:
+		pw.startLine()
+	case pw.separator == " " && strings.ContainsAny(text[:1], ".,;:!?)"):
+		// The parser trims the space around text, so punctuation following an element is assumed to be attached to it
+	default:
+		pw.sb.WriteString(pw.separator)
+	}
+
+	pw.pendingBreaks = 0
+	pw.sb.WriteString(text)
+	pw.separator = " "
+}
+
+func (pw *plainTextWriter) startLine() {
+	switch {
+	case pw.bullet != "":
+		pw.sb.WriteString(pw.bullet)
+		pw.bullet = ""
+	case len(pw.indents) > 0:
+		pw.sb.WriteString(pw.indents[len(pw.indents)-1])
+	}
+}
@@ -0,0 +1,85 @@
+package tagparser
+
+import (
+	"testing"
+)
+
+func TestInnerText(t *testing.T) {
+	root := mustParse(t, `<div><h1>Title</h1><p>Hello <b>bold</b> world</p><script/></div>`)
+
+	cases := []struct {
+		tag       *Tag
+		separator string
+		want      string
+	}{
+		{root, " ", "Title Hello bold world"},
+		{root, "|", "Title|Hello|bold|world"},
+		{&root.Children[1], "", "Helloboldworld"},
+		{&root.Children[1].Children[0], " ", "Hello"},
+		{&root.Children[2], " ", ""},
+	}
+
+	for _, c := range cases {
+		if got := c.tag.InnerText(c.separator); got != c.want {
+			t.Errorf("InnerText(%q) of <%v>. Got %q Want %q", c.separator, c.tag.Name, got, c.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	document := `<html>
+  <head><title>Ignored</title><style>p { color: red }</style></head>
+  <body>
+    <h1>Welcome</h1>
+    <p>Read the <a href="https://example.com/docs">docs</a>, or <a href="#top">go back</a>.</p>
+    <ul>
+      <li>First</li>
+      <li>Second
+        <ol><li>Nested</li><li>Again</li></ol>
+      </li>
+    </ul>
+    <div>Line one<br/>Line two</div>
+    <table><tr><th>Name</th><th>Age</th></tr><tr><td>Ann</td><td>30</td></tr></table>
+    <p><img src="a.png" alt="A cat"/> <a href="https://example.com">https://example.com</a></p>
+    <script>alert("hi")</script>
+  </body>
+</html>`
+
+	got, err := PlainText(mustParse(t, document), PlainTextOptions{})
+	want := `Welcome
+
+Read the docs (https://example.com/docs), or go back.
+
+- First
+- Second
+  1. Nested
+  2. Again
+Line one
+Line two
+
+Name	Age
+Ann	30
+
+[A cat] https://example.com
+`
+	if err != nil || got != want {
+		t.Errorf("PlainText. Got\n%v (%v)\nWant\n%v", got, err, want)
+	}
+}
+
+func TestPlainText_Options(t *testing.T) {
+	root := mustParse(t, `<ul><li>See <a href="/about">about</a></li></ul>`)
+
+	got, err := PlainText(root, PlainTextOptions{OmitLinks: true, Bullet: "* "})
+	want := "* See about\n"
+	if err != nil || got != want {
+		t.Errorf("PlainText with options. Got %q (%v) Want %q", got, err, want)
+	}
+}
+
+func TestPlainText_Empty(t *testing.T) {
+	got, err := PlainText(mustParse(t, `<div><script>x</script></div>`), PlainTextOptions{})
+	if err != nil || got != "" {
+		t.Errorf("PlainText of a tree without visible text should be empty. Got %q (%v)", got, err)
+	}
+}
@@ -0,0 +1,390 @@
+package tagparser
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmarshalError reports a value which could not be stored in a struct field
+type UnmarshalError struct {
+	// The XPath style path of the tag (or attribute) being stored, i.e. /config/server[2]/@port
+	Path string
+	// The field being set, i.e. Config.Servers.Port. Unnamed struct types are written as struct {...}
+	Field string
+	Type  reflect.Type
+	// The offsets of the tag being stored - [StartIdx, EndIdx)
+	StartIdx int
+	EndIdx   int
+	// The position of StartIdx within the document passed to Unmarshal. Zero for UnmarshalTag
+	Position Position
+	Err      error
+}
+
+func (e *UnmarshalError) Error() string {
+	location := e.Path
+	if e.Position.Line > 0 {
+		location = fmt.Sprintf("%v:%v: %v", e.Position.Line, e.Position.Column, e.Path)
+	}
+	return fmt.Sprintf("%v: cannot unmarshal into %v (%v) - %v", location, e.Field, e.Type, e.Err)
+}
+
+func (e *UnmarshalError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	tagType             = reflect.TypeOf(Tag{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal: Parse a document and store it's root tag in the struct pointed to by v. See UnmarshalTag
+func Unmarshal(document []rune, v any) error {
+	result, err := Parse(document)
+	if err != nil {
+		return err
+	}
+
+	err = UnmarshalTag(&result.Root, v)
+	var unmarshalErr *UnmarshalError
+	if errors.As(err, &unmarshalErr) {
+		unmarshalErr.Position = result.LineIndex().Position(unmarshalErr.StartIdx)
+	}
+	return err
+}
+
+// UnmarshalTag: Store a tree in the struct pointed to by v, driven by `tag:"..."` struct tags.
+//
+// Each exported field is set from the first child element with the field's name (matched case-insensitively), or
+// from every such child for slices. A tag of the form `tag:"name,option"` renames the field, and the options are:
+//   - attr: Set the field from an attribute instead. Slices are set from the space separated words of the value
+//   - text: Set the field from the tag's own text content (it's text children, joined by a space)
+//   - children: Set a slice from all of the tag's child elements, regardless of their names
+//   - name: Set the field to the tag's name. See MarshalTag for how it names elements when marshalling
+//
+// A field tagged `tag:"-"` is ignored, and the omitempty option (used by Marshal) is accepted. Missing elements and
+// attributes leave their fields unchanged, as do empty elements (and empty text) stored in bools, numbers and
+// durations.
+//
+// Elements are stored in nested structs (and pointers to them, which are allocated as needed), or in fields of type
+// Tag, which receive the element itself. Fields promoted from a nil embedded pointer to an unexported struct type are
+// skipped, as the pointer cannot be allocated. Elements stored in other types are converted from their text content.
+// Strings, bools, integers, floats, time.Duration and types implementing encoding.TextUnmarshaler are converted;
+// values which fail to convert return an *UnmarshalError
+func UnmarshalTag(tag *Tag, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("unmarshal requires a non-nil pointer. got %T", v)
+	}
+
+	return unmarshalElement(tag, "/"+pathStep(tag, 1, 1), rv.Elem(), typeName(rv.Type()))
+}
+
+// tagField describes how a struct field is stored in a tag, from it's `tag:"name,options..."` struct tag
+type tagField struct {
+	index []int
+	name  string
+	// One of "" (an element), attr, text, children or name
+	option    string
+	omitEmpty bool
+}
+
+// tagFields: The fields of a struct type which are stored in tags, including those promoted from embedded structs
+func tagFields(t reflect.Type) ([]tagField, error) {
+	fields := make([]tagField, 0)
+	for _, field := range reflect.VisibleFields(t) {
+		structTag, tagged := field.Tag.Lookup("tag")
+		switch {
+		case !field.IsExported() || structTag == "-":
+			continue
+		case field.Anonymous && !tagged && indirectType(field.Type).Kind() == reflect.Struct:
+			// The embedded struct's own fields are visible too
+			continue
+		}
+
+		options := strings.Split(structTag, ",")
+		tf := tagField{index: field.Index, name: options[0]}
+		if tf.name == "" {
+			tf.name = field.Name
+		}
+
+		for _, option := range options[1:] {
+			switch option {
+			case "omitempty":
+				tf.omitEmpty = true
+			case "attr", "text", "children", "name":
+				if tf.option != "" {
+					return nil, fmt.Errorf("conflicting options %q and %q in the tag of %v.%v", tf.option, option, typeName(t), field.Name)
+				}
+				tf.option = option
+			default:
+				return nil, fmt.Errorf("unknown option %q in the tag of %v.%v", option, typeName(t), field.Name)
+			}
+		}
+		fields = append(fields, tf)
+	}
+	return fields, nil
+}
+
+// unmarshalElement: Store a tag in a value, which must be settable
+func unmarshalElement(tag *Tag, path string, v reflect.Value, fieldPath string) error {
+	t := indirectType(v.Type())
+	switch {
+	case t == tagType:
+		allocate(v).Set(reflect.ValueOf(*tag))
+		return nil
+	case t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return unmarshalStruct(tag, path, allocate(v), fieldPath)
+	}
+
+	if err := setContent(v, ownText(tag)); err != nil {
+		return &UnmarshalError{Path: path, Field: fieldPath, Type: t, StartIdx: tag.StartIdx, EndIdx: tag.EndIdx, Err: err}
+	}
+	return nil
+}
+
+func unmarshalStruct(tag *Tag, path string, v reflect.Value, fieldPath string) error {
+	fields, err := tagFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	steps := pathSteps(tag.Children)
+	for _, field := range fields {
+		fv, ok := fieldByIndex(v, field.index)
+		if !ok {
+			// The field is promoted from a nil pointer to an unexported struct, which cannot be allocated
+			continue
+		}
+		fieldName := fieldPath + "." + v.Type().FieldByIndex(field.index).Name
+		fail := func(path string, err error) error {
+			return &UnmarshalError{Path: path, Field: fieldName, Type: fv.Type(), StartIdx: tag.StartIdx, EndIdx: tag.EndIdx, Err: err}
+		}
+
+		switch field.option {
+		case "attr":
+			value, ok := lookupAttribute(tag, field.name)
+			if !ok {
+				continue
+			}
+			if err := setAttribute(fv, value); err != nil {
+				return fail(path+"/@"+field.name, err)
+			}
+		case "text":
+			if err := setContent(fv, ownText(tag)); err != nil {
+				return fail(path+"/text()", err)
+			}
+		case "name":
+			if err := setText(fv, tag.Name); err != nil {
+				return fail(path, err)
+			}
+		case "children":
+			if fv.Kind() != reflect.Slice {
+				return fail(path, fmt.Errorf("children must be stored in a slice"))
+			}
+
+			fv.Set(reflect.MakeSlice(fv.Type(), 0, len(tag.Children)))
+			for idx := range tag.Children {
+				// Only a []Tag can hold text
+				if tag.Children[idx].Name == TextTagName && fv.Type().Elem() != tagType {
+					continue
+				}
+				if err := appendElement(&tag.Children[idx], path+"/"+steps[idx], fv, fieldName); err != nil {
+					return err
+				}
+			}
+		default:
+			for idx := range tag.Children {
+				child := &tag.Children[idx]
+				if child.Name == TextTagName || !strings.EqualFold(child.Name, field.name) {
+					continue
+				}
+
+				if fv.Kind() != reflect.Slice {
+					if err := unmarshalElement(child, path+"/"+steps[idx], fv, fieldName); err != nil {
+						return err
+					}
+					break
+				}
+
+				if err := appendElement(child, path+"/"+steps[idx], fv, fieldName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// appendElement: Store a tag in a new element of a slice
+func appendElement(tag *Tag, path string, slice reflect.Value, fieldPath string) error {
+	element := reflect.New(slice.Type().Elem()).Elem()
+	if err := unmarshalElement(tag, path, element, fieldPath); err != nil {
+		return err
+	}
+	slice.Set(reflect.Append(slice, element))
+	return nil
+}
+
+// setAttribute: Convert an attribute value, storing each of it's words in an element of slices
+func setAttribute(v reflect.Value, value string) error {
+	v = allocate(v)
+	if v.Kind() != reflect.Slice || reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return setText(v, value)
+	}
+
+	words := strings.Fields(value)
+	v.Set(reflect.MakeSlice(v.Type(), len(words), len(words)))
+	for idx, word := range words {
+		if err := setText(v.Index(idx), word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setContent: Convert the text content of an element to the type of a value. Empty content leaves bools, numbers and
+// durations unchanged (without allocating pointers to them), as there is no value to convert
+func setContent(v reflect.Value, text string) error {
+	t := indirectType(v.Type())
+	if text != "" || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return setText(v, text)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return setText(v, text)
+}
+
+// setText: Convert text to the type of a value
+func setText(v reflect.Value, text string) error {
+	v = allocate(v)
+	if unmarshaler, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	if v.Type() == durationType {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(value)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// allocate: Follow pointers to the value they point to, allocating any which are nil
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// indirectType: The type pointed to, following any number of pointers
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// typeName: The name of a type for error messages, following pointers. Unnamed struct types are written as
+// struct {...} rather than in full
+func typeName(t reflect.Type) string {
+	t = indirectType(t)
+	switch {
+	case t.Name() != "":
+		return t.Name()
+	case t.Kind() == reflect.Struct:
+		return "struct {...}"
+	}
+	return t.String()
+}
+
+// fieldByIndex: The nested field of a struct, allocating any nil embedded struct pointers on the way. Returns false
+// when a nil embedded pointer cannot be set, as it's type is unexported
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for depth, idx := range index {
+		if depth > 0 {
+			if v.Kind() == reflect.Pointer && v.IsNil() && !v.CanSet() {
+				return reflect.Value{}, false
+			}
+			v = allocate(v)
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
+
+// lookupAttribute: Find an attribute by name, preferring an exact match to a case-insensitive one
+func lookupAttribute(tag *Tag, name string) (string, bool) {
+	if value, ok := tag.Attributes[name]; ok {
+		return value, true
+	}
+
+	for key, value := range tag.Attributes {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// ownText: The text children of a tag (but not it's descendants), joined by a space
+func ownText(tag *Tag) string {
+	if tag.Name == TextTagName {
+		return tag.Attributes[TextAttributeName]
+	}
+
+	texts := make([]string, 0)
+	for idx := range tag.Children {
+		if tag.Children[idx].Name == TextTagName {
+			texts = append(texts, tag.Children[idx].Attributes[TextAttributeName])
+		}
+	}
+	return strings.Join(texts, " ")
+}
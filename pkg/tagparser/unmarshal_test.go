@@ -0,0 +1,206 @@
+package tagparser
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testServer struct {
+	Name    string        `tag:"name,attr"`
+	Port    int           `tag:"port,attr"`
+	Tags    []string      `tag:"tags,attr"`
+	Host    string        `tag:"host"`
+	Timeout time.Duration `tag:"timeout"`
+	Note    *string       `tag:"note"`
+}
+
+type testConfig struct {
+	Element  string       `tag:",name"`
+	Version  float64      `tag:"version,attr"`
+	Debug    bool         `tag:"debug,attr"`
+	Title    string       `tag:",text"`
+	Servers  []testServer `tag:"server"`
+	Database *struct {
+		Url string `tag:",text"`
+	} `tag:"database"`
+	Extras  []Tag  `tag:",children"`
+	Ignored string `tag:"-"`
+	Missing int    `tag:"missing"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	document := `
+<config version="1.5" debug="true">
+  Main config
+  <server name="a" port="8080" tags="web  public">
+    <host>a.example.com</host>
+    <timeout>30s</timeout>
+    <note>First</note>
+  </server>
+  <server name="b" port="9090"><host>b.example.com</host></server>
+  <database>postgres://db</database>
+</config>`
+
+	var config testConfig
+	config.Ignored = "unchanged"
+	if err := Unmarshal([]rune(document), &config); err != nil {
+		t.Fatalf("Unmarshal failed - %v", err)
+	}
+
+	note := "First"
+	want := testConfig{
+		Element: "config",
+		Version: 1.5,
+		Debug:   true,
+		Title:   "Main config",
+		Servers: []testServer{
+			{Name: "a", Port: 8080, Tags: []string{"web", "public"}, Host: "a.example.com", Timeout: 30 * time.Second, Note: &note},
+			{Name: "b", Port: 9090, Host: "b.example.com"},
+		},
+		Database: &struct {
+			Url string `tag:",text"`
+		}{Url: "postgres://db"},
+		Ignored: "unchanged",
+	}
+	if diff := cmp.Diff(want, config, cmp.FilterPath(func(p cmp.Path) bool { return p.String() == "Extras" }, cmp.Ignore())); diff != "" {
+		t.Errorf("Unmarshal mismatch (-want +got):\n%s", diff)
+	}
+
+	if len(config.Extras) != 4 || config.Extras[0].Name != TextTagName || config.Extras[3].Name != "database" {
+		t.Errorf("A []Tag with the children option should receive every child. Got %v children", len(config.Extras))
+	}
+}
+
+type testNamed struct {
+	Level level `tag:"level,attr"`
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level " + strconv.Quote(string(text)))
+	}
+	return nil
+}
+
+type testEmbedded struct {
+	testNamed
+	Items []struct {
+		Kind  string `tag:",name"`
+		Value uint8  `tag:",text"`
+	} `tag:",children"`
+}
+
+func TestUnmarshal_EmbeddedAndTextUnmarshaler(t *testing.T) {
+	var got testEmbedded
+	err := Unmarshal([]rune(`<root LEVEL="high"><a>1</a>text<b>2</b></root>`), &got)
+	if err != nil {
+		t.Fatalf("Unmarshal failed - %v", err)
+	}
+
+	if got.Level != 2 || len(got.Items) != 2 || got.Items[0].Kind != "a" || got.Items[1].Value != 2 {
+		t.Errorf("Unmarshal with embedded structs and a TextUnmarshaler. Got %+v", got)
+	}
+}
+
+type testInner struct {
+	X string `tag:"x,attr"`
+}
+
+type testOuter struct {
+	*testInner
+	Y string `tag:"y,attr"`
+}
+
+func TestUnmarshal_NilEmbeddedPointerToUnexportedStruct(t *testing.T) {
+	// The embedded pointer cannot be allocated, so it's fields are skipped (as encoding/json does)
+	var got testOuter
+	err := Unmarshal([]rune(`<outer x="1" y="2" />`), &got)
+	if err != nil {
+		t.Fatalf("Unmarshal failed - %v", err)
+	}
+	if got.testInner != nil || got.Y != "2" {
+		t.Errorf("Unmarshal with a nil embedded pointer to an unexported struct. Got %+v", got)
+	}
+
+	// An allocated embedded pointer is filled in
+	got = testOuter{testInner: &testInner{}}
+	err = Unmarshal([]rune(`<outer x="1" y="2" />`), &got)
+	if err != nil || got.X != "1" || got.Y != "2" {
+		t.Errorf("Unmarshal with an allocated embedded pointer to an unexported struct. Got %+v (%v)", got, err)
+	}
+}
+
+func TestUnmarshal_EmptyContent(t *testing.T) {
+	var got struct {
+		Count   int           `tag:"count"`
+		Enabled *bool         `tag:"enabled"`
+		Ratio   float64       `tag:"ratio"`
+		Timeout time.Duration `tag:"timeout"`
+		Name    string        `tag:"name"`
+		Level   level         `tag:",text"`
+	}
+	got.Count, got.Name = 3, "unset"
+
+	// Empty elements leave bools and numbers unchanged, as missing elements do
+	document := `<root><count /><enabled></enabled><ratio /><timeout /><name /></root>`
+	err := Unmarshal([]rune(document), &got)
+	if err == nil || err.Error() != `1:1: /root/text(): cannot unmarshal into struct {...}.Level (tagparser.level) - unknown level ""` {
+		t.Errorf("A TextUnmarshaler should still receive empty text. Got %v", err)
+	}
+	if got.Count != 3 || got.Enabled != nil || got.Ratio != 0 || got.Timeout != 0 || got.Name != "" {
+		t.Errorf("Unmarshal of empty elements. Got %+v", got)
+	}
+}
+
+func TestUnmarshal_Errors(t *testing.T) {
+	cases := []struct {
+		name     string
+		document string
+		v        any
+		want     string
+	}{
+		{"attribute", "<config>\n  <server port=\"http\" />\n</config>", &testConfig{},
+			`2:3: /config/server/@port: cannot unmarshal into testConfig.Servers.Port (int) - strconv.ParseInt: parsing "http": invalid syntax`},
+		{"leading whitespace", "\n  <config>\n  <server port=\"http\" />\n</config>", &testConfig{},
+			`3:3: /config/server/@port: cannot unmarshal into testConfig.Servers.Port (int) - strconv.ParseInt: parsing "http": invalid syntax`},
+		{"element", `<config><server /><server><timeout>soon</timeout></server></config>`, &testConfig{},
+			`1:27: /config/server[2]/timeout: cannot unmarshal into testConfig.Servers.Timeout (time.Duration) - time: invalid duration "soon"`},
+		{"text unmarshaler", `<root level="mid"/>`, &testNamed{},
+			`1:1: /root/@level: cannot unmarshal into testNamed.Level (tagparser.level) - unknown level "mid"`},
+		{"overflow", `<root><a>300</a></root>`, &testEmbedded{},
+			`1:7: /root/a/text(): cannot unmarshal into testEmbedded.Items.Value (uint8) - strconv.ParseUint: parsing "300": value out of range`},
+	}
+
+	for _, c := range cases {
+		err := Unmarshal([]rune(c.document), c.v)
+		var unmarshalErr *UnmarshalError
+		if !errors.As(err, &unmarshalErr) || err.Error() != c.want {
+			t.Errorf("%v: Unmarshal error. Got %v Want %v", c.name, err, c.want)
+		}
+	}
+
+	if err := Unmarshal([]rune(`<a/>`), testConfig{}); err == nil {
+		t.Errorf("Unmarshal into a non-pointer should fail")
+	}
+	if err := Unmarshal([]rune(`<a`), &testConfig{}); err == nil {
+		t.Errorf("Unmarshal of an invalid document should fail")
+	}
+
+	var invalid struct {
+		Value string `tag:"value,unknown"`
+	}
+	if err := Unmarshal([]rune(`<a/>`), &invalid); err == nil {
+		t.Errorf("Unmarshal with an unknown struct tag option should fail")
+	}
+}
@@ -0,0 +1,447 @@
+package tagparser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type of an attribute value or text content checked by Validate
+type ValueType int
+
+const (
+	// Any text
+	ValueTypeString ValueType = iota
+	// A base 10 integer, i.e. -12
+	ValueTypeInt
+	// A floating point number, i.e. 1.5e3
+	ValueTypeNumber
+	// Either true or false
+	ValueTypeBool
+	// One of the ValueSchema's Values
+	ValueTypeEnum
+	// Text matched by the ValueSchema's Pattern
+	ValueTypeRegex
+)
+
+var valueTypeNames = []string{"string", "int", "number", "bool", "enum", "regex"}
+
+func (t ValueType) String() string {
+	if t < 0 || int(t) >= len(valueTypeNames) {
+		return fmt.Sprintf("ValueType(%d)", int(t))
+	}
+	return valueTypeNames[t]
+}
+
+// ParseValueType: Find the ValueType with the given name (i.e. "int"). Names are case insensitive
+func ParseValueType(name string) (ValueType, error) {
+	for idx, typeName := range valueTypeNames {
+		if strings.EqualFold(name, typeName) {
+			return ValueType(idx), nil
+		}
+	}
+	return ValueTypeString, fmt.Errorf("unknown value type %q. expected one of %v", name, strings.Join(valueTypeNames, ", "))
+}
+
+// ValueSchema describes the values allowed for an attribute or text content
+type ValueSchema struct {
+	Type ValueType
+	// The allowed values of a ValueTypeEnum
+	Values []string
+	// The pattern of a ValueTypeRegex. Anchor it (i.e. ^[a-z]+$) to match the whole value. ParseSchema anchors the
+	// patterns of schema documents
+	Pattern *regexp.Regexp
+}
+
+// check: Check a value against the schema, describing why it is invalid
+func (s ValueSchema) check(value string) error {
+	switch s.Type {
+	case ValueTypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%q is not an int", value)
+		}
+	case ValueTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+	case ValueTypeBool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+	case ValueTypeEnum:
+		if !slices.Contains(s.Values, value) {
+			return fmt.Errorf("%q is not one of %v", value, strings.Join(s.Values, ", "))
+		}
+	case ValueTypeRegex:
+		if s.Pattern == nil {
+			return fmt.Errorf("the regex has no pattern")
+		}
+		if !s.Pattern.MatchString(value) {
+			return fmt.Errorf("%q does not match %v", value, s.Pattern)
+		}
+	}
+	return nil
+}
+
+// AttributeSchema describes an attribute an element may have
+type AttributeSchema struct {
+	Name     string
+	Required bool
+	ValueSchema
+}
+
+// TextSchema describes the text content an element may have
+type TextSchema struct {
+	Required bool
+	ValueSchema
+}
+
+// ChildSchema describes a child element an element may contain, and how many times
+type ChildSchema struct {
+	Name string
+	// The fewest occurrences required
+	Min int
+	// The most occurrences allowed. 0 is unbounded
+	Max int
+}
+
+// ElementSchema describes the attributes, text and children of an element
+type ElementSchema struct {
+	Attributes []AttributeSchema
+	// Allow attributes other than those in Attributes
+	AnyAttributes bool
+	Children      []ChildSchema
+	// Allow child elements other than those in Children. Undeclared children are not validated
+	AnyChildren bool
+	// The text content allowed. When nil, the element may not contain text
+	Text *TextSchema
+}
+
+// Schema describes the shape of a document. Tag names are matched case-sensitively
+type Schema struct {
+	// The name of the root element. When empty, any declared element may be the root
+	Root string
+	// The declared elements, by name
+	Elements map[string]ElementSchema
+}
+
+// ValidationError is a single way in which a document does not match a Schema
+type ValidationError struct {
+	// The XPath style path of the offending tag, i.e. /config/server[2]
+	Path    string
+	Message string
+	// The offsets of the offending tag - [StartIdx, EndIdx)
+	StartIdx int
+	EndIdx   int
+	// The position of StartIdx within the input passed to Parse
+	Position Position
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%v:%v: %v: %v", e.Position.Line, e.Position.Column, e.Path, e.Message)
+}
+
+// Validate: Check that a document matches a schema, returning every difference. The errors are grouped by the element
+// which reports them (a tag's children are checked by it's parent), in document order.
+//
+// Each element must be declared by the schema, and is checked for:
+//   - Missing required attributes, attributes which aren't declared and attribute values of the wrong type
+//   - Text content where none is allowed, missing required text and text of the wrong type. The element's own text
+//     children are joined by a space and checked as a single value
+//   - Child elements which aren't allowed, or which occur fewer than Min or more than Max times
+//
+// A nil schema is reported as a single error, against the root element
+func Validate(result ParseResult, schema *Schema) []ValidationError {
+	lineIndex := result.LineIndex()
+	validationErrors := make([]ValidationError, 0)
+	report := func(tag *Tag, path string, format string, args ...any) {
+		validationErrors = append(validationErrors, ValidationError{
+			Path:     path,
+			Message:  fmt.Sprintf(format, args...),
+			StartIdx: tag.StartIdx,
+			EndIdx:   tag.EndIdx,
+			Position: lineIndex.Position(tag.StartIdx),
+		})
+	}
+
+	type frame struct {
+		tag  *Tag
+		path string
+	}
+
+	root := &result.Root
+	rootPath := "/" + pathStep(root, 1, 1)
+	if schema == nil {
+		report(root, rootPath, "there is no schema to validate against")
+		return validationErrors
+	}
+	if schema.Root != "" && root.Name != schema.Root {
+		report(root, rootPath, "the root element is <%v>, expected <%v>", root.Name, schema.Root)
+		return validationErrors
+	}
+	if _, ok := schema.Elements[root.Name]; !ok {
+		report(root, rootPath, "<%v> is not declared by the schema", root.Name)
+		return validationErrors
+	}
+
+	stack := []frame{{root, rootPath}}
+	for len(stack) > 0 {
+		tag, path := stack[len(stack)-1].tag, stack[len(stack)-1].path
+		stack = stack[:len(stack)-1]
+		element := schema.Elements[tag.Name]
+
+		for _, attribute := range element.Attributes {
+			value, ok := tag.Attributes[attribute.Name]
+			switch {
+			case !ok && attribute.Required:
+				report(tag, path, "<%v> is missing the required %v attribute", tag.Name, attribute.Name)
+			case ok:
+				if err := attribute.check(value); err != nil {
+					report(tag, path+"/@"+attribute.Name, "invalid %v attribute - %v", attribute.Name, err)
+				}
+			}
+		}
+
+		if !element.AnyAttributes {
+			for _, key := range sortedAttributeKeys(tag) {
+				declared := slices.ContainsFunc(element.Attributes, func(a AttributeSchema) bool { return a.Name == key })
+				if !declared {
+					report(tag, path+"/@"+key, "the %v attribute is not allowed on <%v>", key, tag.Name)
+				}
+			}
+		}
+
+		hasText := slices.ContainsFunc(tag.Children, func(child Tag) bool { return child.Name == TextTagName })
+		switch {
+		case hasText && element.Text == nil:
+			report(tag, path, "<%v> may not contain text", tag.Name)
+		case !hasText && element.Text != nil && element.Text.Required:
+			report(tag, path, "<%v> is missing it's required text", tag.Name)
+		case hasText:
+			if err := element.Text.check(ownText(tag)); err != nil {
+				report(tag, path+"/text()", "invalid text - %v", err)
+			}
+		}
+
+		steps := pathSteps(tag.Children)
+		counts := map[string]int{}
+		children := make([]frame, 0, len(tag.Children))
+		for idx := range tag.Children {
+			child := &tag.Children[idx]
+			if child.Name == TextTagName {
+				continue
+			}
+
+			childPath := path + "/" + steps[idx]
+			counts[child.Name] += 1
+			allowed := slices.IndexFunc(element.Children, func(c ChildSchema) bool { return c.Name == child.Name })
+			switch {
+			case allowed == -1 && !element.AnyChildren:
+				report(child, childPath, "<%v> is not allowed in <%v>", child.Name, tag.Name)
+				continue
+			case allowed != -1 && element.Children[allowed].Max > 0 && counts[child.Name] > element.Children[allowed].Max:
+				report(child, childPath, "<%v> may contain at most %v <%v>", tag.Name, element.Children[allowed].Max, child.Name)
+			}
+
+			if _, declared := schema.Elements[child.Name]; declared {
+				children = append(children, frame{child, childPath})
+			}
+		}
+
+		for _, allowed := range element.Children {
+			if counts[allowed.Name] < allowed.Min {
+				report(tag, path, "<%v> must contain at least %v <%v>, found %v", tag.Name, allowed.Min, allowed.Name, counts[allowed.Name])
+			}
+		}
+
+		// Children are pushed in reverse, so they're visited in document order
+		for idx := len(children) - 1; idx >= 0; idx-- {
+			stack = append(stack, children[idx])
+		}
+	}
+
+	return validationErrors
+}
+
+// The structure of a schema document, checked before it is read
+var schemaSchema = Schema{
+	Root: "schema",
+	Elements: map[string]ElementSchema{
+		"schema": {
+			Attributes: []AttributeSchema{{Name: "root"}},
+			Children:   []ChildSchema{{Name: "element", Min: 1}},
+		},
+		"element": {
+			Attributes: []AttributeSchema{
+				{Name: "name", Required: true},
+				{Name: "anyAttributes", ValueSchema: ValueSchema{Type: ValueTypeBool}},
+				{Name: "anyChildren", ValueSchema: ValueSchema{Type: ValueTypeBool}},
+			},
+			Children: []ChildSchema{{Name: "attribute"}, {Name: "child"}, {Name: "text", Max: 1}},
+		},
+		"attribute": {Attributes: append([]AttributeSchema{{Name: "name", Required: true}}, schemaValueAttributes...)},
+		"text":      {Attributes: schemaValueAttributes},
+		"child": {
+			Attributes: []AttributeSchema{
+				{Name: "name", Required: true},
+				{Name: "min", ValueSchema: ValueSchema{Type: ValueTypeInt}},
+				{Name: "max", ValueSchema: ValueSchema{Type: ValueTypeRegex, Pattern: regexp.MustCompile(`^(?:[0-9]+|unbounded)$`)}},
+			},
+		},
+	},
+}
+
+// The attributes describing a ValueSchema in a schema document
+var schemaValueAttributes = []AttributeSchema{
+	{Name: "type", ValueSchema: ValueSchema{Type: ValueTypeEnum, Values: valueTypeNames}},
+	{Name: "required", ValueSchema: ValueSchema{Type: ValueTypeBool}},
+	{Name: "values"},
+	{Name: "pattern"},
+}
+
+type schemaDocument struct {
+	Root     string                  `tag:"root,attr"`
+	Elements []schemaElementDocument `tag:"element"`
+}
+
+type schemaElementDocument struct {
+	Name          string                `tag:"name,attr"`
+	AnyAttributes bool                  `tag:"anyAttributes,attr"`
+	AnyChildren   bool                  `tag:"anyChildren,attr"`
+	Attributes    []schemaValueDocument `tag:"attribute"`
+	Children      []schemaChildDocument `tag:"child"`
+	Text          *schemaValueDocument  `tag:"text"`
+}
+
+type schemaValueDocument struct {
+	Name     string   `tag:"name,attr"`
+	Type     string   `tag:"type,attr"`
+	Required bool     `tag:"required,attr"`
+	Values   []string `tag:"values,attr"`
+	Pattern  string   `tag:"pattern,attr"`
+}
+
+type schemaChildDocument struct {
+	Name string `tag:"name,attr"`
+	Min  int    `tag:"min,attr"`
+	Max  string `tag:"max,attr"`
+}
+
+// ParseSchema: Read a Schema from a schema document, i.e.
+//
+//	<schema root="config">
+//	    <element name="config">
+//	        <attribute name="version" type="int" required="true" />
+//	        <child name="server" min="1" max="unbounded" />
+//	    </element>
+//	    <element name="server">
+//	        <attribute name="mode" type="enum" values="dev prod" />
+//	        <text type="regex" pattern="[a-z.]+" required="true" />
+//	    </element>
+//	</schema>
+//
+// Each element declaration lists the element's attributes, children and text. A child's min defaults to 0, and it's max
+// to unbounded. Elements without a text declaration may not contain text. anyAttributes="true" and anyChildren="true"
+// allow undeclared attributes and children.
+// An attribute's type (and the type of text) is one of string (the default), int, number, bool, enum (with a space
+// separated list of values) or regex (with a pattern, which must match the whole value).
+// Mistakes in the schema document are returned as errors, with their positions
+func ParseSchema(document []rune) (*Schema, error) {
+	result, err := Parse(document)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema - %w", err)
+	}
+
+	if validationErrors := Validate(result, &schemaSchema); len(validationErrors) > 0 {
+		errs := make([]error, 0, len(validationErrors))
+		for _, validationErr := range validationErrors {
+			errs = append(errs, validationErr)
+		}
+		return nil, fmt.Errorf("invalid schema - %w", errors.Join(errs...))
+	}
+
+	var parsed schemaDocument
+	if err := Unmarshal(document, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid schema - %w", err)
+	}
+
+	schema := &Schema{Root: parsed.Root, Elements: make(map[string]ElementSchema, len(parsed.Elements))}
+	for _, declaration := range parsed.Elements {
+		if _, ok := schema.Elements[declaration.Name]; ok {
+			return nil, fmt.Errorf("invalid schema - <%v> is declared more than once", declaration.Name)
+		}
+
+		element := ElementSchema{AnyAttributes: declaration.AnyAttributes, AnyChildren: declaration.AnyChildren}
+		for _, attribute := range declaration.Attributes {
+			value, err := compileValueSchema(attribute)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema - the %v attribute of <%v>: %w", attribute.Name, declaration.Name, err)
+			}
+			element.Attributes = append(element.Attributes, AttributeSchema{Name: attribute.Name, Required: attribute.Required, ValueSchema: value})
+		}
+
+		if declaration.Text != nil {
+			value, err := compileValueSchema(*declaration.Text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema - the text of <%v>: %w", declaration.Name, err)
+			}
+			element.Text = &TextSchema{Required: declaration.Text.Required, ValueSchema: value}
+		}
+
+		for _, child := range declaration.Children {
+			maxCount := 0
+			if child.Max != "" && child.Max != "unbounded" {
+				maxCount, _ = strconv.Atoi(child.Max)
+				if maxCount < child.Min || maxCount == 0 {
+					return nil, fmt.Errorf("invalid schema - the max of <%v> in <%v> must be at least 1 and it's min", child.Name, declaration.Name)
+				}
+			}
+			element.Children = append(element.Children, ChildSchema{Name: child.Name, Min: child.Min, Max: maxCount})
+		}
+
+		schema.Elements[declaration.Name] = element
+	}
+
+	if _, ok := schema.Elements[schema.Root]; schema.Root != "" && !ok {
+		return nil, fmt.Errorf("invalid schema - the root element <%v> is not declared", schema.Root)
+	}
+	for name, element := range schema.Elements {
+		for _, child := range element.Children {
+			if _, ok := schema.Elements[child.Name]; !ok {
+				return nil, fmt.Errorf("invalid schema - <%v> contains <%v>, which is not declared", name, child.Name)
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// compileValueSchema: Check and compile the type of an attribute or text declaration
+func compileValueSchema(declaration schemaValueDocument) (ValueSchema, error) {
+	value := ValueSchema{}
+	if declaration.Type != "" {
+		valueType, err := ParseValueType(declaration.Type)
+		if err != nil {
+			return value, err
+		}
+		value.Type = valueType
+	}
+
+	switch value.Type {
+	case ValueTypeEnum:
+		if len(declaration.Values) == 0 {
+			return value, fmt.Errorf("an enum must list it's values")
+		}
+		value.Values = declaration.Values
+	case ValueTypeRegex:
+		pattern, err := regexp.Compile("^(?:" + declaration.Pattern + ")$")
+		if err != nil || declaration.Pattern == "" {
+			return value, fmt.Errorf("a regex must have a valid pattern")
+		}
+		value.Pattern = pattern
+	}
+	return value, nil
+}
@@ -0,0 +1,133 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `
+<schema root="config">
+    <element name="config">
+        <attribute name="version" type="int" required="true" />
+        <child name="server" min="1" max="2" />
+        <child name="extra" />
+    </element>
+    <element name="server">
+        <attribute name="mode" type="enum" values="dev prod" />
+        <attribute name="weight" type="number" />
+        <child name="host" min="1" max="1" />
+    </element>
+    <element name="host">
+        <attribute name="secure" type="bool" />
+        <text type="regex" pattern="[a-z.]+" required="true" />
+    </element>
+    <element name="extra" anyAttributes="true" anyChildren="true">
+        <text />
+    </element>
+</schema>`
+
+func TestValidate(t *testing.T) {
+	schema, err := ParseSchema([]rune(testSchema))
+	if err != nil {
+		t.Fatalf("ParseSchema failed - %v", err)
+	}
+
+	valid := `<config version="2">
+    <server mode="prod" weight="0.5"><host secure="true">a.example.com</host></server>
+    <extra any="thing">Anything <goes><here /></goes></extra>
+</config>`
+	result, _ := Parse([]rune(valid))
+	if errs := Validate(result, schema); len(errs) != 0 {
+		t.Errorf("Validate of a valid document should return no errors. Got %v", errs)
+	}
+
+	invalid := `<config version="two" debug="true">
+    Text
+    <server mode="test"><host>a.example.com</host><host /></server>
+    <server><host secure="yes">B.EXAMPLE.COM</host></server>
+    <server weight="x" />
+    <unknown />
+</config>`
+	result, _ = Parse([]rune(invalid))
+	got := make([]string, 0)
+	for _, err := range Validate(result, schema) {
+		got = append(got, err.Error())
+	}
+
+	want := []string{
+		`1:1: /config/@version: invalid version attribute - "two" is not an int`,
+		`1:1: /config/@debug: the debug attribute is not allowed on <config>`,
+		`1:1: /config: <config> may not contain text`,
+		`5:5: /config/server[3]: <config> may contain at most 2 <server>`,
+		`6:5: /config/unknown: <unknown> is not allowed in <config>`,
+		`3:5: /config/server[1]/@mode: invalid mode attribute - "test" is not one of dev, prod`,
+		`3:51: /config/server[1]/host[2]: <server> may contain at most 1 <host>`,
+		`3:51: /config/server[1]/host[2]: <host> is missing it's required text`,
+		`4:13: /config/server[2]/host/@secure: invalid secure attribute - "yes" is not true or false`,
+		`4:13: /config/server[2]/host/text(): invalid text - "B.EXAMPLE.COM" does not match ^(?:[a-z.]+)$`,
+		`5:5: /config/server[3]/@weight: invalid weight attribute - "x" is not a number`,
+		`5:5: /config/server[3]: <server> must contain at least 1 <host>, found 0`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Validate errors. Got\n%v\nWant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidate_PositionsCountLeadingWhitespace(t *testing.T) {
+	schema := &Schema{Elements: map[string]ElementSchema{"a": {}}}
+
+	result, _ := Parse([]rune("\n\n  <a>\n  <b /></a>"))
+	errs := Validate(result, schema)
+	if len(errs) != 1 || errs[0].Error() != "4:3: /a/b: <b> is not allowed in <a>" {
+		t.Errorf("Validate positions. Got %v", errs)
+	}
+}
+
+func TestValidate_Root(t *testing.T) {
+	schema := &Schema{Elements: map[string]ElementSchema{"a": {}}}
+
+	result, _ := Parse([]rune(`<b />`))
+	if errs := Validate(result, schema); len(errs) != 1 || errs[0].Message != "<b> is not declared by the schema" {
+		t.Errorf("Validate of an undeclared root. Got %v", errs)
+	}
+
+	schema.Root = "a"
+	if errs := Validate(result, schema); len(errs) != 1 || errs[0].Message != "the root element is <b>, expected <a>" {
+		t.Errorf("Validate of the wrong root. Got %v", errs)
+	}
+
+	if errs := Validate(result, nil); len(errs) != 1 || errs[0].Error() != "1:1: /b: there is no schema to validate against" {
+		t.Errorf("Validate without a schema. Got %v", errs)
+	}
+}
+
+func TestParseSchema_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"unknown attribute", "\n<schema><element name=\"a\" nmae=\"b\" /></schema>",
+			"invalid schema - 2:9: /schema/element/@nmae: the nmae attribute is not allowed on <element>"},
+		{"unknown type", `<schema><element name="a"><attribute name="b" type="date" /></element></schema>`,
+			`invalid schema - 1:27: /schema/element/attribute/@type: invalid type attribute - "date" is not one of string, int, number, bool, enum, regex`},
+		{"missing values", `<schema><element name="a"><attribute name="b" type="enum" /></element></schema>`,
+			"invalid schema - the b attribute of <a>: an enum must list it's values"},
+		{"invalid pattern", `<schema><element name="a"><text type="regex" pattern="(" /></element></schema>`,
+			"invalid schema - the text of <a>: a regex must have a valid pattern"},
+		{"undeclared child", `<schema><element name="a"><child name="b" /></element></schema>`,
+			"invalid schema - <a> contains <b>, which is not declared"},
+		{"undeclared root", `<schema root="b"><element name="a" /></schema>`,
+			"invalid schema - the root element <b> is not declared"},
+		{"duplicate", `<schema><element name="a" /><element name="a" /></schema>`,
+			"invalid schema - <a> is declared more than once"},
+		{"max below min", `<schema><element name="a"><child name="a" min="2" max="1" /></element></schema>`,
+			"invalid schema - the max of <a> in <a> must be at least 1 and it's min"},
+	}
+
+	for _, c := range cases {
+		if _, err := ParseSchema([]rune(c.schema)); err == nil || err.Error() != c.want {
+			t.Errorf("%v: ParseSchema error. Got %v Want %v", c.name, err, c.want)
+		}
+	}
+}
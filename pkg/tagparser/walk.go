@@ -0,0 +1,115 @@
+package tagparser
+
+import "errors"
+
+// SkipChildren can be returned from a WalkFunc to prevent the walk from descending into the
+// children of the current tag. It is never returned by the walk functions themselves.
+var SkipChildren = errors.New("skip children")
+
+// StopWalk can be returned from a WalkFunc to end the walk early. The walk function will return nil.
+var StopWalk = errors.New("stop walk")
+
+// WalkFunc is called for each tag visited by Walk, WalkPostOrder and WalkEnterExit.
+// The tag pointer refers to the tag inside the tree, so it may be used to modify the tag in place.
+// Returning SkipChildren or StopWalk alters the walk, any other error will abort the walk and be returned.
+type WalkFunc func(tag *Tag) error
+
+// Walk: Visit tag and all of it's descendants in pre-order (parents before children), calling fn for each.
+func Walk(tag *Tag, fn WalkFunc) error {
+	return WalkEnterExit(tag, fn, nil)
+}
+
+// WalkPostOrder: Visit tag and all of it's descendants in post-order (children before parents), calling fn for each.
+// Returning SkipChildren from fn has no effect, as the children have already been visited.
+func WalkPostOrder(tag *Tag, fn WalkFunc) error {
+	return WalkEnterExit(tag, nil, fn)
+}
+
+// WalkEnterExit: Visit tag and all of it's descendants, calling enter before a tag's children are visited
+// and exit after them. Either function may be nil.
+// When enter returns SkipChildren, the children are skipped but exit is still called for the tag.
+//
+// The walk uses an explicit stack, so very deep documents will not grow the goroutine stack.
+func WalkEnterExit(tag *Tag, enter WalkFunc, exit WalkFunc) error {
+	err := walk(tag,
+		func(t *Tag, _ []*Tag) error {
+			if enter == nil {
+				return nil
+			}
+			return enter(t)
+		},
+		func(t *Tag, _ []*Tag) error {
+			if exit == nil {
+				return nil
+			}
+			return exit(t)
+		})
+
+	if err == StopWalk {
+		return nil
+	}
+
+	return err
+}
+
+// walkFunc is the internal variant of WalkFunc, which is also given the ancestors of the tag (root first).
+// The ancestors slice is reused between calls and must not be retained.
+type walkFunc func(tag *Tag, ancestors []*Tag) error
+
+type walkFrame struct {
+	tag *Tag
+	// The index of the next child to visit
+	next int
+}
+
+// walk: Explicit stack implementation shared by the public walk functions.
+// StopWalk is returned as-is so that callers can distinguish an early exit.
+func walk(tag *Tag, enter walkFunc, exit walkFunc) error {
+	if tag == nil {
+		return nil
+	}
+
+	stack := []walkFrame{{tag: tag}}
+	ancestors := make([]*Tag, 0)
+
+	err := enter(tag, ancestors)
+	if err == SkipChildren {
+		stack[0].next = len(tag.Children)
+	} else if err != nil {
+		return err
+	}
+
+	for len(stack) > 0 {
+		frame := &stack[len(stack)-1]
+
+		if frame.next >= len(frame.tag.Children) {
+			// All children visited - Leave the tag
+			stack = stack[:len(stack)-1]
+
+			err := exit(frame.tag, ancestors)
+			if err != nil && err != SkipChildren {
+				return err
+			}
+
+			if len(ancestors) > 0 {
+				ancestors = ancestors[:len(ancestors)-1]
+			}
+			continue
+		}
+
+		child := &frame.tag.Children[frame.next]
+		frame.next += 1
+
+		ancestors = append(ancestors, frame.tag)
+		stack = append(stack, walkFrame{tag: child})
+
+		err := enter(child, ancestors)
+		if err == SkipChildren {
+			stack[len(stack)-1].next = len(child.Children)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
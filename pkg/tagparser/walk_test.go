@@ -0,0 +1,187 @@
+package tagparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func walkTestTree() *Tag {
+	return &Tag{
+		Name: "a",
+		Children: []Tag{
+			{
+				Name: "b",
+				Children: []Tag{
+					{Name: "c"},
+					{Name: "d"},
+				},
+			},
+			{
+				Name: "e",
+				Children: []Tag{
+					{Name: "f"},
+				},
+			},
+		},
+	}
+}
+
+func TestWalk_WorksWithNilTag(t *testing.T) {
+	var tag *Tag
+	err := Walk(tag, func(t *Tag) error { return errors.New("should not be called") })
+	if err != nil {
+		t.Errorf("Walk doesn't work with nil tag. Got %v", err)
+	}
+}
+
+func TestWalk_VisitsInPreOrder(t *testing.T) {
+	got := []string{}
+	err := Walk(walkTestTree(), func(t *Tag) error {
+		got = append(got, t.Name)
+		return nil
+	})
+
+	want := []string{"a", "b", "c", "d", "e", "f"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("Walk visited tags in the wrong order. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestWalkPostOrder_VisitsInPostOrder(t *testing.T) {
+	got := []string{}
+	err := WalkPostOrder(walkTestTree(), func(t *Tag) error {
+		got = append(got, t.Name)
+		return nil
+	})
+
+	want := []string{"c", "d", "b", "f", "e", "a"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("WalkPostOrder visited tags in the wrong order. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestWalkEnterExit_PairsEvents(t *testing.T) {
+	got := []string{}
+	err := WalkEnterExit(walkTestTree(),
+		func(t *Tag) error {
+			got = append(got, "+"+t.Name)
+			if t.Name == "b" {
+				return SkipChildren
+			}
+			return nil
+		},
+		func(t *Tag) error {
+			got = append(got, "-"+t.Name)
+			return nil
+		})
+
+	want := []string{"+a", "+b", "-b", "+e", "+f", "-f", "-e", "-a"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("WalkEnterExit produced incorrect events. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestWalk_SkipChildren(t *testing.T) {
+	got := []string{}
+	err := Walk(walkTestTree(), func(t *Tag) error {
+		got = append(got, t.Name)
+		if t.Name == "b" {
+			return SkipChildren
+		}
+		return nil
+	})
+
+	want := []string{"a", "b", "e", "f"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("Walk did not skip children. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestWalk_StopWalk(t *testing.T) {
+	got := []string{}
+	err := Walk(walkTestTree(), func(t *Tag) error {
+		got = append(got, t.Name)
+		if t.Name == "d" {
+			return StopWalk
+		}
+		return nil
+	})
+
+	want := []string{"a", "b", "c", "d"}
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("Walk did not stop early. Got %v (%v) Want %v", got, err, want)
+	}
+}
+
+func TestWalk_ReturnsCallbackErrors(t *testing.T) {
+	want := errors.New("🔥")
+	visited := 0
+	got := Walk(walkTestTree(), func(t *Tag) error {
+		visited += 1
+		if t.Name == "c" {
+			return want
+		}
+		return nil
+	})
+
+	if got != want || visited != 3 {
+		t.Errorf("Walk did not return the callback error. Got %v after %v tags", got, visited)
+	}
+}
+
+func TestWalk_CanModifyTagsInPlace(t *testing.T) {
+	tree := walkTestTree()
+	Walk(tree, func(t *Tag) error {
+		t.Name = t.Name + "!"
+		return nil
+	})
+
+	if tree.Children[1].Children[0].Name != "f!" {
+		t.Errorf("Walk did not provide pointers into the tree. Got %v", tree.Children[1].Children[0].Name)
+	}
+}
+
+func TestWalk_AncestorsAreTracked(t *testing.T) {
+	entered := map[string][]string{}
+	exited := map[string][]string{}
+	record := func(into map[string][]string) walkFunc {
+		return func(t *Tag, ancestors []*Tag) error {
+			names := []string{}
+			for _, a := range ancestors {
+				names = append(names, a.Name)
+			}
+			into[t.Name] = names
+			return nil
+		}
+	}
+	walk(walkTestTree(), record(entered), record(exited))
+
+	want := map[string][]string{
+		"a": {}, "b": {"a"}, "c": {"a", "b"}, "d": {"a", "b"}, "e": {"a"}, "f": {"a", "e"},
+	}
+	if !cmp.Equal(entered, want) || !cmp.Equal(exited, want) {
+		t.Errorf("walk tracked ancestors incorrectly. Got %v and %v Want %v", entered, exited, want)
+	}
+}
+
+func TestWalk_HandlesVeryDeepDocuments(t *testing.T) {
+	depth := 100000
+	root := &Tag{Name: "deep"}
+	current := root
+	for i := 0; i < depth; i++ {
+		current.Children = []Tag{{Name: "deep", Depth: i + 1}}
+		current = &current.Children[0]
+	}
+
+	visited := 0
+	err := Walk(root, func(t *Tag) error {
+		visited += 1
+		return nil
+	})
+
+	if err != nil || visited != depth+1 {
+		t.Errorf("Walk failed on a deep document. Visited %v (%v)", visited, err)
+	}
+}
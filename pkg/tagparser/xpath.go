@@ -0,0 +1,1449 @@
+package tagparser
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// XPath is a compiled XPath 1.0 expression which can be evaluated against many Tag trees.
+//
+// Supported syntax:
+// - Absolute and relative location paths, including the // . .. and @ abbreviations
+// - The child, descendant, descendant-or-self, self, parent, ancestor, ancestor-or-self, following-sibling,
+// // preceding-sibling, following, preceding and attribute axes
+// - Name tests, *, text() and node() node tests
+// - Predicates, including positional predicates such as [1] and [last()]
+// - The or, and, =, !=, <, <=, >, >=, +, -, *, div, mod and | operators, with XPath 1.0 comparison rules
+// - The core function library (except id(), lang() and the namespace functions)
+//
+// Note that:
+// - The tree is treated as a document whose only child is the root Tag. Relative expressions are evaluated from the document node
+// - <text> pseudo tags are treated as text nodes, and are matched by text() rather than by name tests
+// - Variables ($name) are not supported
+type XPath struct {
+	source string
+	expr   xpathExpr
+}
+
+// XPathNode is a node selected by an XPath expression
+type XPathNode struct {
+	// The element or text tag. For attribute nodes, the element which owns the attribute.
+	// Nil for the document node
+	Tag *Tag
+	// The attribute name for attribute nodes, otherwise the empty string
+	Attribute string
+}
+
+// IsAttribute: Check if the node is an attribute node
+func (n XPathNode) IsAttribute() bool {
+	return n.Tag != nil && n.Attribute != ""
+}
+
+// IsText: Check if the node is a text node (a <text> pseudo tag)
+func (n XPathNode) IsText() bool {
+	return n.Tag != nil && n.Attribute == "" && n.Tag.Name == TextTagName
+}
+
+// Value: Get the XPath string-value of the node. For elements, this is the concatenation of all descendant text.
+func (n XPathNode) Value() string {
+	switch {
+	case n.Tag == nil:
+		return ""
+	case n.IsAttribute():
+		return n.Tag.Attributes[n.Attribute]
+	case n.IsText():
+		return n.Tag.Attributes[TextAttributeName]
+	}
+
+	var sb strings.Builder
+	Walk(n.Tag, func(t *Tag) error {
+		if t.Name == TextTagName {
+			sb.WriteString(t.Attributes[TextAttributeName])
+			return SkipChildren
+		}
+		return nil
+	})
+	return sb.String()
+}
+
+// CompileXPath: Compile an XPath expression so that it can be evaluated against Tag trees.
+// Syntax errors are reported as a *ParseError with indices into the expression.
+func CompileXPath(expr string) (*XPath, error) {
+	tokens, err := tokenizeXPath([]rune(expr))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &xpathParser{tokens: tokens}
+	compiled, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, p.errorf("Unexpected token in expression - %v", p.peek().text)
+	}
+
+	return &XPath{source: expr, expr: compiled}, nil
+}
+
+// MustCompileXPath: Like CompileXPath, but panics if the expression cannot be compiled.
+func MustCompileXPath(expr string) *XPath {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		panic(fmt.Sprintf("tagparser: cannot compile xpath %q: %v", expr, err))
+	}
+	return x
+}
+
+// XPathEvaluate: Compile and evaluate an XPath expression. See XPath.Evaluate
+func XPathEvaluate(root *Tag, expr string) (any, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Evaluate(root)
+}
+
+// XPathSelect: Compile and evaluate an XPath expression which must result in a node-set. See XPath.Select
+func XPathSelect(root *Tag, expr string) ([]XPathNode, error) {
+	x, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return x.Select(root)
+}
+
+func (x *XPath) String() string {
+	return x.source
+}
+
+// Evaluate: Evaluate the expression against the tree. The result is one of:
+// []XPathNode (a node-set in document order), string, float64 or bool
+func (x *XPath) Evaluate(root *Tag) (any, error) {
+	if root == nil {
+		return nil, fmt.Errorf("cannot evaluate xpath against a nil tag")
+	}
+
+	doc := newXPathDocument(root)
+	ctx := &xpathContext{doc: doc, node: XPathNode{}, position: 1, size: 1}
+	return x.expr.eval(ctx)
+}
+
+// Select: Evaluate the expression against the tree, returning the selected nodes in document order.
+// It is an error for the expression to result in anything other than a node-set.
+func (x *XPath) Select(root *Tag) ([]XPathNode, error) {
+	value, err := x.Evaluate(root)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.([]XPathNode)
+	if !ok {
+		return nil, fmt.Errorf("xpath %v does not result in a node-set", x.source)
+	}
+	return nodes, nil
+}
+
+//region Document Model
+
+type xpathDocument struct {
+	root    *Tag
+	parents map[*Tag]*Tag
+	order   map[*Tag]int
+	// All tags in document order
+	tags []*Tag
+}
+
+func newXPathDocument(root *Tag) *xpathDocument {
+	doc := &xpathDocument{root: root, parents: map[*Tag]*Tag{}, order: map[*Tag]int{}}
+	walk(root,
+		func(t *Tag, ancestors []*Tag) error {
+			if len(ancestors) > 0 {
+				doc.parents[t] = ancestors[len(ancestors)-1]
+			}
+			doc.order[t] = len(doc.tags)
+			doc.tags = append(doc.tags, t)
+			return nil
+		},
+		func(t *Tag, ancestors []*Tag) error { return nil })
+
+	return doc
+}
+
+// before: Compare two nodes by document order, returning true if a comes before b
+func (d *xpathDocument) before(a, b XPathNode) bool {
+	if a.Tag == nil || b.Tag == nil {
+		return a.Tag == nil && b.Tag != nil
+	}
+
+	if a.Tag != b.Tag {
+		return d.order[a.Tag] < d.order[b.Tag]
+	}
+
+	// Same tag - The element comes before it's attributes, which are in name order
+	return a.Attribute < b.Attribute
+}
+
+func (d *xpathDocument) sortNodes(nodes []XPathNode) []XPathNode {
+	sort.SliceStable(nodes, func(i, j int) bool { return d.before(nodes[i], nodes[j]) })
+
+	// Remove duplicates, which are now adjacent
+	unique := nodes[:0]
+	for i, node := range nodes {
+		if i == 0 || node != nodes[i-1] {
+			unique = append(unique, node)
+		}
+	}
+	return unique
+}
+
+func (d *xpathDocument) parent(node XPathNode) (XPathNode, bool) {
+	switch {
+	case node.Tag == nil:
+		return XPathNode{}, false
+	case node.IsAttribute():
+		return XPathNode{Tag: node.Tag}, true
+	}
+
+	parent, ok := d.parents[node.Tag]
+	if !ok {
+		// The root tag's parent is the document node
+		return XPathNode{}, true
+	}
+	return XPathNode{Tag: parent}, true
+}
+
+func (d *xpathDocument) children(node XPathNode) []XPathNode {
+	if node.Tag == nil {
+		return []XPathNode{{Tag: d.root}}
+	}
+
+	if node.IsAttribute() {
+		return nil
+	}
+
+	children := make([]XPathNode, len(node.Tag.Children))
+	for i := range node.Tag.Children {
+		children[i] = XPathNode{Tag: &node.Tag.Children[i]}
+	}
+	return children
+}
+
+func (d *xpathDocument) descendants(node XPathNode) []XPathNode {
+	descendants := make([]XPathNode, 0)
+	start := d.root
+	if node.Tag != nil {
+		if node.IsAttribute() {
+			return descendants
+		}
+		start = node.Tag
+	} else {
+		descendants = append(descendants, XPathNode{Tag: d.root})
+	}
+
+	for i := range start.Children {
+		Walk(&start.Children[i], func(t *Tag) error {
+			descendants = append(descendants, XPathNode{Tag: t})
+			return nil
+		})
+	}
+	return descendants
+}
+
+// axis: Find the nodes on the given axis, in axis order (reverse document order for reverse axes)
+func (d *xpathDocument) axis(name string, node XPathNode) []XPathNode {
+	switch name {
+	case "child":
+		return d.children(node)
+	case "descendant":
+		return d.descendants(node)
+	case "descendant-or-self":
+		return append([]XPathNode{node}, d.descendants(node)...)
+	case "self":
+		return []XPathNode{node}
+	case "parent":
+		if parent, ok := d.parent(node); ok {
+			return []XPathNode{parent}
+		}
+		return nil
+	case "ancestor", "ancestor-or-self":
+		nodes := make([]XPathNode, 0)
+		if name == "ancestor-or-self" {
+			nodes = append(nodes, node)
+		}
+		for current, ok := d.parent(node); ok; current, ok = d.parent(current) {
+			nodes = append(nodes, current)
+		}
+		return nodes
+	case "following-sibling", "preceding-sibling":
+		if node.Tag == nil || node.IsAttribute() {
+			return nil
+		}
+
+		parent, ok := d.parent(node)
+		if !ok {
+			return nil
+		}
+
+		siblings := d.children(parent)
+		position := 0
+		for i, sibling := range siblings {
+			if sibling.Tag == node.Tag {
+				position = i
+			}
+		}
+
+		if name == "following-sibling" {
+			return siblings[position+1:]
+		}
+
+		nodes := make([]XPathNode, 0, position)
+		for i := position - 1; i >= 0; i-- {
+			nodes = append(nodes, siblings[i])
+		}
+		return nodes
+	case "following", "preceding":
+		if node.Tag == nil {
+			return nil
+		}
+
+		if name == "following" {
+			// Everything after the node in document order, excluding it's descendants. An attribute comes straight
+			// after the start of it's owner, so it's following nodes begin with the owner's first child
+			last := node.Tag
+			if !node.IsAttribute() {
+				descendants := d.descendants(node)
+				if len(descendants) > 0 {
+					last = descendants[len(descendants)-1].Tag
+				}
+			}
+
+			nodes := make([]XPathNode, 0)
+			for _, t := range d.tags[d.order[last]+1:] {
+				nodes = append(nodes, XPathNode{Tag: t})
+			}
+			return nodes
+		}
+
+		// Everything before the node in document order, excluding it's ancestors
+		ancestors := map[*Tag]bool{}
+		for current, ok := d.parent(node); ok && current.Tag != nil; current, ok = d.parent(current) {
+			ancestors[current.Tag] = true
+		}
+
+		end := d.order[node.Tag]
+		if node.IsAttribute() {
+			end += 1
+		}
+
+		nodes := make([]XPathNode, 0)
+		for i := end - 1; i >= 0; i-- {
+			if t := d.tags[i]; !ancestors[t] {
+				nodes = append(nodes, XPathNode{Tag: t})
+			}
+		}
+		return nodes
+	case "attribute":
+		if node.Tag == nil || node.IsAttribute() {
+			return nil
+		}
+
+		keys := sortedAttributeKeys(node.Tag)
+		nodes := make([]XPathNode, len(keys))
+		for i, key := range keys {
+			nodes[i] = XPathNode{Tag: node.Tag, Attribute: key}
+		}
+		return nodes
+	}
+
+	return nil
+}
+
+//endregion Document Model
+
+//region Values
+
+func xpathString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		case v == 0:
+			return "0"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []XPathNode:
+		if len(v) == 0 {
+			return ""
+		}
+		return v[0].Value()
+	}
+
+	return ""
+}
+
+func xpathNumber(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if !isXPathNumber(s) {
+			return math.NaN()
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	case []XPathNode:
+		return xpathNumber(xpathString(v))
+	}
+
+	return math.NaN()
+}
+
+// isXPathNumber: Check if s matches the XPath Number grammar, optionally preceded by a minus sign
+func isXPathNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return false
+	}
+
+	for _, r := range whole + fraction {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func xpathBoolean(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return len(v) > 0
+	case []XPathNode:
+		return len(v) > 0
+	}
+
+	return false
+}
+
+func compareValues(op string, left, right any) bool {
+	leftNodes, leftIsNodes := left.([]XPathNode)
+	rightNodes, rightIsNodes := right.([]XPathNode)
+
+	switch {
+	case leftIsNodes && rightIsNodes:
+		for _, l := range leftNodes {
+			for _, r := range rightNodes {
+				if compareValues(op, l.Value(), r.Value()) {
+					return true
+				}
+			}
+		}
+		return false
+	case leftIsNodes || rightIsNodes:
+		nodes, other := leftNodes, right
+		if rightIsNodes {
+			nodes, other = rightNodes, left
+		}
+
+		if b, ok := other.(bool); ok {
+			return compareAtoms(op, xpathBoolean(nodes), b, !leftIsNodes)
+		}
+
+		for _, node := range nodes {
+			var nodeValue any = node.Value()
+			if _, ok := other.(float64); ok {
+				nodeValue = xpathNumber(nodeValue)
+			}
+
+			if compareAtoms(op, nodeValue, other, !leftIsNodes) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return compareAtoms(op, left, right, false)
+}
+
+// compareAtoms: Compare two non node-set values. When swapped is set, the operands are compared right to left
+func compareAtoms(op string, left, right any, swapped bool) bool {
+	if swapped {
+		left, right = right, left
+	}
+
+	if op == "=" || op == "!=" {
+		var equal bool
+		_, leftIsBool := left.(bool)
+		_, rightIsBool := right.(bool)
+		_, leftIsNumber := left.(float64)
+		_, rightIsNumber := right.(float64)
+
+		switch {
+		case leftIsBool || rightIsBool:
+			equal = xpathBoolean(left) == xpathBoolean(right)
+		case leftIsNumber || rightIsNumber:
+			equal = xpathNumber(left) == xpathNumber(right)
+		default:
+			equal = xpathString(left) == xpathString(right)
+		}
+
+		return equal == (op == "=")
+	}
+
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch op {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+
+	return false
+}
+
+//endregion Values
+
+//region Expressions
+
+type xpathContext struct {
+	doc      *xpathDocument
+	node     XPathNode
+	position int
+	size     int
+}
+
+type xpathExpr interface {
+	eval(ctx *xpathContext) (any, error)
+}
+
+type xpathLiteral struct {
+	value any
+}
+
+func (e *xpathLiteral) eval(ctx *xpathContext) (any, error) {
+	return e.value, nil
+}
+
+type xpathNegate struct {
+	expr xpathExpr
+}
+
+func (e *xpathNegate) eval(ctx *xpathContext) (any, error) {
+	value, err := e.expr.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return -xpathNumber(value), nil
+}
+
+type xpathBinary struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (e *xpathBinary) eval(ctx *xpathContext) (any, error) {
+	left, err := e.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and/or short circuit
+	switch {
+	case e.op == "and" && !xpathBoolean(left):
+		return false, nil
+	case e.op == "or" && xpathBoolean(left):
+		return true, nil
+	}
+
+	right, err := e.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "and", "or":
+		return xpathBoolean(right), nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareValues(e.op, left, right), nil
+	case "|":
+		leftNodes, leftOk := left.([]XPathNode)
+		rightNodes, rightOk := right.([]XPathNode)
+		if !leftOk || !rightOk {
+			return nil, fmt.Errorf("the | operator requires node-sets")
+		}
+		union := append(append([]XPathNode{}, leftNodes...), rightNodes...)
+		return ctx.doc.sortNodes(union), nil
+	}
+
+	l, r := xpathNumber(left), xpathNumber(right)
+	switch e.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "div":
+		return l / r, nil
+	case "mod":
+		return math.Mod(l, r), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %v", e.op)
+}
+
+type xpathNodeTest struct {
+	// One of "name", "*", "text", "node"
+	kind string
+	name string
+}
+
+func (t *xpathNodeTest) matches(axis string, node XPathNode) bool {
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return node.IsText()
+	}
+
+	// Name tests only match the principal node type of the axis
+	if axis == "attribute" {
+		return node.IsAttribute() && (t.kind == "*" || node.Attribute == t.name)
+	}
+
+	if node.Tag == nil || node.IsAttribute() || node.IsText() {
+		return false
+	}
+	return t.kind == "*" || node.Tag.Name == t.name
+}
+
+type xpathStep struct {
+	axis       string
+	test       xpathNodeTest
+	predicates []xpathExpr
+}
+
+// applyPredicates: Filter nodes (in axis order) by each predicate in turn
+func applyPredicates(ctx *xpathContext, nodes []XPathNode, predicates []xpathExpr) ([]XPathNode, error) {
+	for _, predicate := range predicates {
+		filtered := make([]XPathNode, 0, len(nodes))
+		for i, node := range nodes {
+			inner := &xpathContext{doc: ctx.doc, node: node, position: i + 1, size: len(nodes)}
+			value, err := predicate.eval(inner)
+			if err != nil {
+				return nil, err
+			}
+
+			keep := false
+			if number, ok := value.(float64); ok {
+				keep = number == float64(i+1)
+			} else {
+				keep = xpathBoolean(value)
+			}
+
+			if keep {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+
+	return nodes, nil
+}
+
+type xpathPath struct {
+	absolute bool
+	// An optional filter expression the path starts from. Otherwise the path starts at the context node
+	filter xpathExpr
+	steps  []xpathStep
+}
+
+func (e *xpathPath) eval(ctx *xpathContext) (any, error) {
+	var nodes []XPathNode
+	switch {
+	case e.absolute:
+		nodes = []XPathNode{{}}
+	case e.filter != nil:
+		value, err := e.filter.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		nodes, ok = value.([]XPathNode)
+		if !ok {
+			return nil, fmt.Errorf("a location step can only follow a node-set")
+		}
+	default:
+		nodes = []XPathNode{ctx.node}
+	}
+
+	for _, step := range e.steps {
+		next := make([]XPathNode, 0)
+		for _, node := range nodes {
+			candidates := make([]XPathNode, 0)
+			for _, candidate := range ctx.doc.axis(step.axis, node) {
+				if step.test.matches(step.axis, candidate) {
+					candidates = append(candidates, candidate)
+				}
+			}
+
+			selected, err := applyPredicates(ctx, candidates, step.predicates)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		nodes = ctx.doc.sortNodes(next)
+	}
+
+	return nodes, nil
+}
+
+type xpathFilter struct {
+	primary    xpathExpr
+	predicates []xpathExpr
+}
+
+func (e *xpathFilter) eval(ctx *xpathContext) (any, error) {
+	value, err := e.primary.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.([]XPathNode)
+	if !ok {
+		return nil, fmt.Errorf("predicates can only be applied to node-sets")
+	}
+
+	return applyPredicates(ctx, nodes, e.predicates)
+}
+
+type xpathFunction struct {
+	minArgs int
+	// -1 for unlimited arguments
+	maxArgs int
+	call    func(ctx *xpathContext, args []any) (any, error)
+}
+
+type xpathCall struct {
+	name string
+	fn   xpathFunction
+	args []xpathExpr
+}
+
+func (e *xpathCall) eval(ctx *xpathContext) (any, error) {
+	args := make([]any, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	return e.fn.call(ctx, args)
+}
+
+// contextOrArg: Many functions default to the context node when called without an argument
+func contextOrArg(ctx *xpathContext, args []any) any {
+	if len(args) == 0 {
+		return []XPathNode{ctx.node}
+	}
+	return args[0]
+}
+
+func nodeSetArg(name string, value any) ([]XPathNode, error) {
+	nodes, ok := value.([]XPathNode)
+	if !ok {
+		return nil, fmt.Errorf("%v() requires a node-set argument", name)
+	}
+	return nodes, nil
+}
+
+func nameOf(name string, ctx *xpathContext, args []any) (any, error) {
+	nodes, err := nodeSetArg(name, contextOrArg(ctx, args))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(nodes) == 0 || nodes[0].Tag == nil || nodes[0].IsText():
+		return "", nil
+	case nodes[0].IsAttribute():
+		return nodes[0].Attribute, nil
+	}
+
+	tagName := nodes[0].Tag.Name
+	if name == "local-name" {
+		if idx := strings.LastIndex(tagName, ":"); idx != -1 {
+			tagName = tagName[idx+1:]
+		}
+	}
+	return tagName, nil
+}
+
+var xpathFunctions map[string]xpathFunction
+
+func init() {
+	xpathFunctions = map[string]xpathFunction{
+		"last": {0, 0, func(ctx *xpathContext, args []any) (any, error) {
+			return float64(ctx.size), nil
+		}},
+		"position": {0, 0, func(ctx *xpathContext, args []any) (any, error) {
+			return float64(ctx.position), nil
+		}},
+		"count": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			nodes, err := nodeSetArg("count", args[0])
+			return float64(len(nodes)), err
+		}},
+		"name": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return nameOf("name", ctx, args)
+		}},
+		"local-name": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return nameOf("local-name", ctx, args)
+		}},
+		"string": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return xpathString(contextOrArg(ctx, args)), nil
+		}},
+		"concat": {2, -1, func(ctx *xpathContext, args []any) (any, error) {
+			var sb strings.Builder
+			for _, arg := range args {
+				sb.WriteString(xpathString(arg))
+			}
+			return sb.String(), nil
+		}},
+		"starts-with": {2, 2, func(ctx *xpathContext, args []any) (any, error) {
+			return strings.HasPrefix(xpathString(args[0]), xpathString(args[1])), nil
+		}},
+		"contains": {2, 2, func(ctx *xpathContext, args []any) (any, error) {
+			return strings.Contains(xpathString(args[0]), xpathString(args[1])), nil
+		}},
+		"substring-before": {2, 2, func(ctx *xpathContext, args []any) (any, error) {
+			before, _, found := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+			if !found {
+				return "", nil
+			}
+			return before, nil
+		}},
+		"substring-after": {2, 2, func(ctx *xpathContext, args []any) (any, error) {
+			_, after, _ := strings.Cut(xpathString(args[0]), xpathString(args[1]))
+			return after, nil
+		}},
+		"substring": {2, 3, func(ctx *xpathContext, args []any) (any, error) {
+			runes := []rune(xpathString(args[0]))
+			start := math.Round(xpathNumber(args[1]))
+			end := math.Inf(1)
+			if len(args) == 3 {
+				end = start + math.Round(xpathNumber(args[2]))
+			}
+
+			var sb strings.Builder
+			for i, r := range runes {
+				// XPath positions are 1-based
+				position := float64(i + 1)
+				if position >= start && position < end {
+					sb.WriteRune(r)
+				}
+			}
+			return sb.String(), nil
+		}},
+		"string-length": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return float64(len([]rune(xpathString(contextOrArg(ctx, args))))), nil
+		}},
+		"normalize-space": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return strings.Join(strings.Fields(xpathString(contextOrArg(ctx, args))), " "), nil
+		}},
+		"translate": {3, 3, func(ctx *xpathContext, args []any) (any, error) {
+			from, to := []rune(xpathString(args[1])), []rune(xpathString(args[2]))
+			var sb strings.Builder
+			for _, r := range xpathString(args[0]) {
+				position := -1
+				for i, f := range from {
+					if f == r {
+						position = i
+						break
+					}
+				}
+
+				switch {
+				case position == -1:
+					sb.WriteRune(r)
+				case position < len(to):
+					sb.WriteRune(to[position])
+				}
+			}
+			return sb.String(), nil
+		}},
+		"boolean": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return xpathBoolean(args[0]), nil
+		}},
+		"not": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return !xpathBoolean(args[0]), nil
+		}},
+		"true": {0, 0, func(ctx *xpathContext, args []any) (any, error) {
+			return true, nil
+		}},
+		"false": {0, 0, func(ctx *xpathContext, args []any) (any, error) {
+			return false, nil
+		}},
+		"number": {0, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return xpathNumber(contextOrArg(ctx, args)), nil
+		}},
+		"sum": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			nodes, err := nodeSetArg("sum", args[0])
+			total := 0.0
+			for _, node := range nodes {
+				total += xpathNumber(node.Value())
+			}
+			return total, err
+		}},
+		"floor": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return math.Floor(xpathNumber(args[0])), nil
+		}},
+		"ceiling": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			return math.Ceil(xpathNumber(args[0])), nil
+		}},
+		"round": {1, 1, func(ctx *xpathContext, args []any) (any, error) {
+			// XPath rounds halves towards positive infinity
+			return math.Floor(xpathNumber(args[0]) + 0.5), nil
+		}},
+	}
+}
+
+//endregion Expressions
+
+//region Tokenizer
+
+type xpathTokenKind int
+
+const (
+	xpathTokenOperator xpathTokenKind = iota
+	xpathTokenName
+	xpathTokenNumber
+	xpathTokenLiteral
+	// Punctuation which isn't an operator: ( ) [ ] . .. @ , ::
+	xpathTokenSymbol
+)
+
+type xpathToken struct {
+	kind     xpathTokenKind
+	text     string
+	startIdx int
+	endIdx   int
+	// For names, whether the name is followed by ( or ::
+	beforeParen  bool
+	beforeColons bool
+}
+
+func isXPathNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || (r > unicode.MaxASCII && isRuneValidForName(r) && !unicode.IsDigit(r))
+}
+
+func isXPathNameRune(r rune) bool {
+	return isXPathNameStart(r) || unicode.IsDigit(r) || r == '-' || r == '.'
+}
+
+func tokenizeXPath(runes []rune) ([]xpathToken, error) {
+	tokens := make([]xpathToken, 0)
+
+	// precedingAllowsOperator: Implements the disambiguation rules for * and operator names from the XPath specification
+	precedingAllowsOperator := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+
+		previous := tokens[len(tokens)-1]
+		switch previous.kind {
+		case xpathTokenOperator:
+			return false
+		case xpathTokenSymbol:
+			return previous.text == ")" || previous.text == "]" || previous.text == "." || previous.text == ".."
+		}
+		return true
+	}
+
+	idx := 0
+	for idx < len(runes) {
+		r := runes[idx]
+		start := idx
+
+		if unicode.IsSpace(r) {
+			idx += 1
+			continue
+		}
+
+		next := rune(0)
+		if idx+1 < len(runes) {
+			next = runes[idx+1]
+		}
+
+		addToken := func(kind xpathTokenKind, length int) {
+			idx += length
+			tokens = append(tokens, xpathToken{kind: kind, text: string(runes[start:idx]), startIdx: start, endIdx: idx})
+		}
+
+		switch {
+		case r == '/' && next == '/':
+			addToken(xpathTokenOperator, 2)
+		case r == '!' && next == '=', r == '<' && next == '=', r == '>' && next == '=':
+			addToken(xpathTokenOperator, 2)
+		case strings.ContainsRune("/|+-=<>", r):
+			addToken(xpathTokenOperator, 1)
+		case r == '*':
+			if precedingAllowsOperator() {
+				addToken(xpathTokenOperator, 1)
+			} else {
+				addToken(xpathTokenName, 1)
+			}
+		case r == ':' && next == ':':
+			addToken(xpathTokenSymbol, 2)
+		case r == '.' && next == '.':
+			addToken(xpathTokenSymbol, 2)
+		case r == '.' && !unicode.IsDigit(next):
+			addToken(xpathTokenSymbol, 1)
+		case strings.ContainsRune("()[]@,", r):
+			addToken(xpathTokenSymbol, 1)
+		case r == '"' || r == '\'':
+			end := idx + 1
+			for end < len(runes) && runes[end] != r {
+				end += 1
+			}
+			if end >= len(runes) {
+				return nil, &ParseError{StartIdx: start, EndIdx: end, Reason: "Expression ended without closing the string literal"}
+			}
+
+			tokens = append(tokens, xpathToken{kind: xpathTokenLiteral, text: string(runes[start+1 : end]), startIdx: start, endIdx: end + 1})
+			idx = end + 1
+		case unicode.IsDigit(r) || r == '.':
+			end := idx
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end += 1
+			}
+			addToken(xpathTokenNumber, end-idx)
+		case isXPathNameStart(r):
+			end := idx
+			for end < len(runes) {
+				c := runes[end]
+				// Allow prefixed names such as name:now, but not axis separators
+				isPrefix := c == ':' && end+1 < len(runes) && isXPathNameStart(runes[end+1])
+				if !isXPathNameRune(c) && !isPrefix {
+					break
+				}
+				end += 1
+			}
+
+			name := string(runes[start:end])
+			isOperatorName := name == "and" || name == "or" || name == "div" || name == "mod"
+			if isOperatorName && precedingAllowsOperator() {
+				addToken(xpathTokenOperator, end-idx)
+			} else {
+				addToken(xpathTokenName, end-idx)
+			}
+		case r == '$':
+			return nil, &ParseError{StartIdx: start, EndIdx: start + 1, Reason: "Variable references are not supported"}
+		default:
+			return nil, &ParseError{StartIdx: start, EndIdx: start + 1, Reason: fmt.Sprintf("Unexpected rune in expression - %v", string(r))}
+		}
+	}
+
+	// Record what follows each name, as this decides whether it is a function, node type or axis
+	for i := range tokens {
+		if tokens[i].kind != xpathTokenName || i+1 >= len(tokens) {
+			continue
+		}
+
+		tokens[i].beforeParen = tokens[i+1].kind == xpathTokenSymbol && tokens[i+1].text == "("
+		tokens[i].beforeColons = tokens[i+1].kind == xpathTokenSymbol && tokens[i+1].text == "::"
+	}
+
+	return tokens, nil
+}
+
+//endregion Tokenizer
+
+//region Parser
+
+type xpathParser struct {
+	tokens []xpathToken
+	idx    int
+}
+
+func (p *xpathParser) done() bool {
+	return p.idx >= len(p.tokens)
+}
+
+func (p *xpathParser) peek() xpathToken {
+	if p.done() {
+		return xpathToken{kind: -1}
+	}
+	return p.tokens[p.idx]
+}
+
+func (p *xpathParser) is(kind xpathTokenKind, texts ...string) bool {
+	token := p.peek()
+	if token.kind != kind {
+		return false
+	}
+
+	for _, text := range texts {
+		if token.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *xpathParser) errorf(format string, args ...any) error {
+	if p.done() {
+		end := 0
+		if len(p.tokens) > 0 {
+			end = p.tokens[len(p.tokens)-1].endIdx
+		}
+		return &ParseError{StartIdx: end, EndIdx: end, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	token := p.peek()
+	return &ParseError{StartIdx: token.startIdx, EndIdx: token.endIdx, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *xpathParser) expect(text string) error {
+	if !p.is(xpathTokenSymbol, text) {
+		if p.done() {
+			return p.errorf("Expected %v - got end of expression", text)
+		}
+		return p.errorf("Expected %v - got %v", text, p.peek().text)
+	}
+	p.idx += 1
+	return nil
+}
+
+// parseBinary: Parse a left associative chain of operators, where next parses the operands
+func (p *xpathParser) parseBinary(next func() (xpathExpr, error), operators ...string) (xpathExpr, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.is(xpathTokenOperator, operators...) {
+		op := p.peek().text
+		p.idx += 1
+
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathBinary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	return p.parseOr()
+}
+
+func (p *xpathParser) parseOr() (xpathExpr, error) {
+	return p.parseBinary(p.parseAnd, "or")
+}
+
+func (p *xpathParser) parseAnd() (xpathExpr, error) {
+	return p.parseBinary(p.parseEquality, "and")
+}
+
+func (p *xpathParser) parseEquality() (xpathExpr, error) {
+	return p.parseBinary(p.parseRelational, "=", "!=")
+}
+
+func (p *xpathParser) parseRelational() (xpathExpr, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *xpathParser) parseAdditive() (xpathExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *xpathParser) parseMultiplicative() (xpathExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "div", "mod")
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.is(xpathTokenOperator, "-") {
+		p.idx += 1
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathNegate{expr: expr}, nil
+	}
+
+	return p.parseUnion()
+}
+
+func (p *xpathParser) parseUnion() (xpathExpr, error) {
+	return p.parseBinary(p.parsePathExpr, "|")
+}
+
+func isXPathNodeType(name string) bool {
+	return name == "text" || name == "node" || name == "comment" || name == "processing-instruction"
+}
+
+// startsStep: Check if the current token can begin a location step
+func (p *xpathParser) startsStep() bool {
+	token := p.peek()
+	switch token.kind {
+	case xpathTokenSymbol:
+		return token.text == "." || token.text == ".." || token.text == "@"
+	case xpathTokenName:
+		return !token.beforeParen || isXPathNodeType(token.text)
+	}
+	return false
+}
+
+func (p *xpathParser) parsePathExpr() (xpathExpr, error) {
+	switch {
+	case p.is(xpathTokenOperator, "/"):
+		p.idx += 1
+		path := &xpathPath{absolute: true}
+		if !p.startsStep() {
+			return path, nil
+		}
+		return path, p.parseRelativePath(path)
+	case p.is(xpathTokenOperator, "//"):
+		p.idx += 1
+		path := &xpathPath{absolute: true, steps: []xpathStep{descendantOrSelfStep()}}
+		return path, p.parseRelativePath(path)
+	case p.startsStep():
+		path := &xpathPath{}
+		return path, p.parseRelativePath(path)
+	}
+
+	filter, err := p.parseFilterExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.is(xpathTokenOperator, "/", "//") {
+		return filter, nil
+	}
+
+	path := &xpathPath{filter: filter}
+	if p.is(xpathTokenOperator, "//") {
+		path.steps = append(path.steps, descendantOrSelfStep())
+	}
+	p.idx += 1
+	return path, p.parseRelativePath(path)
+}
+
+func descendantOrSelfStep() xpathStep {
+	return xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: "node"}}
+}
+
+func (p *xpathParser) parseRelativePath(path *xpathPath) error {
+	for {
+		step, err := p.parseStep()
+		if err != nil {
+			return err
+		}
+		path.steps = append(path.steps, step)
+
+		switch {
+		case p.is(xpathTokenOperator, "/"):
+			p.idx += 1
+		case p.is(xpathTokenOperator, "//"):
+			p.idx += 1
+			path.steps = append(path.steps, descendantOrSelfStep())
+		default:
+			return nil
+		}
+	}
+}
+
+var xpathAxes = map[string]bool{
+	"child": true, "descendant": true, "descendant-or-self": true, "self": true,
+	"parent": true, "ancestor": true, "ancestor-or-self": true,
+	"following-sibling": true, "preceding-sibling": true, "following": true, "preceding": true,
+	"attribute": true,
+}
+
+func (p *xpathParser) parseStep() (step xpathStep, err error) {
+	switch {
+	case p.is(xpathTokenSymbol, "."):
+		p.idx += 1
+		return xpathStep{axis: "self", test: xpathNodeTest{kind: "node"}}, nil
+	case p.is(xpathTokenSymbol, ".."):
+		p.idx += 1
+		return xpathStep{axis: "parent", test: xpathNodeTest{kind: "node"}}, nil
+	case p.is(xpathTokenSymbol, "@"):
+		p.idx += 1
+		step.axis = "attribute"
+	case p.peek().kind == xpathTokenName && p.peek().beforeColons:
+		token := p.peek()
+		if !xpathAxes[token.text] {
+			return step, p.errorf("Unsupported axis %v", token.text)
+		}
+		step.axis = token.text
+		p.idx += 2
+	default:
+		step.axis = "child"
+	}
+
+	token := p.peek()
+	if token.kind != xpathTokenName {
+		if p.done() {
+			return step, p.errorf("Expected a node test - got end of expression")
+		}
+		return step, p.errorf("Expected a node test - got %v", token.text)
+	}
+	p.idx += 1
+
+	switch {
+	case token.text == "*":
+		step.test = xpathNodeTest{kind: "*"}
+	case token.beforeParen && isXPathNodeType(token.text):
+		if token.text != "text" && token.text != "node" {
+			return step, &ParseError{StartIdx: token.startIdx, EndIdx: token.endIdx, Reason: fmt.Sprintf("Unsupported node type %v()", token.text)}
+		}
+		step.test = xpathNodeTest{kind: token.text}
+		p.idx += 1
+		if err = p.expect(")"); err != nil {
+			return
+		}
+	default:
+		step.test = xpathNodeTest{kind: "name", name: token.text}
+	}
+
+	step.predicates, err = p.parsePredicates()
+	return
+}
+
+func (p *xpathParser) parsePredicates() ([]xpathExpr, error) {
+	predicates := make([]xpathExpr, 0)
+	for p.is(xpathTokenSymbol, "[") {
+		p.idx += 1
+		predicate, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	return predicates, nil
+}
+
+func (p *xpathParser) parseFilterExpr() (xpathExpr, error) {
+	primary, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	predicates, err := p.parsePredicates()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(predicates) == 0 {
+		return primary, nil
+	}
+	return &xpathFilter{primary: primary, predicates: predicates}, nil
+}
+
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	token := p.peek()
+	switch {
+	case p.done():
+		return nil, p.errorf("Expected an expression - got end of expression")
+	case token.kind == xpathTokenLiteral:
+		p.idx += 1
+		return &xpathLiteral{value: token.text}, nil
+	case token.kind == xpathTokenNumber:
+		p.idx += 1
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, &ParseError{StartIdx: token.startIdx, EndIdx: token.endIdx, Reason: fmt.Sprintf("Invalid number %v", token.text)}
+		}
+		return &xpathLiteral{value: number}, nil
+	case p.is(xpathTokenSymbol, "("):
+		p.idx += 1
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	case token.kind == xpathTokenName && token.beforeParen:
+		fn, ok := xpathFunctions[token.text]
+		if !ok {
+			return nil, p.errorf("Unsupported function %v()", token.text)
+		}
+		p.idx += 2
+
+		call := &xpathCall{name: token.text, fn: fn}
+		if !p.is(xpathTokenSymbol, ")") {
+			for {
+				arg, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				call.args = append(call.args, arg)
+
+				if !p.is(xpathTokenSymbol, ",") {
+					break
+				}
+				p.idx += 1
+			}
+		}
+
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+
+		if len(call.args) < fn.minArgs || (fn.maxArgs != -1 && len(call.args) > fn.maxArgs) {
+			return nil, &ParseError{StartIdx: token.startIdx, EndIdx: token.endIdx,
+				Reason: fmt.Sprintf("Wrong number of arguments for %v(). Got %v", token.text, len(call.args))}
+		}
+		return call, nil
+	}
+
+	return nil, p.errorf("Unexpected token in expression - %v", token.text)
+}
+
+//endregion Parser
@@ -0,0 +1,252 @@
+package tagparser
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var xpathDocumentInput []rune = []rune(`<library name="City">
+	<shelf id="s1">
+		<book year="1999" lang="en"><title>Alpha</title><price>10</price></book>
+		<book year="2005" lang="fr"><title>Beta</title><price>25.5</price></book>
+	</shelf>
+	<shelf id="s2">
+		<book year="2010" lang="en"><title>Gamma</title><price>7</price></book>
+		<note>Closed <b>Mondays</b> and holidays</note>
+	</shelf>
+</library>`)
+
+// xpathValues: Evaluate the expression against the test document, rendering the results as strings
+func xpathValues(t *testing.T, expr string) []string {
+	result, err := Parse(xpathDocumentInput)
+	if err != nil {
+		t.Fatalf("Expected test document to parse. Got %v", err)
+	}
+
+	nodes, err := XPathSelect(&result.Root, expr)
+	if err != nil {
+		t.Fatalf("Expected %v to evaluate. Got %v", expr, err)
+	}
+
+	got := make([]string, 0)
+	for _, node := range nodes {
+		switch {
+		case node.Tag == nil:
+			got = append(got, "#document")
+		case node.IsAttribute():
+			got = append(got, "@"+node.Attribute+"="+node.Value())
+		case node.IsText():
+			got = append(got, "'"+node.Value()+"'")
+		default:
+			got = append(got, node.Tag.Name+":"+node.Value())
+		}
+	}
+	return got
+}
+
+func TestXPath_LocationPaths(t *testing.T) {
+	type Def struct {
+		expr string
+		want []string
+	}
+
+	test_defs := []Def{
+		{expr: "/", want: []string{"#document"}},
+		{expr: "/library/shelf/book/title", want: []string{"title:Alpha", "title:Beta", "title:Gamma"}},
+		{expr: "library/shelf[2]/book/title", want: []string{"title:Gamma"}},
+		{expr: "//title", want: []string{"title:Alpha", "title:Beta", "title:Gamma"}},
+		{expr: "//shelf[@id='s2']//b", want: []string{"b:Mondays"}},
+		{expr: "//book[1]/title", want: []string{"title:Alpha", "title:Gamma"}},
+		{expr: "(//book)[1]/title", want: []string{"title:Alpha"}},
+		{expr: "//book[last()]/title", want: []string{"title:Beta", "title:Gamma"}},
+		{expr: "//book/@year", want: []string{"@year=1999", "@year=2005", "@year=2010"}},
+		{expr: "/library/@*", want: []string{"@name=City"}},
+		{expr: "//note/text()", want: []string{"'Closed'", "'and holidays'"}},
+		{expr: "//note/node()", want: []string{"'Closed'", "b:Mondays", "'and holidays'"}},
+		{expr: "//title[.='Beta']/..", want: []string{"book:Beta25.5"}},
+		{expr: "//b/ancestor::*", want: []string{"library:Alpha10Beta25.5Gamma7ClosedMondaysand holidays", "shelf:Gamma7ClosedMondaysand holidays", "note:ClosedMondaysand holidays"}},
+		{expr: "//b/ancestor-or-self::note", want: []string{"note:ClosedMondaysand holidays"}},
+		{expr: "//book[title='Alpha']/following-sibling::book/title", want: []string{"title:Beta"}},
+		{expr: "//book[title='Beta']/preceding-sibling::*[1]/title", want: []string{"title:Alpha"}},
+		{expr: "//price[.='25.5']/following::title", want: []string{"title:Gamma"}},
+		{expr: "//book[@year='2005']/@lang/following::title", want: []string{"title:Beta", "title:Gamma"}},
+		{expr: "//book[@year='2005']/@lang/preceding::title", want: []string{"title:Alpha"}},
+		{expr: "//book[3]", want: []string{}},
+		{expr: "//title | //price[. > 20]", want: []string{"title:Alpha", "title:Beta", "price:25.5", "title:Gamma"}},
+		{expr: "//child::shelf/descendant::price", want: []string{"price:10", "price:25.5", "price:7"}},
+		{expr: "//self::title", want: []string{"title:Alpha", "title:Beta", "title:Gamma"}},
+	}
+
+	for _, def := range test_defs {
+		got := xpathValues(t, def.expr)
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("XPath %v selected incorrectly.\nGot  %v\nWant %v", def.expr, got, def.want)
+		}
+	}
+}
+
+func TestXPath_Predicates(t *testing.T) {
+	type Def struct {
+		expr string
+		want []string
+	}
+
+	test_defs := []Def{
+		{expr: "//book[@lang='en']/title", want: []string{"title:Alpha", "title:Gamma"}},
+		{expr: "//book[@year > 2000 and @lang != 'fr']/title", want: []string{"title:Gamma"}},
+		{expr: "//book[price < 10 or @year < 2000]/title", want: []string{"title:Alpha", "title:Gamma"}},
+		{expr: "//book[contains(title, 'amm')]/title", want: []string{"title:Gamma"}},
+		{expr: "//book[starts-with(title, 'B')]/@lang", want: []string{"@lang=fr"}},
+		{expr: "//shelf[count(book) = 2]/@id", want: []string{"@id=s1"}},
+		{expr: "//book[position() mod 2 = 0]/title", want: []string{"title:Beta"}},
+		{expr: "//book[not(@lang='en')]/title", want: []string{"title:Beta"}},
+		{expr: "//shelf[book/@year = 2010]/@id", want: []string{"@id=s2"}},
+		{expr: "//*[@id][2]", want: []string{"shelf:Gamma7ClosedMondaysand holidays"}},
+	}
+
+	for _, def := range test_defs {
+		got := xpathValues(t, def.expr)
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("XPath %v selected incorrectly.\nGot  %v\nWant %v", def.expr, got, def.want)
+		}
+	}
+}
+
+func TestXPath_EvaluatesScalarExpressions(t *testing.T) {
+	result, _ := Parse(xpathDocumentInput)
+
+	type Def struct {
+		expr string
+		want any
+	}
+
+	test_defs := []Def{
+		{expr: "count(//book)", want: 3.0},
+		{expr: "sum(//price)", want: 42.5},
+		{expr: "string(//book[2]/title)", want: "Beta"},
+		{expr: "concat(//shelf[1]/@id, '-', //shelf[2]/@id)", want: "s1-s2"},
+		{expr: "normalize-space('  a   b ')", want: "a b"},
+		{expr: "string-length(//title)", want: 5.0},
+		{expr: "substring('12345', 2, 3)", want: "234"},
+		{expr: "substring-before('1999/12', '/')", want: "1999"},
+		{expr: "substring-after('1999/12', '/')", want: "12"},
+		{expr: "translate('bar', 'abc', 'ABC')", want: "BAr"},
+		{expr: "name(//book[1]/@lang)", want: "lang"},
+		{expr: "local-name(/*)", want: "library"},
+		{expr: "1 + 2 * 3 - 4 div 2", want: 5.0},
+		{expr: "7 mod 3", want: 1.0},
+		{expr: "-(2)", want: -2.0},
+		{expr: "floor(2.5) + ceiling(2.5) + round(2.5)", want: 8.0},
+		{expr: "//book/@year = 2005", want: true},
+		{expr: "//book/@year = 2006", want: false},
+		{expr: "//book/@year != 2005", want: true},
+		{expr: "//title = //b", want: false},
+		{expr: "boolean(//missing)", want: false},
+		{expr: "true() and not(false())", want: true},
+		{expr: "string(1 div 0)", want: "Infinity"},
+		{expr: "string(0.5)", want: "0.5"},
+		{expr: "string(3)", want: "3"},
+	}
+
+	for _, def := range test_defs {
+		got, err := XPathEvaluate(&result.Root, def.expr)
+		if err != nil {
+			t.Errorf("Expected %v to evaluate. Got %v", def.expr, err)
+			continue
+		}
+		if !cmp.Equal(got, def.want) {
+			t.Errorf("XPath %v evaluated incorrectly. Got %v (%T) Want %v", def.expr, got, got, def.want)
+		}
+	}
+
+	got, err := XPathEvaluate(&result.Root, "number('abc')")
+	if number, ok := got.(float64); err != nil || !ok || !math.IsNaN(number) {
+		t.Errorf("Expected number('abc') to be NaN. Got %v (%v)", got, err)
+	}
+}
+
+func TestXPath_SelectReturnsPointersIntoTree(t *testing.T) {
+	result, _ := Parse(xpathDocumentInput)
+	root := &result.Root
+
+	nodes, err := MustCompileXPath("/library/shelf[2]").Select(root)
+	if err != nil || len(nodes) != 1 || nodes[0].Tag != &root.Children[1] {
+		t.Errorf("Select did not return pointers into the tree. Got %v (%v)", nodes, err)
+	}
+}
+
+func TestXPath_SelectErrorsForNonNodeSets(t *testing.T) {
+	result, _ := Parse(xpathDocumentInput)
+
+	_, err := XPathSelect(&result.Root, "count(//book)")
+	if err == nil || !strings.Contains(err.Error(), "does not result in a node-set") {
+		t.Errorf("Expected Select to fail for a number result. Got %v", err)
+	}
+
+	_, err = XPathEvaluate(&result.Root, "count('a')")
+	if err == nil || !strings.Contains(err.Error(), "requires a node-set") {
+		t.Errorf("Expected count() to fail for a string argument. Got %v", err)
+	}
+}
+
+func TestCompileXPath_ErrorsWithInvalidExpressions(t *testing.T) {
+	type Def struct {
+		expr          string
+		expectedError string
+	}
+
+	test_defs := []Def{
+		{expr: "", expectedError: "Expected an expression"},
+		{expr: "//", expectedError: "Expected a node test"},
+		{expr: "/a[", expectedError: "Expected an expression"},
+		{expr: "/a[1", expectedError: "Expected ]"},
+		{expr: "'open", expectedError: "without closing the string literal"},
+		{expr: "$var", expectedError: "Variable references are not supported"},
+		{expr: "namespace::a", expectedError: "Unsupported axis"},
+		{expr: "comment()", expectedError: "Unsupported node type"},
+		{expr: "upper-case('a')", expectedError: "Unsupported function"},
+		{expr: "count()", expectedError: "Wrong number of arguments"},
+		{expr: "1 +", expectedError: "Expected an expression"},
+		{expr: "a b", expectedError: "Unexpected token"},
+		{expr: "a # b", expectedError: "Unexpected rune"},
+	}
+
+	for _, def := range test_defs {
+		_, err := CompileXPath(def.expr)
+		if err == nil || !strings.Contains(err.Error(), def.expectedError) {
+			t.Errorf("XPath %q error was %v but expected %v", def.expr, err, def.expectedError)
+		}
+	}
+}
+
+func TestTokenizeXPath_DisambiguatesOperators(t *testing.T) {
+	type Def struct {
+		expr string
+		want []xpathTokenKind
+	}
+
+	test_defs := []Def{
+		// The first * is a name test, the second is multiplication
+		{expr: "* * 2", want: []xpathTokenKind{xpathTokenName, xpathTokenOperator, xpathTokenNumber}},
+		// div is an element name at the start of an expression
+		{expr: "div div div", want: []xpathTokenKind{xpathTokenName, xpathTokenOperator, xpathTokenName}},
+		{expr: "@*", want: []xpathTokenKind{xpathTokenSymbol, xpathTokenName}},
+		{expr: "name:now", want: []xpathTokenKind{xpathTokenName}},
+		{expr: "child::a", want: []xpathTokenKind{xpathTokenName, xpathTokenSymbol, xpathTokenName}},
+	}
+
+	for _, def := range test_defs {
+		tokens, err := tokenizeXPath([]rune(def.expr))
+		got := make([]xpathTokenKind, len(tokens))
+		for i, token := range tokens {
+			got[i] = token.kind
+		}
+
+		if err != nil || !cmp.Equal(got, def.want) {
+			t.Errorf("tokenizeXPath(%v) got %v (%v) want %v", def.expr, got, err, def.want)
+		}
+	}
+}
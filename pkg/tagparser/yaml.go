@@ -0,0 +1,126 @@
+package tagparser
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WriteYaml: Write the tag to w as a YAML document, using the same shape as ToJson.
+// Each element is a mapping, with the tag name under "_name", each attribute (in name order) and the children (if any)
+// as a sequence under "_children". <text> pseudo tags are written as strings.
+//
+// Strings which YAML would read as another type (i.e. "true", "1.5" or "null") or which contain special characters
+// are double quoted, so every value is read back as the original string. A nil tag writes nothing.
+func WriteYaml(w io.Writer, tag *Tag) error {
+	if tag == nil {
+		return nil
+	}
+
+	sw := &stickyWriter{w: w}
+
+	// The indentation of each open element's keys
+	indents := make([]string, 0)
+
+	enter := func(t *Tag) error {
+		// The first key of an element inside a sequence shares the line with it's "- " marker
+		firstPrefix, indent := "", ""
+		if len(indents) > 0 {
+			sequenceIndent := indents[len(indents)-1] + "  "
+			firstPrefix, indent = sequenceIndent+"- ", sequenceIndent+"  "
+		}
+
+		if t.Name == TextTagName {
+			text, err := textTagContent(t)
+			if err != nil {
+				return err
+			}
+
+			sw.WriteString(firstPrefix + yamlScalar(text) + "\n")
+			return SkipChildren
+		}
+
+		sw.WriteString(firstPrefix + "_name: " + yamlScalar(t.Name) + "\n")
+		for _, key := range sortedAttributeKeys(t) {
+			sw.WriteString(indent + yamlScalar(key) + ": " + yamlScalar(t.Attributes[key]) + "\n")
+		}
+
+		if len(t.Children) > 0 {
+			sw.WriteString(indent + "_children:\n")
+		}
+
+		indents = append(indents, indent)
+		return sw.err
+	}
+
+	exit := func(t *Tag) error {
+		if t.Name != TextTagName {
+			indents = indents[:len(indents)-1]
+		}
+		return sw.err
+	}
+
+	return WalkEnterExit(tag, enter, exit)
+}
+
+// ToYaml: Convert the tag to a YAML document. See WriteYaml for the output shape.
+func (tag *Tag) ToYaml() (string, error) {
+	var sb strings.Builder
+	err := WriteYaml(&sb, tag)
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Plain scalars which YAML 1.1 or 1.2 would read as booleans, nulls or special numbers
+var yamlReservedWords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+	"null": true, "~": true, ".inf": true, "-.inf": true, "+.inf": true, ".nan": true,
+}
+
+// yamlScalar: Write a string as a YAML scalar, quoting it when it can't be written as a plain string.
+// Double quoted YAML scalars support the same escapes as JSON, so the JSON quoting is reused.
+func yamlScalar(s string) string {
+	if yamlNeedsQuotes(s) {
+		return quoteJsonString(s)
+	}
+	return s
+}
+
+// yamlNeedsQuotes: Check if a plain scalar could be misread, or is invalid.
+// This is deliberately conservative - i.e. anything starting like a number is quoted.
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || yamlReservedWords[strings.ToLower(s)] {
+		return true
+	}
+
+	first, _ := utf8.DecodeRuneInString(s)
+	last, _ := utf8.DecodeLastRuneInString(s)
+	if unicode.IsSpace(first) || unicode.IsSpace(last) || last == ':' {
+		return true
+	}
+
+	// Numbers, dates, times and versions
+	if unicode.IsDigit(first) || (len(s) > 1 && strings.ContainsRune("+-.", first) && unicode.IsDigit(rune(s[1]))) {
+		return true
+	}
+
+	// Indicator characters, which have a special meaning at the start of a scalar
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", first) {
+		return true
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return true
+	}
+
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) && r != ' ' {
+			return true
+		}
+	}
+
+	return false
+}
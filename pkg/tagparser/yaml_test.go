@@ -0,0 +1,111 @@
+package tagparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToYaml_WorksWithNilTag(t *testing.T) {
+	var tag *Tag
+	got, err := tag.ToYaml()
+	if err != nil || got != "" {
+		t.Errorf("ToYaml doesn't work with nil tags. Got %v (%v)", got, err)
+	}
+}
+
+func TestToYaml_WritesDocument(t *testing.T) {
+	result, err := Parse([]rune(`<library id="1"><book lang="en"><title>Go</title><price>10</price></book>Some <shelf />text</library>`))
+	if err != nil {
+		t.Fatalf("Expected document to parse. Got %v", err)
+	}
+
+	got, err := result.Root.ToYaml()
+	want := `_name: library
+id: "1"
+_children:
+  - _name: book
+    lang: en
+    _children:
+      - _name: title
+        _children:
+          - Go
+      - _name: price
+        _children:
+          - "10"
+  - Some
+  - _name: shelf
+  - text
+`
+	if err != nil || got != want {
+		t.Errorf("ToYaml produced incorrect output (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestToYaml_QuotesAmbiguousStrings(t *testing.T) {
+	type Def struct {
+		value string
+		want  string
+	}
+
+	test_defs := []Def{
+		{value: "plain text", want: "plain text"},
+		{value: "🐶", want: "🐶"},
+		{value: "a-b:c", want: "a-b:c"},
+		{value: "", want: `""`},
+		{value: "true", want: `"true"`},
+		{value: "No", want: `"No"`},
+		{value: "null", want: `"null"`},
+		{value: "~", want: `"~"`},
+		{value: "42", want: `"42"`},
+		{value: "-1.5e3", want: `"-1.5e3"`},
+		{value: ".5", want: `".5"`},
+		{value: "2024-01-01", want: `"2024-01-01"`},
+		{value: ".inf", want: `".inf"`},
+		{value: "- item", want: `"- item"`},
+		{value: "#comment", want: `"#comment"`},
+		{value: "*alias", want: `"*alias"`},
+		{value: "key: value", want: `"key: value"`},
+		{value: "trailing:", want: `"trailing:"`},
+		{value: "a #b", want: `"a #b"`},
+		{value: " padded", want: `" padded"`},
+		{value: `say "hi"`, want: `say "hi"`},
+		{value: "line\nbreak", want: `"line\nbreak"`},
+		{value: "tab\there", want: `"tab\there"`},
+	}
+
+	for _, def := range test_defs {
+		got := yamlScalar(def.value)
+		if got != def.want {
+			t.Errorf("Incorrect YAML scalar for %q. Got %v Want %v", def.value, got, def.want)
+		}
+	}
+}
+
+func TestToYaml_QuotesKeysAndNames(t *testing.T) {
+	tag := &Tag{Name: "", Attributes: map[string]string{"yes": "no"}, Children: []Tag{{Name: "1"}}}
+	got, err := tag.ToYaml()
+	want := `_name: ""
+"yes": "no"
+_children:
+  - _name: "1"
+`
+	if err != nil || got != want {
+		t.Errorf("ToYaml produced incorrect output (%v). \nGot:\n%v\nWant:\n%v", err, got, want)
+	}
+}
+
+func TestToYaml_WritesTextRoots(t *testing.T) {
+	tag := &Tag{Name: TextTagName, Attributes: map[string]string{TextAttributeName: "false"}}
+	got, err := tag.ToYaml()
+	if err != nil || got != "\"false\"\n" {
+		t.Errorf("ToYaml produced incorrect output for text (%v). Got %v", err, got)
+	}
+}
+
+func TestToYaml_ReturnsErrors(t *testing.T) {
+	tag := &Tag{Name: "a", Children: []Tag{{Name: TextTagName}}}
+	_, err := tag.ToYaml()
+	if err == nil || !strings.Contains(err.Error(), "missing it's required text") {
+		t.Errorf("ToYaml should fail for text tags without text. Got %v", err)
+	}
+}